@@ -0,0 +1,295 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package chat
+
+import (
+    "errors"
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "tidepool/ratelimit"
+    tp "tidepool/tidepool"
+    "tidepool/tidepool/gene"
+)
+
+const (
+    defaultPrefix = "!"
+    defaultPace = 500 * time.Millisecond
+    defaultVoteWindow = time.Minute
+    defaultMaxNuke = 16
+    defaultEnergy = 1000
+    defaultQueue = 64
+)
+
+var (
+    ErrQueueFull = errors.New("intervention queue full")
+    ErrRateLimited = errors.New("slow down")
+)
+
+type BridgeOptions struct {
+    Prefix string
+    Pace time.Duration
+    VoteWindow time.Duration
+    MaxNuke int32
+    Energy int64
+    Queue int
+    Limiter *ratelimit.Limiter
+}
+
+type Reply func(channel, text string) error
+
+type intervention struct {
+    user string
+    channel string
+    name string
+    apply func(e *tp.Env) error
+}
+
+type Bridge struct {
+    env *tp.Env
+    opts BridgeOptions
+    queue []intervention
+    votes map[string]string
+    channels map[string]bool
+}
+
+func NewBridge(e *tp.Env, opts BridgeOptions) *Bridge {
+    if opts.Prefix == "" {
+        opts.Prefix = defaultPrefix
+    }
+    if opts.Pace <= 0 {
+        opts.Pace = defaultPace
+    }
+    if opts.VoteWindow <= 0 {
+        opts.VoteWindow = defaultVoteWindow
+    }
+    if opts.MaxNuke < 1 {
+        opts.MaxNuke = defaultMaxNuke
+    }
+    if opts.Energy < 1 {
+        opts.Energy = defaultEnergy
+    }
+    if opts.Queue < 1 {
+        opts.Queue = defaultQueue
+    }
+    return &Bridge{
+        env: e,
+        opts: opts,
+        votes: make(map[string]string),
+        channels: make(map[string]bool),
+    }
+}
+
+func parseInts(args []string, n int) ([]int32, error) {
+    if len(args) < n {
+        return nil, fmt.Errorf("expected %d numbers", n)
+    }
+    vs := make([]int32, n)
+    for i := range vs {
+        v, err := strconv.ParseInt(args[i], 10, 32)
+        if err != nil {
+            return nil, fmt.Errorf("invalid number %q", args[i])
+        }
+        vs[i] = int32(v)
+    }
+    return vs, nil
+}
+
+func (b *Bridge) seed(args []string) (func(e *tp.Env) error, error) {
+    vs, err := parseInts(args, 2)
+    if err != nil {
+        return nil, err
+    }
+    x, y := vs[0], vs[1]
+    if x < 0 || y < 0 || x >= b.env.Width || y >= b.env.Height {
+        return nil, fmt.Errorf("%d,%d out of bounds", x, y)
+    }
+
+    if len(args) < 3 {
+        return func(e *tp.Env) error {
+            return e.InflowBurst(1, &tp.Rect{X: x, Y: y, W: 1, H: 1})
+        }, nil
+    }
+
+    g, err := gene.ParseGenome(args[2])
+    if err != nil {
+        return nil, err
+    }
+    if int32(len(g)) > b.env.GenomeSize {
+        return nil, fmt.Errorf("genome exceeds %d genes", b.env.GenomeSize)
+    }
+    return func(e *tp.Env) error {
+        _, err := e.InjectCell(x, y, g, b.opts.Energy)
+        return err
+    }, nil
+}
+
+func (b *Bridge) nuke(args []string) (func(e *tp.Env) error, error) {
+    vs, err := parseInts(args, 2)
+    if err != nil {
+        return nil, err
+    }
+    r := tp.Rect{X: vs[0], Y: vs[1], W: 1, H: 1}
+    if len(args) >= 4 {
+        if vs, err = parseInts(args[2:], 2); err != nil {
+            return nil, err
+        }
+        r.W, r.H = vs[0], vs[1]
+    }
+    if r.W < 1 || r.H < 1 {
+        return nil, errors.New("empty region")
+    }
+    if r.W > b.opts.MaxNuke {
+        r.W = b.opts.MaxNuke
+    }
+    if r.H > b.opts.MaxNuke {
+        r.H = b.opts.MaxNuke
+    }
+    return func(e *tp.Env) error {
+        _, err := e.KillRegion(r)
+        return err
+    }, nil
+}
+
+func (b *Bridge) enqueue(m Message, name string, fn func(e *tp.Env) error) error {
+    if len(b.queue) >= b.opts.Queue {
+        return ErrQueueFull
+    }
+    if ok, _ := b.opts.Limiter.Allow(m.User); !ok {
+        return ErrRateLimited
+    }
+    b.queue = append(b.queue, intervention{
+        user: m.User,
+        channel: m.Channel,
+        name: name,
+        apply: fn,
+    })
+    return nil
+}
+
+func (b *Bridge) Handle(m Message, reply Reply) {
+    if !strings.HasPrefix(m.Text, b.opts.Prefix) {
+        return
+    }
+    fields := strings.Fields(strings.TrimPrefix(m.Text, b.opts.Prefix))
+    if len(fields) == 0 {
+        return
+    }
+    b.channels[m.Channel] = true
+
+    cmd, args := strings.ToLower(fields[0]), fields[1:]
+
+    var err error
+    switch cmd {
+    case "seed", "nuke":
+        var fn func(e *tp.Env) error
+        if cmd == "seed" {
+            fn, err = b.seed(args)
+        } else {
+            fn, err = b.nuke(args)
+        }
+        if err == nil {
+            err = b.enqueue(m, cmd, fn)
+        }
+    case "vote":
+        if len(args) < 1 {
+            var names []string
+            for _, p := range tp.Presets() {
+                names = append(names, p.Name)
+            }
+            err = fmt.Errorf("vote for one of: %s", strings.Join(names, ", "))
+            break
+        }
+        if _, err = tp.LookupPreset(args[0]); err == nil {
+            b.votes[m.User] = args[0]
+        }
+    case "status":
+        reply(m.Channel, fmt.Sprintf("tick %d, %d live cells, %d queued",
+            b.env.Ticks(), b.env.LiveCount(), len(b.queue)))
+    case "help":
+        reply(m.Channel, fmt.Sprintf("%[1]sseed x y [genome], %[1]snuke x y [w h], %[1]svote preset, %[1]sstatus", b.opts.Prefix))
+    default:
+        return
+    }
+
+    if err != nil {
+        reply(m.Channel, fmt.Sprintf("@%s %v", m.User, err))
+    }
+}
+
+func (b *Bridge) step(reply Reply) {
+    if len(b.queue) == 0 {
+        return
+    }
+    iv := b.queue[0]
+    b.queue = b.queue[1:]
+
+    if err := iv.apply(b.env); err != nil {
+        reply(iv.channel, fmt.Sprintf("@%s %s failed: %v", iv.user, iv.name, err))
+    }
+}
+
+func (b *Bridge) tally() (string, int) {
+    counts := make(map[string]int)
+    for _, p := range b.votes {
+        counts[p]++
+    }
+    names := make([]string, 0, len(counts))
+    for p := range counts {
+        names = append(names, p)
+    }
+    sort.Strings(names)
+
+    var win string
+    var max int
+    for _, p := range names {
+        if counts[p] > max {
+            win, max = p, counts[p]
+        }
+    }
+    return win, max
+}
+
+func (b *Bridge) closeVote(reply Reply) {
+    win, n := b.tally()
+    b.votes = make(map[string]string)
+    if win == "" {
+        return
+    }
+
+    msg := fmt.Sprintf("vote closed: %s wins with %d", win, n)
+    p, err := tp.LookupPreset(win)
+    if err == nil {
+        err = b.env.ApplyPreset(p)
+    }
+    if err != nil {
+        msg = fmt.Sprintf("vote closed: %s failed: %v", win, err)
+    }
+    for ch := range b.channels {
+        reply(ch, msg)
+    }
+}
+
+func (b *Bridge) Run(msgs <-chan Message, reply Reply) {
+    pace := time.NewTicker(b.opts.Pace)
+    defer pace.Stop()
+    vote := time.NewTicker(b.opts.VoteWindow)
+    defer vote.Stop()
+
+    for {
+        select {
+        case m, ok := <-msgs:
+            if !ok {
+                return
+            }
+            b.Handle(m, reply)
+        case <-pace.C:
+            b.step(reply)
+        case <-vote.C:
+            b.closeVote(reply)
+        }
+    }
+}
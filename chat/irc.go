@@ -0,0 +1,166 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package chat
+
+import (
+    "bufio"
+    "crypto/tls"
+    "fmt"
+    "net"
+    "strings"
+    "sync"
+    "time"
+)
+
+const dialTimeout = 10 * time.Second
+
+type Options struct {
+    Nick string
+    Pass string
+    Channels []string
+    TLS bool
+}
+
+type Message struct {
+    User string
+    Channel string
+    Text string
+}
+
+type Client struct {
+    C <-chan Message
+
+    conn net.Conn
+    mutex *sync.Mutex
+    msgs chan Message
+    channels []string
+}
+
+func Dial(addr string, opts Options) (*Client, error) {
+    d := &net.Dialer{Timeout: dialTimeout}
+
+    var conn net.Conn
+    var err error
+    if opts.TLS {
+        conn, err = tls.DialWithDialer(d, "tcp", addr, nil)
+    } else {
+        conn, err = d.Dial("tcp", addr)
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    msgs := make(chan Message, 64)
+    c := &Client{
+        C: msgs,
+        conn: conn,
+        mutex: &sync.Mutex{},
+        msgs: msgs,
+        channels: opts.Channels,
+    }
+
+    if opts.Pass != "" {
+        c.send("PASS %s", opts.Pass)
+    }
+    c.send("NICK %s", opts.Nick)
+    if err := c.send("USER %s 0 * :%s", opts.Nick, opts.Nick); err != nil {
+        conn.Close()
+        return nil, err
+    }
+
+    go c.read()
+
+    return c, nil
+}
+
+func (c *Client) send(format string, args ...interface{}) error {
+    line := fmt.Sprintf(format, args...)
+    line = strings.NewReplacer("\r", " ", "\n", " ").Replace(line)
+
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    _, err := c.conn.Write([]byte(line + "\r\n"))
+    return err
+}
+
+func (c *Client) Join(channel string) error {
+    if !strings.HasPrefix(channel, "#") {
+        channel = "#" + channel
+    }
+    return c.send("JOIN %s", channel)
+}
+
+func (c *Client) Say(channel, text string) error {
+    return c.send("PRIVMSG %s :%s", channel, text)
+}
+
+func (c *Client) Close() error {
+    c.send("QUIT")
+    return c.conn.Close()
+}
+
+func parseLine(line string) (prefix, command string, params []string) {
+    if strings.HasPrefix(line, "@") {
+        if i := strings.IndexByte(line, ' '); i >= 0 {
+            line = line[i + 1:]
+        } else {
+            return
+        }
+    }
+    if strings.HasPrefix(line, ":") {
+        i := strings.IndexByte(line, ' ')
+        if i < 0 {
+            return
+        }
+        prefix, line = line[1:i], line[i + 1:]
+    }
+
+    var trailing string
+    hasTrailing := false
+    if i := strings.Index(line, " :"); i >= 0 {
+        line, trailing, hasTrailing = line[:i], line[i + 2:], true
+    }
+    fields := strings.Fields(line)
+    if len(fields) == 0 {
+        return
+    }
+    command, params = strings.ToUpper(fields[0]), fields[1:]
+    if hasTrailing {
+        params = append(params, trailing)
+    }
+    return
+}
+
+func (c *Client) read() {
+    defer close(c.msgs)
+
+    s := bufio.NewScanner(c.conn)
+    for s.Scan() {
+        prefix, command, params := parseLine(strings.TrimRight(s.Text(), "\r"))
+        switch command {
+        case "001":
+            for _, ch := range c.channels {
+                c.Join(ch)
+            }
+        case "PING":
+            pong := "PONG"
+            if len(params) > 0 {
+                pong += " :" + params[len(params) - 1]
+            }
+            c.send("%s", pong)
+        case "PRIVMSG":
+            if len(params) < 2 {
+                continue
+            }
+            user := prefix
+            if i := strings.IndexByte(user, '!'); i >= 0 {
+                user = user[:i]
+            }
+            c.msgs <- Message{
+                User: user,
+                Channel: params[0],
+                Text: params[1],
+            }
+        }
+    }
+}
@@ -0,0 +1,83 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package main
+
+import (
+    "flag"
+    "log"
+    "os"
+    "os/signal"
+    "strings"
+    "time"
+
+    "tidepool/chat"
+    "tidepool/cmd"
+    "tidepool/ratelimit"
+)
+
+func main() {
+    addr := flag.String("server", "irc.chat.twitch.tv:6697", "IRC server address")
+    useTLS := flag.Bool("tls", true, "Connect to the IRC server over TLS")
+    nick := flag.String("nick", "tidepool", "IRC nickname")
+    pass := flag.String("pass", "", "IRC password (oauth:token for Twitch)")
+    channels := flag.String("channels", "", "Comma-separated channels to join")
+    prefix := flag.String("prefix", "!", "Chat command prefix")
+    pace := flag.Duration("pace", 500 * time.Millisecond, "Delay between queued interventions")
+    voteWindow := flag.Duration("vote-window", time.Minute, "Preset voting window")
+    maxNuke := flag.Int("max-nuke", 16, "Maximum nuke region side")
+    rate := flag.Float64("rate", 0.2, "Interventions per second allowed per user")
+    burst := flag.Int("burst", 2, "Interventions a user may make at once")
+    quota := flag.Int("quota", 0, "Interventions allowed per user per quota window (0 for unlimited)")
+    quotaWindow := flag.Duration("quota-window", time.Hour, "Quota window")
+
+    env, dts := cmd.ParseAndRun()
+    go func() {
+        for range dts {
+        }
+    }()
+
+    var chs []string
+    for _, ch := range strings.Split(*channels, ",") {
+        if ch = strings.TrimSpace(ch); ch != "" {
+            chs = append(chs, ch)
+        }
+    }
+    if len(chs) == 0 {
+        log.Fatal("no channels given")
+    }
+
+    client, err := chat.Dial(*addr, chat.Options{
+        Nick: *nick,
+        Pass: *pass,
+        Channels: chs,
+        TLS: *useTLS,
+    })
+    if err != nil {
+        log.Fatal(err)
+    }
+    defer client.Close()
+
+    sig := make(chan os.Signal, 1)
+    signal.Notify(sig, os.Interrupt)
+    defer signal.Stop(sig)
+
+    go func() {
+        <-sig
+        env.Stop()
+        client.Close()
+    }()
+
+    bridge := chat.NewBridge(env, chat.BridgeOptions{
+        Prefix: *prefix,
+        Pace: *pace,
+        VoteWindow: *voteWindow,
+        MaxNuke: int32(*maxNuke),
+        Limiter: ratelimit.New(ratelimit.Quota{
+            Rate: *rate,
+            Burst: *burst,
+            Max: *quota,
+            Window: *quotaWindow,
+        }),
+    })
+    bridge.Run(client.C, client.Say)
+}
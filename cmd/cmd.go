@@ -2,28 +2,81 @@ package cmd
 
 import (
     "flag"
+    "fmt"
+    "os"
     "runtime"
     "time"
 
     tp "tidepool/tidepool"
 )
 
-func ParseAndRun() (*tp.Env, <-chan *tp.Delta) {
+func Parse() (*tp.Env, time.Duration) {
     w := flag.Int("width", 256, "Environment width")
     h := flag.Int("height", 256, "Environment height")
     g := flag.Int("genome", 1024, "Genome size")
     p := flag.Float64("pop", 0.01, "Initial population percent")
     s := flag.Int64("seed", -1, "Environment seed")
     t := flag.Duration("tick", time.Millisecond, "Clock tick frequency")
+    preset := flag.String("preset", "", "Named config preset (soup, predation, islands)")
+    vars := flag.Bool("expvar", false, "Publish core counters via expvar under petri.")
+    scenario := flag.String("scenario", "", "Path to a JSON scenario of timed interventions")
 
     flag.Parse()
 
     pop := int32(*p * float64(*w * *h))
     env := tp.NewEnv(int32(*w), int32(*h), int32(*g), pop, *s)
 
-    dts := make(chan *tp.Delta)
+    if *preset != "" {
+        ps, err := tp.LookupPreset(*preset)
+        if err == nil {
+            err = env.ApplyPreset(ps)
+        }
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(1)
+        }
+    }
+
+    if *scenario != "" {
+        s, err := tp.LoadScenario(*scenario)
+        if err == nil {
+            err = env.SetScenario(s)
+        }
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(1)
+        }
+    }
+
+    if *vars {
+        tp.PublishExpvar(env)
+    }
+
+    return env, *t
+}
+
+func ParseAndRun() (*tp.Env, <-chan *tp.Delta) {
+    deltaBuffer := flag.Int("delta-buffer", 0, "Worker delta queue capacity (default one per worker)")
+    outputBuffer := flag.Int("output-buffer", 0, "Capacity of the delta channel returned to consumers")
+    turbo := flag.Bool("turbo", false, "Advance ticks as fast as possible instead of at the tick frequency")
+    lockstep := flag.Bool("lockstep", false, "Execute each tick's cells in parallel and merge them in index order for reproducible runs")
+
+    env, t := Parse()
+
+    dts := make(chan *tp.Delta, *outputBuffer)
+
+    pacing := tp.PacingWallClock
+    if *turbo {
+        pacing = tp.PacingTurbo
+    }
 
-    go env.Run(runtime.NumCPU(), *t, dts)
+    go env.RunWith(tp.RunOptions{
+        Workers: runtime.NumCPU(),
+        Tick: t,
+        DeltaBuffer: *deltaBuffer,
+        Pacing: pacing,
+        Lockstep: *lockstep,
+    }, dts)
 
     return env, dts
 }
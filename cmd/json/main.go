@@ -4,16 +4,32 @@ package main
 
 import (
     "encoding/json"
+    "flag"
     "fmt"
     "os"
     "os/signal"
 
     "tidepool/cmd"
+    "tidepool/script"
+    tp "tidepool/tidepool"
 )
 
 func main() {
+    src := flag.String("script", "", "Starlark script with event handlers")
+
     env, dts := cmd.ParseAndRun()
 
+    if *src != "" {
+        s, err := script.Load(env, *src, nil)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(1)
+        }
+        out := make(chan *tp.Delta)
+        go s.Run(dts, out)
+        dts = out
+    }
+
     sig := make(chan os.Signal, 1)
     signal.Notify(sig, os.Interrupt)
     defer signal.Stop(sig)
@@ -0,0 +1,52 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package main
+
+import (
+    "flag"
+    "fmt"
+    "log"
+    "os"
+    "os/signal"
+    "time"
+
+    "tidepool/cmd"
+    "tidepool/mqtt"
+)
+
+func main() {
+    addr := flag.String("broker", "localhost:1883", "MQTT broker address")
+    id := flag.String("client", "tidepool", "MQTT client ID")
+    user := flag.String("user", "", "MQTT username")
+    pass := flag.String("password", "", "MQTT password")
+    prefix := flag.String("topic", "tidepool", "MQTT topic prefix")
+    update := flag.Duration("update", time.Second, "Stats publish frequency")
+
+    env, dts := cmd.ParseAndRun()
+
+    client, err := mqtt.Dial(*addr, mqtt.Options{
+        ClientID: *id,
+        Username: *user,
+        Password: *pass,
+        KeepAlive: time.Minute,
+    })
+    if err != nil {
+        log.Fatal(err)
+    }
+    defer client.Close()
+
+    sig := make(chan os.Signal, 1)
+    signal.Notify(sig, os.Interrupt)
+    defer signal.Stop(sig)
+
+    go func() {
+        <-sig
+        env.Stop()
+    }()
+
+    pub := mqtt.NewPublisher(client, mqtt.Topics{
+        Stats: fmt.Sprintf("%s/stats", *prefix),
+        Events: fmt.Sprintf("%s/events", *prefix),
+    })
+    pub.Run(dts, time.Tick(*update))
+}
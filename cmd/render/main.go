@@ -0,0 +1,155 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package main
+
+import (
+    "flag"
+    "fmt"
+    "image"
+    "image/png"
+    "io"
+    "io/ioutil"
+    "os"
+    "os/exec"
+    "path/filepath"
+
+    "tidepool/render"
+    "tidepool/store"
+    tp "tidepool/tidepool"
+)
+
+func scaleImage(img *image.RGBA, n int) *image.RGBA {
+    if n <= 1 {
+        return img
+    }
+    b := img.Bounds()
+    out := image.NewRGBA(image.Rect(0, 0, b.Dx() * n, b.Dy() * n))
+    for y := 0; y < b.Dy() * n; y++ {
+        for x := 0; x < b.Dx() * n; x++ {
+            out.SetRGBA(x, y, img.RGBAAt(x / n, y / n))
+        }
+    }
+    return out
+}
+
+func writeFrame(path string, img image.Image) error {
+    f, err := os.Create(path)
+    if err != nil {
+        return err
+    }
+    if err := png.Encode(f, img); err != nil {
+        f.Close()
+        return err
+    }
+    return f.Close()
+}
+
+func fail(err error) {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(1)
+}
+
+func main() {
+    snapshot := flag.String("snapshot", "", "World snapshot the deltas start from")
+    deltas := flag.String("deltas", "", "Recorded delta log (JSON lines)")
+    logDir := flag.String("log", "", "Directory of binary delta logs")
+    prefix := flag.String("prefix", "deltas", "Binary delta log file prefix")
+    every := flag.Int64("every", 100, "Ticks between frames")
+    out := flag.String("out", "frames", "Output directory for numbered PNGs")
+    scale := flag.Int("scale", 1, "Pixels per cell")
+    energy := flag.Bool("energy", false, "Color cells by energy instead of genome")
+    video := flag.String("video", "", "Pipe frames to ffmpeg and write this video file")
+    rate := flag.Int("rate", 30, "Video frame rate")
+
+    flag.Parse()
+
+    if *deltas == "" && *logDir == "" {
+        fail(fmt.Errorf("missing -deltas or -log"))
+    }
+    if *every < 1 {
+        *every = 1
+    }
+
+    var dts []*tp.Delta
+    var err error
+    if *logDir != "" {
+        dts, err = store.ReadDeltaLog(*logDir, *prefix)
+    } else {
+        var f *os.File
+        if f, err = os.Open(*deltas); err == nil {
+            dts, err = tp.ReadDeltas(f)
+            f.Close()
+        }
+    }
+    if err != nil {
+        fail(err)
+    }
+
+    var base []byte
+    if *snapshot != "" {
+        if base, err = ioutil.ReadFile(*snapshot); err != nil {
+            fail(err)
+        }
+    }
+
+    r, err := tp.NewReplay(base, dts)
+    if err != nil {
+        fail(err)
+    }
+
+    var m render.ColorMapper = render.GenomeColors{}
+    if *energy {
+        m = render.EnergyColors{}
+    }
+
+    var pipe io.WriteCloser
+    var cmd *exec.Cmd
+    if *video != "" {
+        cmd = exec.Command("ffmpeg", "-y", "-loglevel", "error",
+            "-f", "image2pipe", "-framerate", fmt.Sprint(*rate), "-i", "-",
+            "-pix_fmt", "yuv420p", *video)
+        cmd.Stderr = os.Stderr
+        if pipe, err = cmd.StdinPipe(); err != nil {
+            fail(err)
+        }
+        if err := cmd.Start(); err != nil {
+            fail(err)
+        }
+    } else if err := os.MkdirAll(*out, 0755); err != nil {
+        fail(err)
+    }
+
+    frames := 0
+    next := r.Tick()
+    emit := func() {
+        img := scaleImage(render.Image(r.Env(), m), *scale)
+        if pipe != nil {
+            err = png.Encode(pipe, img)
+        } else {
+            err = writeFrame(filepath.Join(*out, fmt.Sprintf("%06d.png", frames)), img)
+        }
+        if err != nil {
+            fail(err)
+        }
+        frames++
+    }
+
+    for {
+        if r.Tick() >= next {
+            emit()
+            next = r.Tick() - r.Tick() % *every + *every
+        }
+        if !r.Step() {
+            break
+        }
+    }
+
+    if pipe != nil {
+        pipe.Close()
+        if err := cmd.Wait(); err != nil {
+            fail(err)
+        }
+    }
+
+    fmt.Printf("wrote %d frames\n", frames)
+}
@@ -0,0 +1,541 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package main
+
+import (
+    "bufio"
+    "encoding/json"
+    "errors"
+    "flag"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "runtime"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "tidepool/cmd"
+    tp "tidepool/tidepool"
+    "tidepool/tidepool/gene"
+)
+
+type repl struct {
+    env *tp.Env
+    tick time.Duration
+    mutex *sync.Mutex
+    stats tp.Stats
+    done chan struct{}
+}
+
+type command struct {
+    usage string
+    fn func(*repl, []string) error
+}
+
+var commands map[string]command
+
+func init() {
+    commands = map[string]command{
+        "help": {"help", (*repl).help},
+        "step": {"step [n]", (*repl).step},
+        "run": {"run", (*repl).run},
+        "stop": {"stop", (*repl).stop},
+        "cell": {"cell x y", (*repl).cell},
+        "dis": {"dis x y", (*repl).dis},
+        "inspect": {"inspect x y", (*repl).inspect},
+        "trace": {"trace x y", (*repl).trace},
+        "break": {"break x y idx", (*repl).setBreak},
+        "unbreak": {"unbreak x y idx", (*repl).unbreak},
+        "breaks": {"breaks", (*repl).breaks},
+        "inject": {"inject x y genome [energy]", (*repl).inject},
+        "stats": {"stats", (*repl).printStats},
+        "mem": {"mem", (*repl).mem},
+        "config": {"config [json]", (*repl).config},
+        "save": {"save file", (*repl).save},
+        "load": {"load file", (*repl).load},
+        "export": {"export file.npz|file.npy [array]", (*repl).export},
+        "discoveries": {"discoveries", (*repl).discoveries},
+        "interactions": {"interactions [file.dot|file.graphml]", (*repl).interactions},
+    }
+}
+
+func parseInts(args []string, n int) ([]int64, error) {
+    if len(args) < n {
+        return nil, errors.New("not enough arguments")
+    }
+    vs := make([]int64, n)
+    for i := range vs {
+        v, err := strconv.ParseInt(args[i], 10, 64)
+        if err != nil {
+            return nil, err
+        }
+        vs[i] = v
+    }
+    return vs, nil
+}
+
+func (r *repl) running() bool {
+    return r.done != nil
+}
+
+func (r *repl) addStats(s tp.Stats) {
+    r.mutex.Lock()
+    r.stats.Add(s)
+    r.mutex.Unlock()
+}
+
+func (r *repl) help(args []string) error {
+    names := make([]string, 0, len(commands))
+    for n := range commands {
+        names = append(names, n)
+    }
+    sort.Strings(names)
+    for _, n := range names {
+        fmt.Println(commands[n].usage)
+    }
+    fmt.Println("quit")
+    return nil
+}
+
+func (r *repl) step(args []string) error {
+    if r.running() {
+        return errors.New("world is running")
+    }
+
+    n := int64(1)
+    if len(args) > 0 {
+        vs, err := parseInts(args, 1)
+        if err != nil {
+            return err
+        }
+        n = vs[0]
+    }
+
+    for i := int64(0); i < n; i++ {
+        for _, dt := range r.env.Advance() {
+            r.addStats(dt.Stats)
+        }
+    }
+
+    return nil
+}
+
+func (r *repl) run(args []string) error {
+    if r.running() {
+        return errors.New("world is running")
+    }
+
+    dts := make(chan *tp.Delta)
+    done := make(chan struct{})
+    r.done = done
+
+    go r.env.Run(runtime.NumCPU(), r.tick, dts)
+    go func() {
+        defer close(done)
+        for dt := range dts {
+            r.addStats(dt.Stats)
+        }
+    }()
+
+    return nil
+}
+
+func (r *repl) stop(args []string) error {
+    if !r.running() {
+        return errors.New("world is not running")
+    }
+
+    r.env.Stop()
+    <-r.done
+    r.done = nil
+
+    return nil
+}
+
+func (r *repl) getCell(args []string) (*tp.Cell, error) {
+    vs, err := parseInts(args, 2)
+    if err != nil {
+        return nil, err
+    }
+    x, y := int32(vs[0]), int32(vs[1])
+    if x < 0 || y < 0 || x >= r.env.Width || y >= r.env.Height {
+        return nil, errors.New("cell out of bounds")
+    }
+    return r.env.GetCell(x, y), nil
+}
+
+func (r *repl) cell(args []string) error {
+    c, err := r.getCell(args)
+    if err != nil {
+        return err
+    }
+    fmt.Printf("id=%d origin=%d parent=%d generation=%d energy=%d\n",
+        c.ID, c.Origin, c.Parent, c.Generation, c.Energy)
+    fmt.Println(c.Genome)
+    return nil
+}
+
+func (r *repl) dis(args []string) error {
+    c, err := r.getCell(args)
+    if err != nil {
+        return err
+    }
+    fmt.Print(c.Genome.Disassemble())
+    return nil
+}
+
+func (r *repl) inspect(args []string) error {
+    c, err := r.getCell(args)
+    if err != nil {
+        return err
+    }
+    in := r.env.Inspect(c.X, c.Y)
+    c = in.Cell
+
+    fmt.Printf("id=%d origin=%d parent=%d generation=%d energy=%d live=%v viable=%v\n",
+        c.ID, c.Origin, c.Parent, c.Generation, c.Energy, in.Live, in.Viable)
+    fmt.Printf("lineage: relatives=%d mutations=%d rate=%g\n",
+        in.Lineage.Relatives, in.Lineage.Mutations, in.Lineage.SubstitutionRate)
+    for dir, n := range in.Neighbors {
+        fmt.Printf("neighbor %s: id=%d energy=%d\n",
+            []string{"left", "right", "up", "down"}[dir], n.ID, n.Energy)
+    }
+    for _, ev := range in.Events {
+        fmt.Printf("event %s tick=%d\n", ev.Type, ev.Tick)
+    }
+    if b := in.Break; b != nil {
+        fmt.Printf("break at %d tick=%d: %s reg=%s ptr=%d dir=%d loop=%d energy=%d\n",
+            b.GenomeIdx, b.Tick, b.State.Op, b.State.Register, b.State.Pointer,
+            b.State.Direction, b.LoopDepth, b.State.Energy)
+        fmt.Printf("buffer=%s\n", b.Buffer)
+    }
+    if p := in.Phenotype; p != nil {
+        fmt.Printf("phenotype: %s execs=%d replications=%d fidelity=%.2f kills=%d shares=%d moves=%d\n",
+            p.Class, p.Execs, p.Replications, p.Fidelity, p.Kills, p.Shares, p.Moves)
+    }
+    fmt.Print(in.Disassembly)
+    return nil
+}
+
+func (r *repl) trace(args []string) error {
+    c, err := r.getCell(args)
+    if err != nil {
+        return err
+    }
+    if c.ID == 0 {
+        return errors.New("cell is dead")
+    }
+
+    type result struct {
+        t tp.Trace
+        err error
+    }
+    ch := make(chan result, 1)
+    go func() {
+        t, err := r.env.TraceNextExec(c.ID)
+        ch <- result{t, err}
+    }()
+
+    var res result
+    for done := false; !done; {
+        select {
+        case res = <-ch:
+            done = true
+        default:
+            if r.running() {
+                res = <-ch
+                done = true
+                break
+            }
+            for _, dt := range r.env.Advance() {
+                r.addStats(dt.Stats)
+            }
+        }
+    }
+    if res.err != nil {
+        return res.err
+    }
+
+    t := res.t
+    fmt.Printf("id=%d tick=%d at %d,%d\n", t.CellID, t.Tick, t.X, t.Y)
+    for _, s := range t.Steps {
+        flags := ""
+        if s.Mutated {
+            flags += "*"
+        }
+        if s.Skipped {
+            flags += "-"
+        }
+        fmt.Printf("%4d %-7s%-2s reg=%s ptr=%d dir=%d energy=%d %s\n",
+            s.GenomeIdx, s.Op, flags, s.Register, s.Pointer, s.Direction,
+            s.Energy, s.Effect)
+    }
+    fmt.Printf("energy=%d dormant=%v buffer=%s\n", t.Energy, t.Dormant, t.Buffer)
+    if t.Child != nil {
+        fmt.Printf("child id=%d at %d,%d\n", t.Child.ID, t.Child.X, t.Child.Y)
+    }
+
+    return nil
+}
+
+func (r *repl) breakpoint(args []string) (int64, int32, error) {
+    c, err := r.getCell(args)
+    if err != nil {
+        return 0, 0, err
+    }
+    if len(args) < 3 {
+        return 0, 0, errors.New("missing genome index")
+    }
+    idx, err := strconv.ParseInt(args[2], 10, 32)
+    if err != nil {
+        return 0, 0, err
+    }
+    return c.ID, int32(idx), nil
+}
+
+func (r *repl) setBreak(args []string) error {
+    id, idx, err := r.breakpoint(args)
+    if err != nil {
+        return err
+    }
+    return r.env.SetBreakpoint(id, idx)
+}
+
+func (r *repl) unbreak(args []string) error {
+    id, idx, err := r.breakpoint(args)
+    if err != nil {
+        return err
+    }
+    r.env.ClearBreakpoint(id, idx)
+    return nil
+}
+
+func (r *repl) breaks(args []string) error {
+    for _, b := range r.env.Breakpoints() {
+        fmt.Printf("cell %d at %d\n", b.CellID, b.GenomeIdx)
+    }
+    if b, ok := r.env.LastBreak(); ok {
+        fmt.Printf("last hit: cell %d at %d tick=%d paused=%v\n",
+            b.CellID, b.GenomeIdx, b.Tick, r.env.Paused())
+    }
+    return nil
+}
+
+func (r *repl) inject(args []string) error {
+    vs, err := parseInts(args, 2)
+    if err != nil {
+        return err
+    }
+    if len(args) < 3 {
+        return errors.New("missing genome")
+    }
+    g, err := gene.ParseGenome(args[2])
+    if err != nil {
+        return err
+    }
+
+    energy := int64(1000)
+    if len(args) > 3 {
+        if energy, err = strconv.ParseInt(args[3], 10, 64); err != nil {
+            return err
+        }
+    }
+
+    c, err := r.env.InjectCell(int32(vs[0]), int32(vs[1]), g, energy)
+    if err != nil {
+        return err
+    }
+    fmt.Printf("injected cell %d\n", c.ID)
+
+    return nil
+}
+
+func (r *repl) printStats(args []string) error {
+    r.mutex.Lock()
+    defer r.mutex.Unlock()
+
+    names := make([]string, 0, len(r.stats))
+    for n := range r.stats {
+        names = append(names, n)
+    }
+    sort.Strings(names)
+    for _, n := range names {
+        fmt.Printf("%s: %d\n", n, r.stats[n])
+    }
+
+    return nil
+}
+
+func (r *repl) mem(args []string) error {
+    m := r.env.MemoryUsage()
+    fmt.Printf("grid: %d\ngenomes: %d\nindexes: %d\nhistory: %d\ntotal: %d\n",
+        m.Grid, m.Genomes, m.Indexes, m.History, m.Total)
+    if m.HistoryBudget > 0 {
+        fmt.Printf("history budget: %d (evicted %d)\n", m.HistoryBudget, m.HistoryEvicted)
+    }
+    return nil
+}
+
+func (r *repl) config(args []string) error {
+    config := r.env.GetConfig()
+    if len(args) > 0 {
+        if err := json.Unmarshal([]byte(strings.Join(args, " ")), &config); err != nil {
+            return err
+        }
+        r.env.SetConfig(config)
+    }
+
+    js, err := json.MarshalIndent(config, "", "  ")
+    if err != nil {
+        return err
+    }
+    fmt.Println(string(js))
+
+    return nil
+}
+
+func (r *repl) save(args []string) error {
+    if len(args) < 1 {
+        return errors.New("missing file")
+    }
+    data, err := r.env.MarshalBinary()
+    if err != nil {
+        return err
+    }
+    return ioutil.WriteFile(args[0], data, 0644)
+}
+
+func (r *repl) export(args []string) error {
+    if len(args) < 1 {
+        return errors.New("missing file")
+    }
+    f, err := os.Create(args[0])
+    if err != nil {
+        return err
+    }
+
+    if strings.HasSuffix(args[0], ".npy") {
+        name := tp.ArrayGenomes
+        if len(args) > 1 {
+            name = args[1]
+        }
+        err = r.env.ExportNpy(f, name)
+    } else {
+        err = r.env.ExportNpz(f, true)
+    }
+    if err != nil {
+        f.Close()
+        return err
+    }
+    return f.Close()
+}
+
+func (r *repl) discoveries(args []string) error {
+    for _, d := range r.env.Discoveries() {
+        fmt.Printf("tick %d: %s count=%d fidelity=%.2f %s\n",
+            d.Tick, d.Signature, d.Count, d.Phenotype.Fidelity, d.Genome)
+    }
+    return nil
+}
+
+func (r *repl) interactions(args []string) error {
+    edges := r.env.InteractionGraph()
+    if len(args) < 1 {
+        for _, e := range edges {
+            fmt.Printf("%d -%s-> %d x%d\n", e.From, e.Kind, e.To, e.Count)
+        }
+        return nil
+    }
+
+    f, err := os.Create(args[0])
+    if err != nil {
+        return err
+    }
+    if strings.HasSuffix(args[0], ".graphml") {
+        err = tp.WriteInteractionGraphML(f, edges)
+    } else {
+        err = tp.WriteInteractionDOT(f, edges)
+    }
+    if err != nil {
+        f.Close()
+        return err
+    }
+    return f.Close()
+}
+
+func (r *repl) load(args []string) error {
+    if len(args) < 1 {
+        return errors.New("missing file")
+    }
+    if r.running() {
+        return errors.New("world is running")
+    }
+
+    data, err := ioutil.ReadFile(args[0])
+    if err != nil {
+        return err
+    }
+    env := &tp.Env{}
+    if err := env.UnmarshalBinary(data); err != nil {
+        return err
+    }
+
+    r.env = env
+    r.mutex.Lock()
+    r.stats = make(tp.Stats)
+    r.mutex.Unlock()
+
+    return nil
+}
+
+func main() {
+    load := flag.String("load", "", "Load world snapshot")
+
+    env, tick := cmd.Parse()
+
+    r := &repl{
+        env: env,
+        tick: tick,
+        mutex: &sync.Mutex{},
+        stats: make(tp.Stats),
+    }
+
+    if *load != "" {
+        if err := r.load([]string{*load}); err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(1)
+        }
+    }
+
+    s := bufio.NewScanner(os.Stdin)
+    for {
+        fmt.Print("> ")
+        if !s.Scan() {
+            break
+        }
+
+        args := strings.Fields(s.Text())
+        if len(args) == 0 {
+            continue
+        }
+        if args[0] == "quit" || args[0] == "exit" {
+            break
+        }
+
+        c, ok := commands[args[0]]
+        if !ok {
+            fmt.Printf("unknown command: %s\n", args[0])
+            continue
+        }
+        if err := c.fn(r, args[1:]); err != nil {
+            fmt.Printf("error: %v\n", err)
+        }
+    }
+
+    if r.running() {
+        r.stop(nil)
+    }
+}
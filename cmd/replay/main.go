@@ -0,0 +1,307 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package main
+
+import (
+    "bufio"
+    "errors"
+    "flag"
+    "fmt"
+    "image/png"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "tidepool/render"
+    "tidepool/store"
+    tp "tidepool/tidepool"
+)
+
+type player struct {
+    replay *tp.Replay
+    mutex *sync.Mutex
+    cols int
+    fps int
+    done chan struct{}
+}
+
+type command struct {
+    usage string
+    fn func(*player, []string) error
+}
+
+var commands map[string]command
+
+func init() {
+    commands = map[string]command{
+        "help": {"help", (*player).help},
+        "play": {"play [fps]", (*player).play},
+        "pause": {"pause", (*player).pause},
+        "step": {"step [n]", (*player).step},
+        "seek": {"seek n", (*player).seek},
+        "tick": {"tick t", (*player).tick},
+        "show": {"show", (*player).show},
+        "frame": {"frame file", (*player).frame},
+    }
+}
+
+func parseInt(args []string, def int) (int, error) {
+    if len(args) == 0 {
+        return def, nil
+    }
+    return strconv.Atoi(args[0])
+}
+
+func (p *player) playing() bool {
+    return p.done != nil
+}
+
+func (p *player) help(args []string) error {
+    names := make([]string, 0, len(commands))
+    for n := range commands {
+        names = append(names, n)
+    }
+    sort.Strings(names)
+    for _, n := range names {
+        fmt.Println(commands[n].usage)
+    }
+    fmt.Println("quit")
+    return nil
+}
+
+func (p *player) text() string {
+    e := p.replay.Env()
+    scale := (e.Width + int32(p.cols) - 1) / int32(p.cols)
+    if scale < 1 {
+        scale = 1
+    }
+
+    var sb strings.Builder
+    fmt.Fprintf(&sb, "delta %d/%d tick %d live %d\n",
+        p.replay.Pos(), p.replay.Len(), p.replay.Tick(), e.LiveCount())
+    for y := int32(0); y < e.Height; y += scale * 2 {
+        for x := int32(0); x < e.Width; x += scale {
+            live := false
+            for dy := int32(0); dy < scale * 2 && !live; dy++ {
+                for dx := int32(0); dx < scale && !live; dx++ {
+                    live = e.IsLive(x + dx, y + dy)
+                }
+            }
+            if live {
+                sb.WriteByte('#')
+            } else {
+                sb.WriteByte(' ')
+            }
+        }
+        sb.WriteByte('\n')
+    }
+
+    return sb.String()
+}
+
+func (p *player) show(args []string) error {
+    p.mutex.Lock()
+    defer p.mutex.Unlock()
+    fmt.Print(p.text())
+    return nil
+}
+
+func (p *player) play(args []string) error {
+    if p.playing() {
+        return errors.New("already playing")
+    }
+    fps, err := parseInt(args, p.fps)
+    if err != nil {
+        return err
+    }
+    if fps < 1 {
+        return errors.New("fps must be positive")
+    }
+
+    done := make(chan struct{})
+    p.done = done
+
+    go func() {
+        t := time.NewTicker(time.Second / time.Duration(fps))
+        defer t.Stop()
+        for {
+            select {
+            case <-done:
+                return
+            case <-t.C:
+                p.mutex.Lock()
+                ok := p.replay.Step()
+                if ok {
+                    fmt.Print("\033[H\033[2J", p.text())
+                }
+                p.mutex.Unlock()
+                if !ok {
+                    return
+                }
+            }
+        }
+    }()
+
+    return nil
+}
+
+func (p *player) pause(args []string) error {
+    if !p.playing() {
+        return errors.New("not playing")
+    }
+    close(p.done)
+    p.done = nil
+    return nil
+}
+
+func (p *player) step(args []string) error {
+    n, err := parseInt(args, 1)
+    if err != nil {
+        return err
+    }
+
+    p.mutex.Lock()
+    defer p.mutex.Unlock()
+
+    return p.replay.Seek(p.replay.Pos() + n)
+}
+
+func (p *player) seek(args []string) error {
+    if len(args) < 1 {
+        return errors.New("missing position")
+    }
+    n, err := parseInt(args, 0)
+    if err != nil {
+        return err
+    }
+
+    p.mutex.Lock()
+    defer p.mutex.Unlock()
+
+    return p.replay.Seek(n)
+}
+
+func (p *player) tick(args []string) error {
+    if len(args) < 1 {
+        return errors.New("missing tick")
+    }
+    t, err := strconv.ParseInt(args[0], 10, 64)
+    if err != nil {
+        return err
+    }
+
+    p.mutex.Lock()
+    defer p.mutex.Unlock()
+
+    return p.replay.SeekTick(t)
+}
+
+func (p *player) frame(args []string) error {
+    if len(args) < 1 {
+        return errors.New("missing file")
+    }
+
+    p.mutex.Lock()
+    img := render.Image(p.replay.Env(), render.DefaultColors)
+    p.mutex.Unlock()
+
+    if err := os.MkdirAll(filepath.Dir(args[0]), 0755); err != nil {
+        return err
+    }
+    f, err := os.Create(args[0])
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+
+    return png.Encode(f, img)
+}
+
+func readDeltas(file, dir, prefix string) ([]*tp.Delta, error) {
+    if dir != "" {
+        return store.ReadDeltaLog(dir, prefix)
+    }
+    f, err := os.Open(file)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    return tp.ReadDeltas(f)
+}
+
+func main() {
+    file := flag.String("file", "", "Recorded delta log (JSON lines)")
+    logDir := flag.String("log", "", "Directory of binary delta logs")
+    prefix := flag.String("prefix", "deltas", "Binary delta log file prefix")
+    snapshot := flag.String("snapshot", "", "World snapshot the log starts from")
+    cols := flag.Int("cols", 80, "Terminal columns used for rendering")
+    fps := flag.Int("fps", 10, "Playback frames per second")
+
+    flag.Parse()
+
+    if *file == "" && *logDir == "" {
+        fmt.Fprintln(os.Stderr, "missing -file or -log")
+        os.Exit(1)
+    }
+
+    dts, err := readDeltas(*file, *logDir, *prefix)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+    }
+
+    var base []byte
+    if *snapshot != "" {
+        if base, err = ioutil.ReadFile(*snapshot); err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(1)
+        }
+    }
+
+    r, err := tp.NewReplay(base, dts)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+    }
+
+    p := &player{
+        replay: r,
+        mutex: &sync.Mutex{},
+        cols: *cols,
+        fps: *fps,
+    }
+
+    s := bufio.NewScanner(os.Stdin)
+    for {
+        fmt.Print("> ")
+        if !s.Scan() {
+            break
+        }
+
+        args := strings.Fields(s.Text())
+        if len(args) == 0 {
+            continue
+        }
+        if args[0] == "quit" || args[0] == "exit" {
+            break
+        }
+
+        c, ok := commands[args[0]]
+        if !ok {
+            fmt.Printf("unknown command: %s\n", args[0])
+            continue
+        }
+        if err := c.fn(p, args[1:]); err != nil {
+            fmt.Printf("error: %v\n", err)
+        }
+    }
+
+    if p.playing() {
+        p.pause(nil)
+    }
+}
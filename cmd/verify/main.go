@@ -0,0 +1,72 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "os"
+    "runtime"
+
+    tp "tidepool/tidepool"
+)
+
+func fail(err error) {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(1)
+}
+
+func main() {
+    name := flag.String("vector", "", "Verify only the named golden vector")
+    maxTick := flag.Int64("max-tick", 0, "Skip checkpoints past this tick (0 verifies all)")
+    record := flag.Bool("record", false, "Print freshly computed vectors as JSON instead of verifying")
+
+    flag.Parse()
+
+    vs := tp.GoldenVectors()
+    if *name != "" {
+        v, err := tp.LookupGoldenVector(*name)
+        if err != nil {
+            fail(err)
+        }
+        vs = []tp.GoldenVector{v}
+    }
+
+    if *record {
+        for i, v := range vs {
+            r, err := v.Record()
+            if err != nil {
+                fail(err)
+            }
+            vs[i] = r
+        }
+        enc := json.NewEncoder(os.Stdout)
+        enc.SetIndent("", "  ")
+        if err := enc.Encode(vs); err != nil {
+            fail(err)
+        }
+        return
+    }
+
+    fmt.Printf("%s/%s %s\n", runtime.GOOS, runtime.GOARCH, runtime.Version())
+
+    failed := false
+    for _, v := range vs {
+        rs, err := v.Verify(*maxTick)
+        for _, r := range rs {
+            status := "ok"
+            if !r.OK() {
+                status = "MISMATCH"
+            }
+            fmt.Printf("%-12s tick %-7d %016x %s\n", v.Name, r.Tick, r.Got, status)
+        }
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            failed = true
+        }
+    }
+    if failed {
+        os.Exit(1)
+    }
+}
@@ -0,0 +1,79 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+    "encoding/json"
+    "syscall/js"
+
+    tp "tidepool/tidepool"
+    "tidepool/tidepool/gene"
+)
+
+var env *tp.Env
+
+func toJS(v interface{}) interface{} {
+    js, err := json.Marshal(v)
+    if err != nil {
+        return errorJS(err)
+    }
+    return string(js)
+}
+
+func errorJS(err error) interface{} {
+    return js.Global().Get("Error").New(err.Error())
+}
+
+func newEnv(this js.Value, args []js.Value) interface{} {
+    env = tp.NewEnv(int32(args[0].Int()), int32(args[1].Int()),
+        int32(args[2].Int()), int32(args[3].Int()), int64(args[4].Int()))
+    return nil
+}
+
+func step(this js.Value, args []js.Value) interface{} {
+    n := 1
+    if len(args) > 0 {
+        n = args[0].Int()
+    }
+
+    var dts []*tp.Delta
+    for i := 0; i < n; i++ {
+        dts = append(dts, env.Advance()...)
+    }
+
+    return toJS(dts)
+}
+
+func getRegion(this js.Value, args []js.Value) interface{} {
+    return toJS(env.GetRegion(int32(args[0].Int()), int32(args[1].Int()),
+        int32(args[2].Int()), int32(args[3].Int())))
+}
+
+func injectCell(this js.Value, args []js.Value) interface{} {
+    g, err := gene.ParseGenome(args[2].String())
+    if err != nil {
+        return errorJS(err)
+    }
+
+    c, err := env.InjectCell(int32(args[0].Int()), int32(args[1].Int()), g,
+        int64(args[3].Int()))
+    if err != nil {
+        return errorJS(err)
+    }
+
+    return toJS(c)
+}
+
+func main() {
+    js.Global().Set("tidepool", js.ValueOf(map[string]interface{}{
+        "newEnv": js.FuncOf(newEnv),
+        "step": js.FuncOf(step),
+        "getRegion": js.FuncOf(getRegion),
+        "injectCell": js.FuncOf(injectCell),
+    }))
+
+    select {}
+}
@@ -8,18 +8,16 @@ import (
     "net/http"
     _ "net/http/pprof"
     "runtime"
-    "text/template"
+    "strings"
     "time"
 
     "tidepool/cmd"
+    "tidepool/ratelimit"
+    "tidepool/store"
+    tp "tidepool/tidepool"
     "tidepool/web"
 )
 
-type Index struct {
-    Host string
-    Scale int
-}
-
 func init() {
     runtime.SetBlockProfileRate(1)
     runtime.SetMutexProfileFraction(1)
@@ -28,30 +26,98 @@ func init() {
 func main() {
     update := flag.Duration("update", time.Second, "Delta update frequency")
     addr := flag.String("addr", ":3000", "http service address")
-    index := flag.String("index", "index.html", "Path to html index file")
+    index := flag.String("index", "", "Path to html index file (default embedded)")
     scale := flag.Int("scale", 1, "Scale of cell visualization")
+    buffer := flag.Int("buffer", 4096, "Delta buffer size before dropping")
+    keyframe := flag.Int64("keyframe", 0, "Deltas between keyframes")
+    stall := flag.Duration("stall", 10 * time.Second, "Time without tick progress before /healthz fails")
+    checkpointDir := flag.String("checkpoint", "", "Directory to write periodic checkpoints to")
+    checkpointEvery := flag.Duration("checkpoint-every", time.Minute, "Checkpoint frequency")
+    multi := flag.Bool("multi", false, "Serve additional named envs under /envs")
+    maxEnvs := flag.Int("max-envs", 0, "Maximum number of named envs (0 for unlimited)")
+    observerTokens := flag.String("observer-tokens", "", "Comma-separated tokens allowed read-only access")
+    operatorTokens := flag.String("operator-tokens", "", "Comma-separated tokens allowed to change the env")
+    public := flag.Bool("public", false, "Allow read-only access without a token when tokens are set")
+    rate := flag.Float64("rate", 0, "Interventions per second allowed per client (0 for unlimited)")
+    burst := flag.Int("burst", 5, "Interventions a client may make at once before rate limiting")
+    quota := flag.Int("quota", 0, "Interventions allowed per client per quota window (0 for unlimited)")
+    quotaWindow := flag.Duration("quota-window", time.Hour, "Quota window")
 
     env, dts := cmd.ParseAndRun()
     defer env.Stop()
 
-    conn := web.NewConn(env, dts, time.Tick(*update))
+    hub := tp.NewHub(env, dts)
+    sub := hub.Subscribe(tp.SubscribeOptions{
+        Policy: tp.PolicyDropOldest,
+        Buffer: *buffer,
+        KeyframeEvery: *keyframe,
+    })
+    go hub.Run()
+
+    conn := web.NewConn(env, sub.C, time.Tick(*update))
     defer conn.Close()
+    conn.SetStallTimeout(*stall)
 
-    http.HandleFunc("/ws", conn.WebsocketHandler)
-    http.HandleFunc("/env", conn.EnvHandler)
+    if *checkpointDir != "" {
+        s, err := store.NewDiskStore(*checkpointDir)
+        if err != nil {
+            log.Fatal(err)
+        }
+        cp := &store.Checkpointer{Store: s, Env: env, Prefix: "checkpoint-"}
+        conn.SetCheckpointStatus(cp)
 
-    indexTemp := template.Must(template.ParseFiles(*index))
+        done := make(chan struct{})
+        defer close(done)
+        go cp.Run(time.Tick(*checkpointEvery), done)
+    }
 
-    http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-        indexTemp.Execute(w, Index{
-            Host: r.Host,
+    conn.Register(http.DefaultServeMux)
+
+    if *multi {
+        server := web.NewServer(web.ServerOptions{
+            Update: *update,
+            Buffer: *buffer,
+            MaxEnvs: *maxEnvs,
+            Index: *index,
             Scale: *scale,
         })
-    })
+        defer server.Close()
+        server.Register(http.DefaultServeMux)
+    }
+
+    indexHandler, err := web.IndexHandler(*index, *scale)
+    if err != nil {
+        log.Fatal(err)
+    }
+    http.HandleFunc("/", indexHandler)
 
     go conn.Run()
 
-    if err := http.ListenAndServe(*addr, nil); err != nil {
+    auth := &web.Auth{
+        Observers: splitTokens(*observerTokens),
+        Operators: splitTokens(*operatorTokens),
+        Public: *public,
+    }
+
+    limiter := ratelimit.New(ratelimit.Quota{
+        Rate: *rate,
+        Burst: *burst,
+        Max: *quota,
+        Window: *quotaWindow,
+    })
+    handler := auth.Wrap(web.Limit(limiter, http.DefaultServeMux))
+
+    if err := http.ListenAndServe(*addr, handler); err != nil {
         log.Fatal(err)
     }
 }
+
+func splitTokens(s string) []string {
+    var ts []string
+    for _, t := range strings.Split(s, ",") {
+        if t = strings.TrimSpace(t); t != "" {
+            ts = append(ts, t)
+        }
+    }
+    return ts
+}
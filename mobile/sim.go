@@ -0,0 +1,211 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package mobile
+
+import (
+    "context"
+    "errors"
+    "runtime"
+    "sync"
+    "time"
+
+    "tidepool/render"
+    tp "tidepool/tidepool"
+    "tidepool/tidepool/gene"
+)
+
+type Sim struct {
+    env *tp.Env
+    mutex *sync.Mutex
+    stats tp.Stats
+    cancel context.CancelFunc
+    done chan struct{}
+}
+
+type CellInfo struct {
+    X int32
+    Y int32
+    ID int64
+    Origin int64
+    Parent int64
+    Generation int64
+    Energy int64
+    Genome string
+}
+
+type StatsInfo struct {
+    Ticks int64
+    LiveCells int64
+    ViableLiveCells int64
+    Reproductions int64
+    Mutations int64
+    MaxGeneration int64
+}
+
+func newSim(e *tp.Env) *Sim {
+    return &Sim{
+        env: e,
+        mutex: &sync.Mutex{},
+        stats: make(tp.Stats),
+    }
+}
+
+func NewSim(width, height, genomeSize int32, pop float64, seed int64) *Sim {
+    p := int32(pop * float64(width * height))
+    return newSim(tp.NewEnv(width, height, genomeSize, p, seed))
+}
+
+func LoadSim(data []byte) (*Sim, error) {
+    e := &tp.Env{}
+    if err := e.UnmarshalBinary(data); err != nil {
+        return nil, err
+    }
+    return newSim(e), nil
+}
+
+func (s *Sim) Snapshot() ([]byte, error) {
+    return s.env.MarshalBinary()
+}
+
+func (s *Sim) Width() int32 {
+    return s.env.Width
+}
+
+func (s *Sim) Height() int32 {
+    return s.env.Height
+}
+
+func (s *Sim) Running() bool {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+    return s.done != nil
+}
+
+func (s *Sim) Start(tickMillis int64) {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    if s.done != nil {
+        return
+    }
+
+    ctx, cancel := context.WithCancel(context.Background())
+    dts := make(chan *tp.Delta)
+    consumed := make(chan struct{})
+    done := make(chan struct{})
+    s.cancel = cancel
+    s.done = done
+
+    go func() {
+        defer close(consumed)
+        for dt := range dts {
+            s.mutex.Lock()
+            s.stats.Add(dt.Stats)
+            s.mutex.Unlock()
+        }
+    }()
+    go func() {
+        defer close(done)
+        s.env.RunWith(tp.RunOptions{
+            Workers: runtime.NumCPU(),
+            Tick: time.Duration(tickMillis) * time.Millisecond,
+            Context: ctx,
+        }, dts)
+        <-consumed
+    }()
+}
+
+func (s *Sim) Stop() {
+    s.mutex.Lock()
+    done, cancel := s.done, s.cancel
+    s.mutex.Unlock()
+
+    if done == nil {
+        return
+    }
+
+    cancel()
+    <-done
+
+    s.mutex.Lock()
+    if s.done == done {
+        s.done = nil
+        s.cancel = nil
+    }
+    s.mutex.Unlock()
+}
+
+func (s *Sim) Advance(n int) error {
+    if s.Running() {
+        return errors.New("mobile: simulation is running")
+    }
+
+    for i := 0; i < n; i++ {
+        for _, dt := range s.env.Advance() {
+            s.mutex.Lock()
+            s.stats.Add(dt.Stats)
+            s.mutex.Unlock()
+        }
+    }
+
+    return nil
+}
+
+func (s *Sim) Pause() {
+    s.env.Pause()
+}
+
+func (s *Sim) Resume() {
+    s.env.Resume()
+}
+
+func (s *Sim) Stat(name string) int64 {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+    return s.stats[name]
+}
+
+func (s *Sim) Stats() *StatsInfo {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    return &StatsInfo{
+        Ticks: s.stats["Ticks"],
+        LiveCells: s.stats["LiveCells"],
+        ViableLiveCells: s.stats["ViableLiveCells"],
+        Reproductions: s.stats["Reproductions"],
+        Mutations: s.stats["Mutations"],
+        MaxGeneration: s.stats["MaxGeneration"],
+    }
+}
+
+func (s *Sim) Cell(x, y int32) *CellInfo {
+    if x < 0 || y < 0 || x >= s.env.Width || y >= s.env.Height {
+        return nil
+    }
+
+    c := s.env.GetCell(x, y)
+    return &CellInfo{
+        X: c.X,
+        Y: c.Y,
+        ID: c.ID,
+        Origin: c.Origin,
+        Parent: c.Parent,
+        Generation: c.Generation,
+        Energy: c.Energy,
+        Genome: c.Genome.String(),
+    }
+}
+
+func (s *Sim) InjectCell(x, y int32, genome string, energy int64) error {
+    g, err := gene.ParseGenome(genome)
+    if err != nil {
+        return err
+    }
+    _, err = s.env.InjectCell(x, y, g, energy)
+    return err
+}
+
+func (s *Sim) Pixels() []byte {
+    return render.RGBA(s.env, render.DefaultColors)
+}
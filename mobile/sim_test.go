@@ -0,0 +1,48 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package mobile
+
+import (
+    "testing"
+    "time"
+)
+
+func stopWithin(t *testing.T, s *Sim, d time.Duration) {
+    stopped := make(chan struct{})
+    go func() {
+        s.Stop()
+        close(stopped)
+    }()
+    select {
+    case <-stopped:
+    case <-time.After(d):
+        t.Fatal("Stop did not return")
+    }
+    if s.Running() {
+        t.Fatal("expected sim to be stopped")
+    }
+}
+
+func TestSimStopImmediately(t *testing.T) {
+    s := NewSim(16, 16, 32, 0.2, 1)
+    s.Start(1)
+    stopWithin(t, s, 5 * time.Second)
+}
+
+func TestSimRestart(t *testing.T) {
+    s := NewSim(16, 16, 32, 0.2, 1)
+    for i := 0; i < 3; i++ {
+        s.Start(1)
+        if !s.Running() {
+            t.Fatal("expected sim to be running")
+        }
+        time.Sleep(10 * time.Millisecond)
+        stopWithin(t, s, 5 * time.Second)
+    }
+    if s.Stat("Ticks") == 0 {
+        t.Fatal("expected ticks to be recorded")
+    }
+    if err := s.Advance(1); err != nil {
+        t.Fatal(err)
+    }
+}
@@ -0,0 +1,177 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package mqtt
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "sync"
+    "time"
+)
+
+const (
+    packetConnect = 1 << 4
+    packetConnAck = 2 << 4
+    packetPublish = 3 << 4
+    packetPingReq = 12 << 4
+    packetDisconnect = 14 << 4
+)
+
+const protocolLevel = 4
+
+type Options struct {
+    ClientID string
+    Username string
+    Password string
+    KeepAlive time.Duration
+}
+
+type Client struct {
+    conn net.Conn
+    mutex *sync.Mutex
+    done chan struct{}
+}
+
+func writeString(b *bytes.Buffer, s string) {
+    b.WriteByte(byte(len(s) >> 8))
+    b.WriteByte(byte(len(s)))
+    b.WriteString(s)
+}
+
+func writeLength(b *bytes.Buffer, n int) {
+    for {
+        d := byte(n % 128)
+        n /= 128
+        if n > 0 {
+            d |= 128
+        }
+        b.WriteByte(d)
+        if n == 0 {
+            return
+        }
+    }
+}
+
+func packet(header byte, body []byte) []byte {
+    var b bytes.Buffer
+    b.WriteByte(header)
+    writeLength(&b, len(body))
+    b.Write(body)
+    return b.Bytes()
+}
+
+func Dial(addr string, opts Options) (*Client, error) {
+    conn, err := net.Dial("tcp", addr)
+    if err != nil {
+        return nil, err
+    }
+
+    var body bytes.Buffer
+    writeString(&body, "MQTT")
+    body.WriteByte(protocolLevel)
+
+    var flags byte = 1 << 1
+    if opts.Username != "" {
+        flags |= 1 << 7
+    }
+    if opts.Password != "" {
+        flags |= 1 << 6
+    }
+    body.WriteByte(flags)
+
+    ka := int(opts.KeepAlive / time.Second)
+    body.WriteByte(byte(ka >> 8))
+    body.WriteByte(byte(ka))
+
+    writeString(&body, opts.ClientID)
+    if opts.Username != "" {
+        writeString(&body, opts.Username)
+    }
+    if opts.Password != "" {
+        writeString(&body, opts.Password)
+    }
+
+    if _, err := conn.Write(packet(packetConnect, body.Bytes())); err != nil {
+        conn.Close()
+        return nil, err
+    }
+
+    conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+    ack := make([]byte, 4)
+    if _, err := io.ReadFull(conn, ack); err != nil {
+        conn.Close()
+        return nil, err
+    }
+    conn.SetReadDeadline(time.Time{})
+
+    if ack[0] != packetConnAck {
+        conn.Close()
+        return nil, errors.New("mqtt: expected CONNACK")
+    }
+    if ack[3] != 0 {
+        conn.Close()
+        return nil, fmt.Errorf("mqtt: connection refused (%d)", ack[3])
+    }
+
+    c := &Client{
+        conn: conn,
+        mutex: &sync.Mutex{},
+        done: make(chan struct{}),
+    }
+
+    go c.discard()
+    if opts.KeepAlive > 0 {
+        go c.ping(opts.KeepAlive / 2)
+    }
+
+    return c, nil
+}
+
+func (c *Client) discard() {
+    io.Copy(io.Discard, c.conn)
+}
+
+func (c *Client) ping(interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-c.done:
+            return
+        case <-ticker.C:
+            if err := c.write(packet(packetPingReq, nil)); err != nil {
+                return
+            }
+        }
+    }
+}
+
+func (c *Client) write(p []byte) error {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    _, err := c.conn.Write(p)
+    return err
+}
+
+func (c *Client) Publish(topic string, payload []byte, retain bool) error {
+    var body bytes.Buffer
+    writeString(&body, topic)
+    body.Write(payload)
+
+    var header byte = packetPublish
+    if retain {
+        header |= 1
+    }
+
+    return c.write(packet(header, body.Bytes()))
+}
+
+func (c *Client) Close() error {
+    close(c.done)
+    c.write(packet(packetDisconnect, nil))
+    return c.conn.Close()
+}
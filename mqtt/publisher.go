@@ -0,0 +1,82 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package mqtt
+
+import (
+    "encoding/json"
+    "log"
+    "time"
+
+    tp "tidepool/tidepool"
+)
+
+type Topics struct {
+    Stats string
+    Events string
+}
+
+type EventJSON struct {
+    Type string
+    Tick int64
+    Value int64
+}
+
+type Publisher struct {
+    client *Client
+    topics Topics
+    stats tp.Stats
+}
+
+func NewPublisher(c *Client, t Topics) *Publisher {
+    return &Publisher{
+        client: c,
+        topics: t,
+        stats: make(tp.Stats),
+    }
+}
+
+func (p *Publisher) publish(topic string, v interface{}, retain bool) {
+    if topic == "" {
+        return
+    }
+    js, err := json.Marshal(v)
+    if err != nil {
+        log.Println(err)
+        return
+    }
+    if err := p.client.Publish(topic, js, retain); err != nil {
+        log.Println(err)
+    }
+}
+
+func (p *Publisher) event(t string, tick, value int64) {
+    p.publish(p.topics.Events, EventJSON{
+        Type: t,
+        Tick: tick,
+        Value: value,
+    }, false)
+}
+
+func (p *Publisher) Run(deltas <-chan *tp.Delta, update <-chan time.Time) {
+    for {
+        select {
+        case dt, ok := <-deltas:
+            if !ok {
+                return
+            }
+            live := p.stats["LiveCells"]
+            maxGen := p.stats["MaxGeneration"]
+
+            p.stats.Add(dt.Stats)
+
+            if g := p.stats["MaxGeneration"]; g > maxGen {
+                p.event("MaxGeneration", dt.Tick, g)
+            }
+            if live > 0 && p.stats["LiveCells"] == 0 {
+                p.event("Extinction", dt.Tick, 0)
+            }
+        case <-update:
+            p.publish(p.topics.Stats, p.stats, true)
+        }
+    }
+}
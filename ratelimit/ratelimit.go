@@ -0,0 +1,127 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package ratelimit
+
+import (
+    "math"
+    "sync"
+    "time"
+)
+
+const sweepThreshold = 1024
+
+type Quota struct {
+    Rate float64
+    Burst int
+    Max int
+    Window time.Duration
+}
+
+type bucket struct {
+    tokens float64
+    last time.Time
+    used int
+    window time.Time
+}
+
+type Limiter struct {
+    quota Quota
+    mutex sync.Mutex
+    clients map[string]*bucket
+}
+
+func New(q Quota) *Limiter {
+    if q.Burst < 1 {
+        q.Burst = 1
+    }
+    if q.Max > 0 && q.Window <= 0 {
+        q.Window = time.Hour
+    }
+    return &Limiter{
+        quota: q,
+        clients: make(map[string]*bucket),
+    }
+}
+
+func (l *Limiter) Enabled() bool {
+    return l != nil && (l.quota.Rate > 0 || l.quota.Max > 0)
+}
+
+func (l *Limiter) refill(b *bucket, now time.Time) {
+    q := l.quota
+    if q.Rate > 0 {
+        b.tokens = math.Min(float64(q.Burst), b.tokens + now.Sub(b.last).Seconds() * q.Rate)
+    }
+    b.last = now
+    if q.Max > 0 && now.Sub(b.window) >= q.Window {
+        b.used = 0
+        b.window = now
+    }
+}
+
+func (l *Limiter) sweep(now time.Time) {
+    for k, b := range l.clients {
+        l.refill(b, now)
+        if b.used == 0 && (l.quota.Rate <= 0 || b.tokens >= float64(l.quota.Burst)) {
+            delete(l.clients, k)
+        }
+    }
+}
+
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+    return l.AllowAt(key, time.Now())
+}
+
+func (l *Limiter) AllowAt(key string, now time.Time) (bool, time.Duration) {
+    if !l.Enabled() {
+        return true, 0
+    }
+
+    l.mutex.Lock()
+    defer l.mutex.Unlock()
+
+    b, ok := l.clients[key]
+    if !ok {
+        if len(l.clients) >= sweepThreshold {
+            l.sweep(now)
+        }
+        b = &bucket{
+            tokens: float64(l.quota.Burst),
+            last: now,
+            window: now,
+        }
+        l.clients[key] = b
+    }
+    l.refill(b, now)
+
+    q := l.quota
+    if q.Max > 0 && b.used >= q.Max {
+        return false, b.window.Add(q.Window).Sub(now)
+    }
+    if q.Rate > 0 {
+        if b.tokens < 1 {
+            wait := time.Duration((1 - b.tokens) / q.Rate * float64(time.Second))
+            return false, wait
+        }
+        b.tokens--
+    }
+    b.used++
+
+    return true, 0
+}
+
+func (l *Limiter) Remaining(key string) int {
+    if !l.Enabled() || l.quota.Max <= 0 {
+        return -1
+    }
+
+    l.mutex.Lock()
+    defer l.mutex.Unlock()
+
+    b, ok := l.clients[key]
+    if !ok {
+        return l.quota.Max
+    }
+    l.refill(b, time.Now())
+    return l.quota.Max - b.used
+}
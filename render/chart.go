@@ -0,0 +1,158 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package render
+
+import (
+    "image"
+    "image/color"
+    "image/png"
+    "io"
+
+    tp "tidepool/tidepool"
+)
+
+const chartMargin = 8
+
+var chartBackground = color.RGBA{255, 255, 255, 255}
+var chartAxis = color.RGBA{96, 96, 96, 255}
+var chartGrid = color.RGBA{224, 224, 224, 255}
+
+var ChartPalette = []color.RGBA{
+    {31, 119, 180, 255},
+    {214, 39, 40, 255},
+    {44, 160, 44, 255},
+    {255, 127, 14, 255},
+    {148, 103, 189, 255},
+}
+
+type ChartSeries struct {
+    Name string
+    Values []float64
+    Ticks []int64
+    Color color.RGBA
+}
+
+func line(img *image.RGBA, x0, y0, x1, y1 int, c color.RGBA) {
+    dx, dy := x1 - x0, y1 - y0
+    if dx < 0 {
+        dx = -dx
+    }
+    if dy < 0 {
+        dy = -dy
+    }
+    sx, sy := 1, 1
+    if x0 > x1 {
+        sx = -1
+    }
+    if y0 > y1 {
+        sy = -1
+    }
+    err := dx - dy
+    for {
+        img.SetRGBA(x0, y0, c)
+        if x0 == x1 && y0 == y1 {
+            return
+        }
+        e2 := 2 * err
+        if e2 > -dy {
+            err -= dy
+            x0 += sx
+        }
+        if e2 < dx {
+            err += dx
+            y0 += sy
+        }
+    }
+}
+
+func LineChart(width, height int, series ...ChartSeries) *image.RGBA {
+    img := image.NewRGBA(image.Rect(0, 0, width, height))
+    for i := 0; i < len(img.Pix); i += 4 {
+        img.Pix[i], img.Pix[i + 1], img.Pix[i + 2], img.Pix[i + 3] =
+            chartBackground.R, chartBackground.G, chartBackground.B, chartBackground.A
+    }
+
+    x0, y0 := chartMargin, height - 1 - chartMargin
+    x1, y1 := width - 1 - chartMargin, chartMargin
+    if x1 <= x0 || y0 <= y1 {
+        return img
+    }
+
+    for i := 1; i < 4; i++ {
+        y := y0 - (y0 - y1) * i / 4
+        line(img, x0, y, x1, y, chartGrid)
+    }
+    line(img, x0, y0, x1, y0, chartAxis)
+    line(img, x0, y0, x0, y1, chartAxis)
+
+    var minT, maxT int64
+    var maxV float64
+    first := true
+    for _, s := range series {
+        for i, v := range s.Values {
+            t := int64(i)
+            if i < len(s.Ticks) {
+                t = s.Ticks[i]
+            }
+            if first || t < minT {
+                minT = t
+            }
+            if first || t > maxT {
+                maxT = t
+            }
+            if v > maxV {
+                maxV = v
+            }
+            first = false
+        }
+    }
+    if first {
+        return img
+    }
+    if maxT == minT {
+        maxT = minT + 1
+    }
+    if maxV <= 0 {
+        maxV = 1
+    }
+
+    for n, s := range series {
+        c := s.Color
+        if c.A == 0 {
+            c = ChartPalette[n % len(ChartPalette)]
+        }
+        px, py := -1, -1
+        for i, v := range s.Values {
+            t := int64(i)
+            if i < len(s.Ticks) {
+                t = s.Ticks[i]
+            }
+            x := x0 + int(float64(t - minT) / float64(maxT - minT) * float64(x1 - x0))
+            y := y0 - int(v / maxV * float64(y0 - y1))
+            if px >= 0 {
+                line(img, px, py, x, y, c)
+            } else {
+                img.SetRGBA(x, y, c)
+            }
+            px, py = x, y
+        }
+    }
+
+    return img
+}
+
+func EncodeChart(w io.Writer, width, height int, series ...ChartSeries) error {
+    return png.Encode(w, LineChart(width, height, series...))
+}
+
+func HistoryChart(w io.Writer, width, height int, h tp.StatsHistory, metrics ...string) error {
+    if len(metrics) == 0 {
+        metrics = []string{"LiveCells", "Diversity"}
+    }
+    series := make([]ChartSeries, len(metrics))
+    for i, m := range metrics {
+        vs, ts := h.Series(m)
+        series[i] = ChartSeries{Name: m, Values: vs, Ticks: ts}
+    }
+    return EncodeChart(w, width, height, series...)
+}
@@ -0,0 +1,109 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package render
+
+import (
+    "image"
+    "image/color"
+    "image/png"
+    "io"
+
+    tp "tidepool/tidepool"
+)
+
+type ColorMapper interface {
+    Color(c *tp.Cell, config tp.Config) color.RGBA
+}
+
+type ColorFunc func(c *tp.Cell, config tp.Config) color.RGBA
+
+func (f ColorFunc) Color(c *tp.Cell, config tp.Config) color.RGBA {
+    return f(c, config)
+}
+
+var black = color.RGBA{A: 255}
+
+type GenomeColors struct{}
+
+func (GenomeColors) Color(c *tp.Cell, config tp.Config) color.RGBA {
+    if c.Energy == 0 || c.Generation < config.ViableCellGeneration {
+        return black
+    }
+    h := c.Genome.Hash()
+    return color.RGBA{byte(h >> 16), byte(h >> 8), byte(h), 255}
+}
+
+type EnergyColors struct {
+    Max int64
+}
+
+func (m EnergyColors) Color(c *tp.Cell, config tp.Config) color.RGBA {
+    if c.Energy == 0 {
+        return black
+    }
+    max := m.Max
+    if max <= 0 {
+        max = 1 << 16
+    }
+    v := c.Energy * 255 / max
+    if v > 255 {
+        v = 255
+    }
+    return color.RGBA{byte(v), byte(v / 2), byte(255 - v), 255}
+}
+
+var DefaultColors ColorMapper = GenomeColors{}
+
+func Image(e *tp.Env, m ColorMapper) *image.RGBA {
+    if m == nil {
+        m = DefaultColors
+    }
+    config := e.GetConfig()
+    img := image.NewRGBA(image.Rect(0, 0, int(e.Width), int(e.Height)))
+
+    e.WithCells(func(cs []*tp.Cell) {
+        for i, c := range cs {
+            img.SetRGBA(i % int(e.Width), i / int(e.Width), m.Color(c, config))
+        }
+    })
+
+    return img
+}
+
+func RGBA(e *tp.Env, m ColorMapper) []byte {
+    return Image(e, m).Pix
+}
+
+func Thumbnail(e *tp.Env, m ColorMapper, size int) *image.RGBA {
+    src := Image(e, m)
+    w, h := src.Rect.Dx(), src.Rect.Dy()
+    if size <= 0 || (w <= size && h <= size) {
+        return src
+    }
+
+    tw, th := size, size
+    if w > h {
+        th = h * size / w
+    } else {
+        tw = w * size / h
+    }
+    if tw < 1 {
+        tw = 1
+    }
+    if th < 1 {
+        th = 1
+    }
+
+    dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+    for y := 0; y < th; y++ {
+        for x := 0; x < tw; x++ {
+            dst.SetRGBA(x, y, src.RGBAAt(x * w / tw, y * h / th))
+        }
+    }
+
+    return dst
+}
+
+func EncodeThumbnail(w io.Writer, e *tp.Env, m ColorMapper, size int) error {
+    return png.Encode(w, Thumbnail(e, m, size))
+}
@@ -0,0 +1,222 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package script
+
+import (
+    "fmt"
+    "log"
+    "reflect"
+
+    "go.starlark.net/starlark"
+
+    tp "tidepool/tidepool"
+    "tidepool/tidepool/gene"
+)
+
+type Script struct {
+    env *tp.Env
+    thread *starlark.Thread
+    globals starlark.StringDict
+    tick int64
+}
+
+func Load(e *tp.Env, filename string, src interface{}) (*Script, error) {
+    s := &Script{
+        env: e,
+        thread: &starlark.Thread{Name: filename},
+    }
+
+    globals, err := starlark.ExecFile(s.thread, filename, src, s.builtins())
+    if err != nil {
+        return nil, err
+    }
+    s.globals = globals
+
+    return s, nil
+}
+
+func (s *Script) builtins() starlark.StringDict {
+    return starlark.StringDict{
+        "inject": starlark.NewBuiltin("inject", s.inject),
+        "kill": starlark.NewBuiltin("kill", s.kill),
+        "cell": starlark.NewBuiltin("cell", s.cell),
+        "set_config": starlark.NewBuiltin("set_config", s.setConfig),
+        "pause": starlark.NewBuiltin("pause", s.pause),
+        "resume": starlark.NewBuiltin("resume", s.resume),
+    }
+}
+
+func cellDict(c *tp.Cell) *starlark.Dict {
+    d := starlark.NewDict(8)
+    d.SetKey(starlark.String("x"), starlark.MakeInt(int(c.X)))
+    d.SetKey(starlark.String("y"), starlark.MakeInt(int(c.Y)))
+    d.SetKey(starlark.String("id"), starlark.MakeInt64(c.ID))
+    d.SetKey(starlark.String("origin"), starlark.MakeInt64(c.Origin))
+    d.SetKey(starlark.String("parent"), starlark.MakeInt64(c.Parent))
+    d.SetKey(starlark.String("generation"), starlark.MakeInt64(c.Generation))
+    d.SetKey(starlark.String("energy"), starlark.MakeInt64(c.Energy))
+    d.SetKey(starlark.String("genome"), starlark.String(c.Genome.String()))
+    return d
+}
+
+func statsDict(st tp.Stats) *starlark.Dict {
+    d := starlark.NewDict(len(st))
+    for n, v := range st {
+        d.SetKey(starlark.String(n), starlark.MakeInt64(v))
+    }
+    return d
+}
+
+func (s *Script) inject(thread *starlark.Thread, b *starlark.Builtin,
+    args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+    var x, y int
+    var genome string
+    energy := 1000
+    if err := starlark.UnpackArgs("inject", args, kwargs, "x", &x, "y", &y,
+        "genome", &genome, "energy?", &energy); err != nil {
+        return nil, err
+    }
+
+    g, err := gene.ParseGenome(genome)
+    if err != nil {
+        return nil, err
+    }
+    c, err := s.env.InjectCell(int32(x), int32(y), g, int64(energy))
+    if err != nil {
+        return nil, err
+    }
+
+    return starlark.MakeInt64(c.ID), nil
+}
+
+func (s *Script) kill(thread *starlark.Thread, b *starlark.Builtin,
+    args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+    var x, y int
+    if err := starlark.UnpackArgs("kill", args, kwargs, "x", &x, "y", &y); err != nil {
+        return nil, err
+    }
+    return starlark.None, s.env.KillCell(int32(x), int32(y))
+}
+
+func (s *Script) cell(thread *starlark.Thread, b *starlark.Builtin,
+    args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+    var x, y int
+    if err := starlark.UnpackArgs("cell", args, kwargs, "x", &x, "y", &y); err != nil {
+        return nil, err
+    }
+    if x < 0 || y < 0 || int32(x) >= s.env.Width || int32(y) >= s.env.Height {
+        return nil, fmt.Errorf("cell: %d,%d out of bounds", x, y)
+    }
+    return cellDict(s.env.GetCell(int32(x), int32(y))), nil
+}
+
+func (s *Script) setConfig(thread *starlark.Thread, b *starlark.Builtin,
+    args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+    var name string
+    var v starlark.Value
+    if err := starlark.UnpackArgs("set_config", args, kwargs, "name", &name,
+        "value", &v); err != nil {
+        return nil, err
+    }
+
+    config := s.env.GetConfig()
+    f := reflect.ValueOf(&config).Elem().FieldByName(name)
+    if !f.IsValid() || !f.CanSet() {
+        return nil, fmt.Errorf("set_config: unknown field %s", name)
+    }
+
+    switch f.Kind() {
+    case reflect.Int, reflect.Int32, reflect.Int64:
+        i, ok := v.(starlark.Int)
+        if !ok {
+            return nil, fmt.Errorf("set_config: %s requires int", name)
+        }
+        n, ok := i.Int64()
+        if !ok {
+            return nil, fmt.Errorf("set_config: %s out of range", name)
+        }
+        f.SetInt(n)
+    case reflect.Float64:
+        switch x := v.(type) {
+        case starlark.Float:
+            f.SetFloat(float64(x))
+        case starlark.Int:
+            n, _ := x.Int64()
+            f.SetFloat(float64(n))
+        default:
+            return nil, fmt.Errorf("set_config: %s requires float", name)
+        }
+    case reflect.Bool:
+        x, ok := v.(starlark.Bool)
+        if !ok {
+            return nil, fmt.Errorf("set_config: %s requires bool", name)
+        }
+        f.SetBool(bool(x))
+    default:
+        return nil, fmt.Errorf("set_config: unsupported field %s", name)
+    }
+
+    if err := config.Validate(); err != nil {
+        return nil, fmt.Errorf("set_config: %v", err)
+    }
+    s.env.SetConfig(config)
+
+    return starlark.None, nil
+}
+
+func (s *Script) pause(thread *starlark.Thread, b *starlark.Builtin,
+    args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+    s.env.Pause()
+    return starlark.None, nil
+}
+
+func (s *Script) resume(thread *starlark.Thread, b *starlark.Builtin,
+    args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+    s.env.Resume()
+    return starlark.None, nil
+}
+
+func (s *Script) call(name string, args ...starlark.Value) error {
+    fn, ok := s.globals[name]
+    if !ok {
+        return nil
+    }
+    _, err := starlark.Call(s.thread, fn, starlark.Tuple(args), nil)
+    return err
+}
+
+func (s *Script) Handle(dt *tp.Delta) error {
+    if dt.Tick > s.tick {
+        s.tick = dt.Tick
+        if err := s.call("on_tick", starlark.MakeInt64(dt.Tick),
+            statsDict(dt.Stats)); err != nil {
+            return err
+        }
+    }
+
+    for _, ev := range dt.Events {
+        var err error
+        switch ev.Type {
+        case tp.EventBirth:
+            err = s.call("on_birth", cellDict(ev.Cell))
+        case tp.EventExtinction:
+            err = s.call("on_extinction", starlark.MakeInt64(ev.Tick))
+        }
+        if err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+func (s *Script) Run(deltas <-chan *tp.Delta, out chan<- *tp.Delta) {
+    defer close(out)
+
+    for dt := range deltas {
+        if err := s.Handle(dt); err != nil {
+            log.Println(err)
+        }
+        out <- dt
+    }
+}
@@ -0,0 +1,94 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package script
+
+import (
+    "strings"
+    "testing"
+
+    tp "tidepool/tidepool"
+)
+
+func TestSetConfig(t *testing.T) {
+    env := tp.NewEnv(8, 8, 16, 0, 1)
+    s, err := Load(env, "test.star", `
+def valid():
+    set_config("InflowFrequency", 7)
+    set_config("MutationLogSize", 16)
+
+def invalid():
+    set_config("FailedKillPenalty", 0)
+
+def unknown():
+    set_config("Width", 1)
+`)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if err := s.call("valid"); err != nil {
+        t.Fatal(err)
+    }
+    if c := env.GetConfig(); c.InflowFrequency != 7 || c.MutationLogSize != 16 {
+        t.Fatalf("expected config to be applied, got %+v", c)
+    }
+
+    if err := s.call("invalid"); err == nil || !strings.Contains(err.Error(), "FailedKillPenalty") {
+        t.Fatalf("expected invalid FailedKillPenalty, got %v", err)
+    }
+    if env.GetConfig().FailedKillPenalty < 1 {
+        t.Fatal("expected invalid config to be rejected")
+    }
+
+    if err := s.call("unknown"); err == nil {
+        t.Fatal("expected unknown field to be rejected")
+    }
+}
+
+func TestHandle(t *testing.T) {
+    env := tp.NewEnv(8, 8, 16, 0, 1)
+    s, err := Load(env, "test.star", `
+def on_tick(tick, stats):
+    inject(tick, 0, "0000", stats["Ticks"])
+
+def on_birth(c):
+    inject(c["x"] + 1, c["y"], "0000", c["id"])
+
+def on_extinction(tick):
+    pause()
+`)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    c := env.GetCell(2, 2)
+    c.ID = 42
+    dt := &tp.Delta{
+        Tick: 3,
+        Stats: tp.Stats{"Ticks": 3},
+        Events: []*tp.Event{
+            {Type: tp.EventBirth, Cell: c},
+            {Type: tp.EventExtinction},
+        },
+    }
+    if err := s.Handle(dt); err != nil {
+        t.Fatal(err)
+    }
+    if e := env.GetCell(3, 0).Energy; e != 3 {
+        t.Fatalf("expected on_tick to inject a cell, got energy %d", e)
+    }
+    if e := env.GetCell(3, 2).Energy; e != 42 {
+        t.Fatalf("expected on_birth to inject a cell, got energy %d", e)
+    }
+    if !env.Paused() {
+        t.Fatal("expected on_extinction to pause")
+    }
+
+    env.KillCell(3, 0)
+    if err := s.Handle(dt); err != nil {
+        t.Fatal(err)
+    }
+    if env.GetCell(3, 0).Energy != 0 {
+        t.Fatal("expected on_tick once per tick")
+    }
+}
@@ -0,0 +1,24 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package store
+
+import (
+    "errors"
+    "time"
+)
+
+var ErrNotFound = errors.New("store: blob not found")
+var ErrInvalidBlobName = errors.New("store: invalid blob name")
+
+type BlobInfo struct {
+    Name string
+    Size int64
+    Modified time.Time
+}
+
+type BlobStore interface {
+    Put(name string, data []byte) error
+    Get(name string) ([]byte, error)
+    Delete(name string) error
+    List(prefix string) ([]BlobInfo, error)
+}
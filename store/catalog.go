@@ -0,0 +1,155 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package store
+
+import (
+    "encoding/json"
+    "errors"
+    "sort"
+    "strings"
+    "time"
+
+    "tidepool/render"
+    tp "tidepool/tidepool"
+)
+
+var ErrInvalidName = errors.New("store: invalid slot name")
+
+type SlotInfo struct {
+    Name string
+    Description string
+    Created time.Time
+    Tick int64
+    Population int64
+    RunID string `json:",omitempty"`
+    Width int32
+    Height int32
+    Seed int64
+    Thumbnail string `json:",omitempty"`
+}
+
+type Catalog struct {
+    Store BlobStore
+    Prefix string
+    Colors render.ColorMapper
+}
+
+func NewCatalog(s BlobStore, prefix string) *Catalog {
+    return &Catalog{Store: s, Prefix: prefix}
+}
+
+func validSlotName(name string) bool {
+    return name != "" && !strings.ContainsAny(name, "/\\") &&
+        !strings.HasPrefix(name, ".")
+}
+
+func (c *Catalog) blob(name, ext string) string {
+    return c.Prefix + "slots/" + name + ext
+}
+
+func (c *Catalog) Save(name, description string, e *tp.Env) (SlotInfo, error) {
+    if !validSlotName(name) {
+        return SlotInfo{}, ErrInvalidName
+    }
+
+    info := SlotInfo{
+        Name: name,
+        Description: description,
+        Created: time.Now().UTC(),
+        Tick: e.Ticks(),
+        Population: int64(e.LiveCount()),
+        Width: e.Width,
+        Height: e.Height,
+        RunID: e.RunID(),
+        Seed: e.Seed,
+    }
+
+    if err := SaveCheckpoint(c.Store, c.blob(name, ".bin"), e); err != nil {
+        return SlotInfo{}, err
+    }
+    if err := SaveThumbnail(c.Store, c.blob(name, ""), e, c.Colors); err != nil {
+        return SlotInfo{}, err
+    }
+    info.Thumbnail = c.blob(name, thumbnailExt)
+
+    data, err := json.Marshal(info)
+    if err != nil {
+        return SlotInfo{}, err
+    }
+    if err := c.Store.Put(c.blob(name, ".json"), data); err != nil {
+        return SlotInfo{}, err
+    }
+
+    return info, nil
+}
+
+func (c *Catalog) Info(name string) (SlotInfo, error) {
+    var info SlotInfo
+    if !validSlotName(name) {
+        return info, ErrInvalidName
+    }
+
+    data, err := c.Store.Get(c.blob(name, ".json"))
+    if err != nil {
+        return info, err
+    }
+    err = json.Unmarshal(data, &info)
+    return info, err
+}
+
+func (c *Catalog) List() ([]SlotInfo, error) {
+    bs, err := c.Store.List(c.Prefix + "slots/")
+    if err != nil {
+        return nil, err
+    }
+
+    var infos []SlotInfo
+    for _, b := range bs {
+        if !strings.HasSuffix(b.Name, ".json") {
+            continue
+        }
+        name := strings.TrimSuffix(b.Name[len(c.Prefix + "slots/"):], ".json")
+        info, err := c.Info(name)
+        if err == ErrNotFound {
+            continue
+        } else if err != nil {
+            return nil, err
+        }
+        infos = append(infos, info)
+    }
+
+    sort.Slice(infos, func(i, j int) bool {
+        return infos[i].Created.After(infos[j].Created)
+    })
+
+    return infos, nil
+}
+
+func (c *Catalog) Thumbnail(name string) ([]byte, error) {
+    if !validSlotName(name) {
+        return nil, ErrInvalidName
+    }
+    return LoadThumbnail(c.Store, c.blob(name, ""))
+}
+
+func (c *Catalog) Load(name string) (*tp.Env, error) {
+    if !validSlotName(name) {
+        return nil, ErrInvalidName
+    }
+    return LoadCheckpoint(c.Store, c.blob(name, ".bin"))
+}
+
+func (c *Catalog) Delete(name string) error {
+    if !validSlotName(name) {
+        return ErrInvalidName
+    }
+
+    for _, ext := range []string{".json", ".bin", thumbnailExt} {
+        err := c.Store.Delete(c.blob(name, ext))
+        if err != nil && err != ErrNotFound {
+            return err
+        }
+    }
+
+    return nil
+}
@@ -0,0 +1,149 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package store
+
+import (
+    "bytes"
+    "log"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "tidepool/render"
+    tp "tidepool/tidepool"
+)
+
+const checkpointTimeFormat = "20060102T150405.000000000Z"
+const thumbnailExt = ".png"
+
+var ThumbnailSize = 64
+
+type Retention struct {
+    Keep int
+    MaxAge time.Duration
+}
+
+func SaveCheckpoint(s BlobStore, name string, e *tp.Env) error {
+    data, err := e.MarshalBinary()
+    if err != nil {
+        return err
+    }
+    return s.Put(name, data)
+}
+
+func SaveThumbnail(s BlobStore, name string, e *tp.Env, m render.ColorMapper) error {
+    var buf bytes.Buffer
+    if err := render.EncodeThumbnail(&buf, e, m, ThumbnailSize); err != nil {
+        return err
+    }
+    return s.Put(name + thumbnailExt, buf.Bytes())
+}
+
+func LoadThumbnail(s BlobStore, name string) ([]byte, error) {
+    return s.Get(name + thumbnailExt)
+}
+
+func LoadCheckpoint(s BlobStore, name string) (*tp.Env, error) {
+    data, err := s.Get(name)
+    if err != nil {
+        return nil, err
+    }
+
+    e := &tp.Env{}
+    if err := e.UnmarshalBinary(data); err != nil {
+        return nil, err
+    }
+    return e, nil
+}
+
+func (r Retention) Apply(s BlobStore, prefix string) error {
+    all, err := s.List(prefix)
+    if err != nil {
+        return err
+    }
+
+    var bs []BlobInfo
+    for _, b := range all {
+        if !strings.HasSuffix(b.Name, thumbnailExt) {
+            bs = append(bs, b)
+        }
+    }
+
+    sort.Slice(bs, func(i, j int) bool {
+        return bs[i].Modified.After(bs[j].Modified)
+    })
+
+    now := time.Now()
+    for i, b := range bs {
+        expired := r.MaxAge > 0 && now.Sub(b.Modified) > r.MaxAge
+        if (r.Keep > 0 && i >= r.Keep) || expired {
+            if err := s.Delete(b.Name); err != nil && err != ErrNotFound {
+                return err
+            }
+            err := s.Delete(b.Name + thumbnailExt)
+            if err != nil && err != ErrNotFound {
+                return err
+            }
+        }
+    }
+
+    return nil
+}
+
+type Checkpointer struct {
+    Store BlobStore
+    Env *tp.Env
+    Prefix string
+    Retention Retention
+    Colors render.ColorMapper
+
+    mutex sync.Mutex
+    last time.Time
+    err error
+}
+
+func (c *Checkpointer) checkpoint() (string, error) {
+    name := c.Prefix + time.Now().UTC().Format(checkpointTimeFormat)
+    if err := SaveCheckpoint(c.Store, name, c.Env); err != nil {
+        return "", err
+    }
+    if c.Colors != nil {
+        if err := SaveThumbnail(c.Store, name, c.Env, c.Colors); err != nil {
+            return "", err
+        }
+    }
+    return name, c.Retention.Apply(c.Store, c.Prefix)
+}
+
+func (c *Checkpointer) Checkpoint() (string, error) {
+    name, err := c.checkpoint()
+
+    c.mutex.Lock()
+    if err == nil {
+        c.last = time.Now()
+    }
+    c.err = err
+    c.mutex.Unlock()
+
+    return name, err
+}
+
+func (c *Checkpointer) LastCheckpoint() (time.Time, error) {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    return c.last, c.err
+}
+
+func (c *Checkpointer) Run(tick <-chan time.Time, done <-chan struct{}) {
+    for {
+        select {
+        case <-done:
+            return
+        case <-tick:
+            if _, err := c.Checkpoint(); err != nil {
+                log.Println(err)
+            }
+        }
+    }
+}
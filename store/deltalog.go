@@ -0,0 +1,380 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package store
+
+import (
+    "bufio"
+    "bytes"
+    "compress/gzip"
+    "encoding/binary"
+    "encoding/gob"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+
+    tp "tidepool/tidepool"
+    "tidepool/tidepool/gene"
+)
+
+const (
+    deltaLogExt = ".log"
+    gzipExt = ".gz"
+    maxRecordSize = 1 << 30
+    defaultMaxLogBytes = 64 << 20
+)
+
+var ErrCorruptLog = errors.New("store: corrupt delta log")
+
+type DeltaLogOptions struct {
+    Dir string
+    Prefix string
+    MaxBytes int64
+    Compress bool
+    Env *tp.Env
+    KeyframeEvery int64
+}
+
+type DeltaLogWriter struct {
+    opts DeltaLogOptions
+    index int
+    file *os.File
+    gz *gzip.Writer
+    w *bufio.Writer
+    n int64
+    buf bytes.Buffer
+    grid []*tp.Cell
+    runID string
+    seq int64
+    tick int64
+    nextKeyframe int64
+}
+
+type DeltaLogReader struct {
+    files []string
+    i int
+    file *os.File
+    gz *gzip.Reader
+    r *bufio.Reader
+}
+
+func deltaLogFiles(dir, prefix string) ([]string, error) {
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        return nil, err
+    }
+
+    var files []string
+    for _, ent := range entries {
+        name := ent.Name()
+        if ent.IsDir() || !strings.HasPrefix(name, prefix + "-") {
+            continue
+        }
+        if strings.HasSuffix(name, deltaLogExt) || strings.HasSuffix(name, deltaLogExt + gzipExt) {
+            files = append(files, filepath.Join(dir, name))
+        }
+    }
+    sort.Strings(files)
+
+    return files, nil
+}
+
+func NewDeltaLogWriter(opts DeltaLogOptions) (*DeltaLogWriter, error) {
+    if opts.Prefix == "" {
+        opts.Prefix = "deltas"
+    }
+    if opts.MaxBytes <= 0 {
+        opts.MaxBytes = defaultMaxLogBytes
+    }
+    if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+        return nil, err
+    }
+
+    files, err := deltaLogFiles(opts.Dir, opts.Prefix)
+    if err != nil {
+        return nil, err
+    }
+
+    l := &DeltaLogWriter{
+        opts: opts,
+        index: len(files),
+    }
+    if opts.Env != nil {
+        opts.Env.WithCells(func(cs []*tp.Cell) {
+            l.grid = make([]*tp.Cell, len(cs))
+            for i, c := range cs {
+                l.grid[i] = copyCell(c)
+            }
+        })
+        l.runID = opts.Env.RunID()
+        l.tick = opts.Env.Ticks()
+        l.nextKeyframe = l.tick + opts.KeyframeEvery
+    }
+    if err := l.rotate(); err != nil {
+        return nil, err
+    }
+
+    return l, nil
+}
+
+func (l *DeltaLogWriter) closeFile() error {
+    if l.file == nil {
+        return nil
+    }
+    err := l.w.Flush()
+    if l.gz != nil {
+        if e := l.gz.Close(); err == nil {
+            err = e
+        }
+    }
+    if e := l.file.Close(); err == nil {
+        err = e
+    }
+    l.file, l.gz, l.w = nil, nil, nil
+    return err
+}
+
+func (l *DeltaLogWriter) rotate() error {
+    if err := l.closeFile(); err != nil {
+        return err
+    }
+
+    l.index++
+    name := fmt.Sprintf("%s-%06d%s", l.opts.Prefix, l.index, deltaLogExt)
+    if l.opts.Compress {
+        name += gzipExt
+    }
+
+    f, err := os.OpenFile(filepath.Join(l.opts.Dir, name), os.O_CREATE | os.O_EXCL | os.O_WRONLY, 0644)
+    if err != nil {
+        return err
+    }
+    l.file = f
+    l.n = 0
+
+    if l.opts.Compress {
+        l.gz = gzip.NewWriter(f)
+        l.w = bufio.NewWriter(l.gz)
+    } else {
+        l.w = bufio.NewWriter(f)
+    }
+
+    return l.writeKeyframe()
+}
+
+func copyCell(c *tp.Cell) *tp.Cell {
+    n := *c
+    n.Genome = append(gene.Genome(nil), c.Genome...)
+    return &n
+}
+
+func (l *DeltaLogWriter) writeKeyframe() error {
+    if l.grid == nil {
+        return nil
+    }
+    return l.record(&tp.Delta{
+        RunID: l.runID,
+        Seq: l.seq,
+        Tick: l.tick,
+        Keyframe: true,
+        Cells: l.grid,
+        Stats: make(tp.Stats),
+    })
+}
+
+func (l *DeltaLogWriter) Write(dt *tp.Delta) error {
+    if l.n >= l.opts.MaxBytes {
+        if err := l.rotate(); err != nil {
+            return err
+        }
+    }
+
+    if err := l.record(dt); err != nil {
+        return err
+    }
+    if l.grid == nil {
+        return nil
+    }
+
+    for _, c := range dt.Cells {
+        if c.Idx < 0 || int(c.Idx) >= len(l.grid) {
+            continue
+        }
+        g := l.grid[c.Idx]
+        genome := g.Genome
+        *g = *c
+        g.Genome = append(genome[:0], c.Genome...)
+    }
+    l.seq, l.tick = dt.Seq, dt.Tick
+    if dt.RunID != "" {
+        l.runID = dt.RunID
+    }
+
+    if l.opts.KeyframeEvery > 0 && dt.Tick >= l.nextKeyframe {
+        l.nextKeyframe = dt.Tick + l.opts.KeyframeEvery
+        return l.writeKeyframe()
+    }
+
+    return nil
+}
+
+func (l *DeltaLogWriter) record(dt *tp.Delta) error {
+    l.buf.Reset()
+    if err := gob.NewEncoder(&l.buf).Encode(dt); err != nil {
+        return err
+    }
+
+    var hdr [binary.MaxVarintLen64]byte
+    n := binary.PutUvarint(hdr[:], uint64(l.buf.Len()))
+    if _, err := l.w.Write(hdr[:n]); err != nil {
+        return err
+    }
+    if _, err := l.w.Write(l.buf.Bytes()); err != nil {
+        return err
+    }
+    if !dt.Keyframe {
+        l.n += int64(n + l.buf.Len())
+    }
+
+    return nil
+}
+
+func (l *DeltaLogWriter) Flush() error {
+    if err := l.w.Flush(); err != nil {
+        return err
+    }
+    if l.gz != nil {
+        return l.gz.Flush()
+    }
+    return nil
+}
+
+func (l *DeltaLogWriter) Close() error {
+    return l.closeFile()
+}
+
+func (l *DeltaLogWriter) Run(dts <-chan *tp.Delta) error {
+    for dt := range dts {
+        if err := l.Write(dt); err != nil {
+            return err
+        }
+    }
+    return l.Close()
+}
+
+func OpenDeltaLog(dir, prefix string) (*DeltaLogReader, error) {
+    if prefix == "" {
+        prefix = "deltas"
+    }
+    files, err := deltaLogFiles(dir, prefix)
+    if err != nil {
+        return nil, err
+    }
+    if len(files) == 0 {
+        return nil, ErrNotFound
+    }
+    return &DeltaLogReader{files: files}, nil
+}
+
+func (r *DeltaLogReader) closeFile() error {
+    if r.file == nil {
+        return nil
+    }
+    var err error
+    if r.gz != nil {
+        err = r.gz.Close()
+    }
+    if e := r.file.Close(); err == nil {
+        err = e
+    }
+    r.file, r.gz, r.r = nil, nil, nil
+    return err
+}
+
+func (r *DeltaLogReader) open() error {
+    f, err := os.Open(r.files[r.i])
+    if err != nil {
+        return err
+    }
+    r.file = f
+
+    if strings.HasSuffix(r.files[r.i], gzipExt) {
+        if r.gz, err = gzip.NewReader(f); err != nil {
+            f.Close()
+            r.file = nil
+            return err
+        }
+        r.r = bufio.NewReader(r.gz)
+    } else {
+        r.r = bufio.NewReader(f)
+    }
+
+    return nil
+}
+
+func (r *DeltaLogReader) Next() (*tp.Delta, error) {
+    for {
+        if r.file == nil {
+            if r.i >= len(r.files) {
+                return nil, io.EOF
+            }
+            if err := r.open(); err != nil {
+                return nil, err
+            }
+        }
+
+        size, err := binary.ReadUvarint(r.r)
+        if err == io.EOF {
+            if err := r.closeFile(); err != nil {
+                return nil, err
+            }
+            r.i++
+            continue
+        }
+        if err != nil {
+            return nil, err
+        }
+        if size > maxRecordSize {
+            return nil, ErrCorruptLog
+        }
+
+        data := make([]byte, size)
+        if _, err := io.ReadFull(r.r, data); err != nil {
+            return nil, ErrCorruptLog
+        }
+
+        dt := &tp.Delta{}
+        if err := gob.NewDecoder(bytes.NewReader(data)).Decode(dt); err != nil {
+            return nil, err
+        }
+        return dt, nil
+    }
+}
+
+func (r *DeltaLogReader) Close() error {
+    r.i = len(r.files)
+    return r.closeFile()
+}
+
+func ReadDeltaLog(dir, prefix string) ([]*tp.Delta, error) {
+    r, err := OpenDeltaLog(dir, prefix)
+    if err != nil {
+        return nil, err
+    }
+    defer r.Close()
+
+    var dts []*tp.Delta
+    for {
+        dt, err := r.Next()
+        if err == io.EOF {
+            return dts, nil
+        }
+        if err != nil {
+            return nil, err
+        }
+        dts = append(dts, dt)
+    }
+}
@@ -0,0 +1,119 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package store
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+type DiskStore struct {
+    Dir string
+}
+
+func NewDiskStore(dir string) (*DiskStore, error) {
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return nil, err
+    }
+    return &DiskStore{Dir: dir}, nil
+}
+
+func (s *DiskStore) path(name string) (string, error) {
+    p := filepath.FromSlash(name)
+    if p == "" || filepath.IsAbs(p) || strings.HasPrefix(p, string(filepath.Separator)) {
+        return "", ErrInvalidBlobName
+    }
+    p = filepath.Clean(p)
+    if p == "." || p == ".." || strings.HasPrefix(p, ".." + string(filepath.Separator)) {
+        return "", ErrInvalidBlobName
+    }
+    return filepath.Join(s.Dir, p), nil
+}
+
+func (s *DiskStore) Put(name string, data []byte) error {
+    p, err := s.path(name)
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+        return err
+    }
+
+    f, err := ioutil.TempFile(filepath.Dir(p), ".tmp-")
+    if err != nil {
+        return err
+    }
+    if _, err := f.Write(data); err != nil {
+        f.Close()
+        os.Remove(f.Name())
+        return err
+    }
+    if err := f.Close(); err != nil {
+        os.Remove(f.Name())
+        return err
+    }
+
+    return os.Rename(f.Name(), p)
+}
+
+func (s *DiskStore) Get(name string) ([]byte, error) {
+    p, err := s.path(name)
+    if err != nil {
+        return nil, err
+    }
+    data, err := ioutil.ReadFile(p)
+    if os.IsNotExist(err) {
+        return nil, ErrNotFound
+    }
+    return data, err
+}
+
+func (s *DiskStore) Delete(name string) error {
+    p, err := s.path(name)
+    if err != nil {
+        return err
+    }
+    err = os.Remove(p)
+    if os.IsNotExist(err) {
+        return ErrNotFound
+    }
+    return err
+}
+
+func (s *DiskStore) List(prefix string) ([]BlobInfo, error) {
+    var bs []BlobInfo
+
+    err := filepath.Walk(s.Dir, func(p string, fi os.FileInfo, err error) error {
+        if err != nil {
+            return err
+        }
+        if fi.IsDir() || strings.HasPrefix(fi.Name(), ".tmp-") {
+            return nil
+        }
+        rel, err := filepath.Rel(s.Dir, p)
+        if err != nil {
+            return err
+        }
+        name := filepath.ToSlash(rel)
+        if strings.HasPrefix(name, prefix) {
+            bs = append(bs, BlobInfo{
+                Name: name,
+                Size: fi.Size(),
+                Modified: fi.ModTime(),
+            })
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    sort.Slice(bs, func(i, j int) bool {
+        return bs[i].Name < bs[j].Name
+    })
+
+    return bs, nil
+}
@@ -0,0 +1,80 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package store
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+func TestDiskStore(t *testing.T) {
+    root, err := ioutil.TempDir("", "tidepool-store")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(root)
+
+    s, err := NewDiskStore(filepath.Join(root, "blobs"))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if err := s.Put("runs/a.bin", []byte("a")); err != nil {
+        t.Fatal(err)
+    }
+    if data, err := s.Get("runs/a.bin"); err != nil || string(data) != "a" {
+        t.Fatalf("expected blob, got %q (%v)", data, err)
+    }
+    bs, err := s.List("runs/")
+    if err != nil || len(bs) != 1 || bs[0].Name != "runs/a.bin" {
+        t.Fatalf("unexpected listing %+v (%v)", bs, err)
+    }
+    if err := s.Delete("runs/a.bin"); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := s.Get("runs/a.bin"); err != ErrNotFound {
+        t.Fatalf("expected ErrNotFound, got %v", err)
+    }
+}
+
+func TestDiskStoreEscape(t *testing.T) {
+    root, err := ioutil.TempDir("", "tidepool-store")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(root)
+
+    s, err := NewDiskStore(filepath.Join(root, "blobs"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    outside := filepath.Join(root, "outside")
+    if err := ioutil.WriteFile(outside, []byte("x"), 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    for _, name := range []string{"", ".", "..", "../outside", "runs/../../outside", "/outside", filepath.ToSlash(outside)} {
+        if err := s.Put(name, []byte("y")); err != ErrInvalidBlobName {
+            t.Fatalf("Put %q: expected ErrInvalidBlobName, got %v", name, err)
+        }
+        if _, err := s.Get(name); err != ErrInvalidBlobName {
+            t.Fatalf("Get %q: expected ErrInvalidBlobName, got %v", name, err)
+        }
+        if err := s.Delete(name); err != ErrInvalidBlobName {
+            t.Fatalf("Delete %q: expected ErrInvalidBlobName, got %v", name, err)
+        }
+    }
+
+    if data, err := ioutil.ReadFile(outside); err != nil || string(data) != "x" {
+        t.Fatalf("expected file outside the store to be untouched, got %q (%v)", data, err)
+    }
+
+    if err := s.Put("runs/../a.bin", []byte("a")); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := s.Get("a.bin"); err != nil {
+        t.Fatal(err)
+    }
+}
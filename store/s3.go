@@ -0,0 +1,235 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package store
+
+import (
+    "bytes"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/xml"
+    "fmt"
+    "io/ioutil"
+    "net/http"
+    "net/url"
+    "sort"
+    "strings"
+    "time"
+)
+
+type S3Store struct {
+    Endpoint string
+    Bucket string
+    Region string
+    AccessKey string
+    SecretKey string
+    Prefix string
+    Client *http.Client
+}
+
+type s3ListResult struct {
+    Contents []struct {
+        Key string
+        Size int64
+        LastModified time.Time
+    }
+    IsTruncated bool
+    NextContinuationToken string
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+    h := hmac.New(sha256.New, key)
+    h.Write([]byte(s))
+    return h.Sum(nil)
+}
+
+func hashHex(b []byte) string {
+    h := sha256.Sum256(b)
+    return hex.EncodeToString(h[:])
+}
+
+func s3Escape(s string, slash bool) string {
+    var b strings.Builder
+    for _, c := range []byte(s) {
+        if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') ||
+            ('0' <= c && c <= '9') || c == '-' || c == '_' || c == '.' ||
+            c == '~' || (slash && c == '/') {
+            b.WriteByte(c)
+        } else {
+            fmt.Fprintf(&b, "%%%02X", c)
+        }
+    }
+    return b.String()
+}
+
+func (s *S3Store) client() *http.Client {
+    if s.Client != nil {
+        return s.Client
+    }
+    return http.DefaultClient
+}
+
+func (s *S3Store) key(name string) string {
+    return s.Prefix + name
+}
+
+func (s *S3Store) do(method, key string, query url.Values, body []byte) (*http.Response, error) {
+    u, err := url.Parse(s.Endpoint)
+    if err != nil {
+        return nil, err
+    }
+
+    path := "/" + s.Bucket
+    if key != "" {
+        path += "/" + key
+    }
+    escPath := s3Escape(path, true)
+
+    keys := make([]string, 0, len(query))
+    for k := range query {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    qs := make([]string, 0, len(keys))
+    for _, k := range keys {
+        qs = append(qs, s3Escape(k, false) + "=" + s3Escape(query.Get(k), false))
+    }
+    rawQuery := strings.Join(qs, "&")
+
+    now := time.Now().UTC()
+    amzDate := now.Format("20060102T150405Z")
+    date := now.Format("20060102")
+    payloadHash := hashHex(body)
+
+    canonical := strings.Join([]string{
+        method,
+        escPath,
+        rawQuery,
+        "host:" + u.Host + "\n" +
+            "x-amz-content-sha256:" + payloadHash + "\n" +
+            "x-amz-date:" + amzDate + "\n",
+        "host;x-amz-content-sha256;x-amz-date",
+        payloadHash,
+    }, "\n")
+
+    scope := date + "/" + s.Region + "/s3/aws4_request"
+    toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" +
+        hashHex([]byte(canonical))
+
+    k := hmacSHA256([]byte("AWS4" + s.SecretKey), date)
+    k = hmacSHA256(k, s.Region)
+    k = hmacSHA256(k, "s3")
+    k = hmacSHA256(k, "aws4_request")
+    sig := hex.EncodeToString(hmacSHA256(k, toSign))
+
+    req, err := http.NewRequest(method, u.Scheme + "://" + u.Host + escPath, bytes.NewReader(body))
+    if err != nil {
+        return nil, err
+    }
+    req.URL.RawQuery = rawQuery
+    req.Header.Set("x-amz-content-sha256", payloadHash)
+    req.Header.Set("x-amz-date", amzDate)
+    req.Header.Set("Authorization", fmt.Sprintf(
+        "AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=%s",
+        s.AccessKey, scope, sig))
+
+    return s.client().Do(req)
+}
+
+func s3Error(resp *http.Response) error {
+    msg, _ := ioutil.ReadAll(resp.Body)
+    return fmt.Errorf("store: s3 %s: %s", resp.Status, msg)
+}
+
+func (s *S3Store) Put(name string, data []byte) error {
+    resp, err := s.do(http.MethodPut, s.key(name), nil, data)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        return s3Error(resp)
+    }
+    return nil
+}
+
+func (s *S3Store) Get(name string) ([]byte, error) {
+    resp, err := s.do(http.MethodGet, s.key(name), nil, nil)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+
+    switch resp.StatusCode {
+    case http.StatusOK:
+        return ioutil.ReadAll(resp.Body)
+    case http.StatusNotFound:
+        return nil, ErrNotFound
+    default:
+        return nil, s3Error(resp)
+    }
+}
+
+func (s *S3Store) Delete(name string) error {
+    resp, err := s.do(http.MethodDelete, s.key(name), nil, nil)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+
+    switch resp.StatusCode {
+    case http.StatusOK, http.StatusNoContent:
+        return nil
+    case http.StatusNotFound:
+        return ErrNotFound
+    default:
+        return s3Error(resp)
+    }
+}
+
+func (s *S3Store) List(prefix string) ([]BlobInfo, error) {
+    var bs []BlobInfo
+    token := ""
+
+    for {
+        q := url.Values{}
+        q.Set("list-type", "2")
+        q.Set("prefix", s.key(prefix))
+        if token != "" {
+            q.Set("continuation-token", token)
+        }
+
+        resp, err := s.do(http.MethodGet, "", q, nil)
+        if err != nil {
+            return nil, err
+        }
+        if resp.StatusCode != http.StatusOK {
+            err := s3Error(resp)
+            resp.Body.Close()
+            return nil, err
+        }
+
+        var res s3ListResult
+        err = xml.NewDecoder(resp.Body).Decode(&res)
+        resp.Body.Close()
+        if err != nil {
+            return nil, err
+        }
+
+        for _, c := range res.Contents {
+            bs = append(bs, BlobInfo{
+                Name: strings.TrimPrefix(c.Key, s.Prefix),
+                Size: c.Size,
+                Modified: c.LastModified,
+            })
+        }
+
+        if !res.IsTruncated {
+            break
+        }
+        token = res.NextContinuationToken
+    }
+
+    return bs, nil
+}
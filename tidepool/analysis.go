@@ -0,0 +1,238 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "math"
+    "sort"
+
+    "tidepool/tidepool/gene"
+)
+
+type Mutation struct {
+    Tick int64
+    CellID int64
+    Origin int64
+    Idx int32
+    Old gene.Gene
+    New gene.Gene
+    Register bool
+    Fixed bool
+    ParentHash uint64
+}
+
+const maxAncestry = 1 << 20
+
+type ancestor struct {
+    parent int64
+    born int64
+}
+
+type SubstitutionRate struct {
+    Origin int64
+    Substitutions int64
+    FirstTick int64
+    LastTick int64
+    Rate float64
+}
+
+func (e *Env) Mutations(origin int64) []Mutation {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+
+    log := e.mutations[origin]
+    ms := make([]Mutation, len(log))
+    copy(ms, log)
+
+    return ms
+}
+
+func (e *Env) MutationLineages() []int64 {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+
+    origins := make([]int64, 0, len(e.mutations))
+    for o := range e.mutations {
+        origins = append(origins, o)
+    }
+    sort.Slice(origins, func(i, j int) bool {
+        return origins[i] < origins[j]
+    })
+
+    return origins
+}
+
+func NewSubstitutionRate(origin int64, ms []Mutation) SubstitutionRate {
+    r := SubstitutionRate{Origin: origin}
+
+    for _, m := range ms {
+        if !m.Fixed {
+            continue
+        }
+        if r.Substitutions == 0 || m.Tick < r.FirstTick {
+            r.FirstTick = m.Tick
+        }
+        if m.Tick > r.LastTick {
+            r.LastTick = m.Tick
+        }
+        r.Substitutions++
+    }
+
+    if span := r.LastTick - r.FirstTick; span > 0 {
+        r.Rate = float64(r.Substitutions) / float64(span)
+    }
+
+    return r
+}
+
+func (e *Env) SubstitutionRates() []SubstitutionRate {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+
+    rs := make([]SubstitutionRate, 0, len(e.mutations))
+    for o, ms := range e.mutations {
+        rs = append(rs, NewSubstitutionRate(o, ms))
+    }
+    sort.Slice(rs, func(i, j int) bool {
+        return rs[i].Origin < rs[j].Origin
+    })
+
+    return rs
+}
+
+func (e *Env) SubstitutionRate(origin int64) SubstitutionRate {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+    return NewSubstitutionRate(origin, e.mutations[origin])
+}
+
+func GenomeDistance(a, b gene.Genome, alphabet int) float64 {
+    n := len(a)
+    if len(b) < n {
+        n = len(b)
+    }
+    if n == 0 || alphabet < 2 {
+        return 0
+    }
+
+    d := 0
+    for i := 0; i < n; i++ {
+        if a[i] != b[i] {
+            d++
+        }
+    }
+
+    p := float64(d) / float64(n)
+    k := float64(alphabet)
+    x := 1 - p * k / (k - 1)
+    if x <= 0 {
+        return math.Inf(1)
+    }
+
+    return -(k - 1) / k * math.Log(x) * float64(n)
+}
+
+func (e *Env) logBirths(dt *Delta) {
+    for _, ev := range dt.Events {
+        if ev.Type != EventBirth || ev.Cell == nil {
+            continue
+        }
+        e.ancestry[ev.Cell.ID] = ancestor{ev.Cell.Parent, ev.Cell.Born}
+    }
+    if len(e.ancestry) > maxAncestry {
+        borns := make([]int64, 0, len(e.ancestry))
+        for _, a := range e.ancestry {
+            borns = append(borns, a.born)
+        }
+        sort.Slice(borns, func(i, j int) bool {
+            return borns[i] < borns[j]
+        })
+        e.pruneAncestry(borns[len(borns) / 2])
+    }
+}
+
+func (e *Env) pruneAncestry(cutoff int64) {
+    for id, a := range e.ancestry {
+        if a.born <= cutoff {
+            delete(e.ancestry, id)
+        }
+    }
+}
+
+func (e *Env) ancestors(id int64) []int64 {
+    ids := []int64{id}
+    for {
+        a, ok := e.ancestry[id]
+        if !ok || a.parent == 0 {
+            return ids
+        }
+        id = a.parent
+        ids = append(ids, id)
+    }
+}
+
+func (e *Env) branchRate(origin int64, branch []int64) (int64, int64) {
+    if len(branch) == 0 {
+        return 0, 0
+    }
+
+    cells := make(map[int64]bool, len(branch))
+    for _, id := range branch {
+        cells[id] = true
+    }
+
+    var subs int64
+    for _, m := range e.mutations[origin] {
+        if m.Fixed && cells[m.CellID] {
+            subs++
+        }
+    }
+
+    span := e.Ticks() - e.ancestry[branch[len(branch) - 1]].born
+    return subs, span
+}
+
+func (e *Env) truncatedBranch(branch []int64) bool {
+    return len(branch) > 0 && e.ancestry[branch[len(branch) - 1]].born <= e.truncated
+}
+
+func (e *Env) DivergenceTime(a, b *Cell) (float64, bool) {
+    if a.Origin != b.Origin {
+        return 0, false
+    }
+
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+
+    pa := e.ancestors(a.ID)
+    pb := e.ancestors(b.ID)
+
+    depth := make(map[int64]int, len(pa))
+    for i, id := range pa {
+        depth[id] = i
+    }
+    found := false
+    for i, id := range pb {
+        if j, ok := depth[id]; ok {
+            pa, pb = pa[:j], pb[:i]
+            found = true
+            break
+        }
+    }
+    if !found {
+        return 0, false
+    }
+    if e.truncated > 0 && (e.truncatedBranch(pa) || e.truncatedBranch(pb)) {
+        return 0, false
+    }
+
+    sa, ta := e.branchRate(a.Origin, pa)
+    sb, tb := e.branchRate(b.Origin, pb)
+    if ta + tb <= 0 || sa + sb == 0 {
+        return 0, false
+    }
+    rate := float64(sa + sb) / float64(ta + tb)
+
+    alphabet := e.GetConfig().Alphabet()
+    return GenomeDistance(a.Genome, b.Genome, alphabet) / (2 * rate), true
+}
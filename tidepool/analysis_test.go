@@ -0,0 +1,176 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "math"
+    "reflect"
+    "testing"
+
+    "tidepool/tidepool/gene"
+)
+
+func TestSubstitutionRateFixed(t *testing.T) {
+    r := NewSubstitutionRate(1, []Mutation{
+        {Tick: 10, Fixed: true},
+        {Tick: 20},
+        {Tick: 30, Register: true},
+        {Tick: 50, Register: true, Fixed: true},
+    })
+    if r.Substitutions != 2 || r.FirstTick != 10 || r.LastTick != 50 {
+        t.Fatalf("expected 2 fixed substitutions over 10..50, got %+v", r)
+    }
+}
+
+func TestGenomeDistanceAlphabet(t *testing.T) {
+    a := gene.Genome{0, 1, 2, 3, 4, 5, 6, 7}
+    b := gene.Genome{0, 1, 2, 3, 4, 5, 7, 6}
+
+    if GenomeDistance(a, a, 16) != 0 {
+        t.Fatal("expected zero distance between identical genomes")
+    }
+    if d4, d16 := GenomeDistance(a, b, 4), GenomeDistance(a, b, 16); d4 <= d16 {
+        t.Fatalf("expected smaller alphabet to correct more, got %v <= %v", d4, d16)
+    }
+    for _, k := range []int{0, 1} {
+        if d := GenomeDistance(a, b, k); d != 0 {
+            t.Fatalf("expected zero distance for alphabet %d, got %v", k, d)
+        }
+    }
+}
+
+func TestDivergenceTime(t *testing.T) {
+    env := NewEnv(8, 8, 8, 0, 1)
+    config := env.GetConfig()
+    config.RecordMutations = true
+    env.SetConfig(config)
+
+    birth := func(id, parent, born int64) *Event {
+        return &Event{Type: EventBirth, Cell: &Cell{ID: id, Parent: parent, Origin: 1, Born: born}}
+    }
+    env.applyDelta(&Delta{
+        Tick: 30,
+        Stats: make(Stats),
+        Events: []*Event{birth(2, 1, 10), birth(3, 2, 20), birth(4, 2, 30)},
+        Mutations: []Mutation{
+            {Tick: 5, CellID: 1, Origin: 1, Fixed: true},
+            {Tick: 15, CellID: 2, Origin: 1, Fixed: true},
+            {Tick: 25, CellID: 3, Origin: 1, Fixed: true},
+            {Tick: 26, CellID: 3, Origin: 1, Fixed: true},
+            {Tick: 35, CellID: 4, Origin: 1, Fixed: true},
+            {Tick: 36, CellID: 4, Origin: 1},
+            {Tick: 37, CellID: 4, Origin: 1},
+        },
+    })
+    env.ticks = 50
+
+    if got := env.ancestors(3); !reflect.DeepEqual(got, []int64{3, 2, 1}) {
+        t.Fatalf("unexpected ancestry %v", got)
+    }
+
+    a := &Cell{ID: 3, Origin: 1, Genome: gene.Genome{0, 1, 2, 3, 4, 5, 6, 7}}
+    b := &Cell{ID: 4, Origin: 1, Genome: gene.Genome{0, 1, 2, 3, 4, 5, 7, 6}}
+
+    d, ok := env.DivergenceTime(a, b)
+    if !ok {
+        t.Fatal("expected divergence time")
+    }
+    rate := 3.0 / float64((50 - 20) + (50 - 30))
+    want := GenomeDistance(a.Genome, b.Genome, config.Alphabet()) / (2 * rate)
+    if math.Abs(d - want) > 1e-9 {
+        t.Fatalf("expected %v, got %v", want, d)
+    }
+
+    b.Origin = 2
+    if _, ok := env.DivergenceTime(a, b); ok {
+        t.Fatal("expected no divergence time across lineages")
+    }
+    b.Origin, b.ID = 1, 9
+    if _, ok := env.DivergenceTime(a, b); ok {
+        t.Fatal("expected no divergence time without a common ancestor")
+    }
+}
+
+func TestDivergenceTimeTruncated(t *testing.T) {
+    env := NewEnv(8, 8, 8, 0, 1)
+    config := env.GetConfig()
+    config.RecordMutations = true
+    config.HistoryBudget = 3 * (mapEntryOverhead + 8 + ancestorSize) + mutationSize / 2
+    env.SetConfig(config)
+
+    birth := func(id, parent, born int64) *Event {
+        return &Event{Type: EventBirth, Cell: &Cell{ID: id, Parent: parent, Origin: 1, Born: born}}
+    }
+    env.applyDelta(&Delta{
+        Tick: 30,
+        Stats: make(Stats),
+        Events: []*Event{birth(2, 1, 10), birth(3, 2, 20), birth(4, 2, 30)},
+        Mutations: []Mutation{
+            {Tick: 15, CellID: 2, Origin: 1, Fixed: true},
+            {Tick: 25, CellID: 3, Origin: 1, Fixed: true},
+            {Tick: 35, CellID: 4, Origin: 1, Fixed: true},
+        },
+    })
+    env.ticks = 50
+
+    r := env.MemoryUsage()
+    if r.HistoryEvicted != 3 || r.HistoryTruncated != 35 {
+        t.Fatalf("expected 3 evictions truncated at 35, got %+v", r)
+    }
+    if got := env.ancestors(3); !reflect.DeepEqual(got, []int64{3, 2, 1}) {
+        t.Fatalf("expected ancestry to survive eviction, got %v", got)
+    }
+
+    a := &Cell{ID: 3, Origin: 1, Genome: gene.Genome{0, 1, 2, 3}}
+    b := &Cell{ID: 4, Origin: 1, Genome: gene.Genome{0, 1, 3, 2}}
+    if _, ok := env.DivergenceTime(a, b); ok {
+        t.Fatal("expected no divergence time across truncated history")
+    }
+
+    config.HistoryBudget = 0
+    env.SetConfig(config)
+    env.applyDelta(&Delta{
+        Tick: 60,
+        Stats: make(Stats),
+        Events: []*Event{birth(5, 3, 55), birth(6, 3, 58)},
+        Mutations: []Mutation{
+            {Tick: 56, CellID: 5, Origin: 1, Fixed: true},
+            {Tick: 59, CellID: 6, Origin: 1, Fixed: true},
+        },
+    })
+    env.ticks = 70
+
+    a.ID, b.ID = 5, 6
+    d, ok := env.DivergenceTime(a, b)
+    if !ok {
+        t.Fatal("expected divergence time after truncation")
+    }
+    rate := 2.0 / float64((70 - 55) + (70 - 58))
+    want := GenomeDistance(a.Genome, b.Genome, config.Alphabet()) / (2 * rate)
+    if math.Abs(d - want) > 1e-9 {
+        t.Fatalf("expected %v, got %v", want, d)
+    }
+}
+
+func TestMutationLineagesSorted(t *testing.T) {
+    env := NewEnv(8, 8, 8, 0, 1)
+    config := env.GetConfig()
+    config.RecordMutations = true
+    env.SetConfig(config)
+
+    var ms []Mutation
+    for _, o := range []int64{9, 3, 7, 1, 5, 8, 2} {
+        ms = append(ms, Mutation{Tick: o, CellID: o, Origin: o, Fixed: true})
+    }
+    env.applyDelta(&Delta{Stats: make(Stats), Mutations: ms})
+
+    origins := env.MutationLineages()
+    if !reflect.DeepEqual(origins, []int64{1, 2, 3, 5, 7, 8, 9}) {
+        t.Fatalf("expected lineages sorted by origin, got %v", origins)
+    }
+    for i, r := range env.SubstitutionRates() {
+        if r.Origin != origins[i] {
+            t.Fatalf("expected rates sorted by origin, got %d at %d", r.Origin, i)
+        }
+    }
+}
@@ -0,0 +1,65 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "tidepool/tidepool/gene"
+)
+
+type cellArena struct {
+    cells []Cell
+    genomes gene.Genome
+    size int
+}
+
+func newCellArena(n int, genomeSize int32) *cellArena {
+    return &cellArena{
+        cells: make([]Cell, n),
+        genomes: make(gene.Genome, n * int(genomeSize)),
+        size: int(genomeSize),
+    }
+}
+
+func (a *cellArena) cell(i int) *Cell {
+    c := &a.cells[i]
+    c.Genome = a.genomes[i * a.size:(i + 1) * a.size:(i + 1) * a.size]
+    return c
+}
+
+func newGrid(width, height, genomeSize int32) []*Cell {
+    a := newCellArena(int(width * height), genomeSize)
+    cs := make([]*Cell, width * height)
+
+    if len(a.genomes) > 0 {
+        a.genomes[0] = gene.STOP
+        for n := 1; n < len(a.genomes); n *= 2 {
+            copy(a.genomes[n:], a.genomes[:n])
+        }
+    }
+
+    for i := range cs {
+        c := a.cell(i)
+        c.Idx = int32(i)
+        c.X = c.Idx % width
+        c.Y = c.Idx / width
+        cs[i] = c
+    }
+
+    return cs
+}
+
+func compactGrid(cs []*Cell, genomeSize int32) []*Cell {
+    a := newCellArena(len(cs), genomeSize)
+
+    for i, s := range cs {
+        c := a.cell(i)
+        if len(s.Genome) == len(c.Genome) {
+            c.copyFrom(s)
+        } else {
+            *c = *s
+        }
+        cs[i] = c
+    }
+
+    return cs
+}
@@ -0,0 +1,383 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "encoding/binary"
+    "io"
+    "sort"
+)
+
+const (
+    arrowMetadataV5 = 4
+
+    arrowHeaderSchema = 1
+    arrowHeaderRecordBatch = 3
+
+    arrowTypeInt = 2
+    arrowTypeUtf8 = 5
+)
+
+const (
+    arrowInt64 = iota
+    arrowUint64
+    arrowUtf8
+)
+
+type arrowField struct {
+    name string
+    kind int
+}
+
+type arrowColumn struct {
+    ints []int64
+    strs []string
+}
+
+type fbField struct {
+    size int
+    scalar uint64
+    child func(b *fbBuilder) int
+}
+
+type fbBuilder struct {
+    buf []byte
+}
+
+func (b *fbBuilder) pad(n int) {
+    for len(b.buf) % n != 0 {
+        b.buf = append(b.buf, 0)
+    }
+}
+
+func (b *fbBuilder) putU32(pos int, v uint32) {
+    binary.LittleEndian.PutUint32(b.buf[pos:], v)
+}
+
+func (b *fbBuilder) table(fields []fbField) int {
+    offs := make([]int, len(fields))
+    order := make([]int, 0, len(fields))
+    for i, f := range fields {
+        if f.size > 0 {
+            order = append(order, i)
+        }
+    }
+    sort.SliceStable(order, func(i, j int) bool {
+        return fields[order[i]].size > fields[order[j]].size
+    })
+
+    size := 4
+    for _, i := range order {
+        n := fields[i].size
+        size = (size + n - 1) / n * n
+        offs[i] = size
+        size += n
+    }
+    size = (size + 3) / 4 * 4
+
+    b.pad(2)
+    vtable := len(b.buf)
+    b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(4 + 2 * len(fields)))
+    b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(size))
+    for _, off := range offs {
+        b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(off))
+    }
+
+    b.pad(8)
+    table := len(b.buf)
+    b.buf = append(b.buf, make([]byte, size)...)
+    b.putU32(table, uint32(table - vtable))
+
+    for _, i := range order {
+        f := fields[i]
+        pos := table + offs[i]
+        if f.child != nil {
+            continue
+        }
+        for j := 0; j < f.size; j++ {
+            b.buf[pos + j] = byte(f.scalar >> (8 * j))
+        }
+    }
+    for _, i := range order {
+        if f := fields[i]; f.child != nil {
+            pos := table + offs[i]
+            b.putU32(pos, uint32(f.child(b) - pos))
+        }
+    }
+
+    return table
+}
+
+func (b *fbBuilder) tables(children []func(b *fbBuilder) int) int {
+    b.pad(4)
+    vec := len(b.buf)
+    b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(children)))
+    b.buf = append(b.buf, make([]byte, 4 * len(children))...)
+    for i, child := range children {
+        pos := vec + 4 + 4 * i
+        b.putU32(pos, uint32(child(b) - pos))
+    }
+    return vec
+}
+
+func (b *fbBuilder) longPairs(vs [][2]int64) int {
+    for len(b.buf) % 8 != 4 {
+        b.buf = append(b.buf, 0)
+    }
+    vec := len(b.buf)
+    b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(vs)))
+    for _, v := range vs {
+        b.buf = binary.LittleEndian.AppendUint64(b.buf, uint64(v[0]))
+        b.buf = binary.LittleEndian.AppendUint64(b.buf, uint64(v[1]))
+    }
+    return vec
+}
+
+func (b *fbBuilder) string(s string) int {
+    b.pad(4)
+    pos := len(b.buf)
+    b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(s)))
+    b.buf = append(b.buf, s...)
+    b.buf = append(b.buf, 0)
+    return pos
+}
+
+func (b *fbBuilder) finish(root func(b *fbBuilder) int) []byte {
+    b.buf = append(b.buf[:0], 0, 0, 0, 0)
+    b.putU32(0, uint32(root(b)))
+    b.pad(8)
+    return b.buf
+}
+
+func fbScalar(size int, v uint64) fbField {
+    return fbField{size: size, scalar: v}
+}
+
+func fbOffset(child func(b *fbBuilder) int) fbField {
+    return fbField{size: 4, child: child}
+}
+
+func arrowMessage(header int, body int64, fn func(b *fbBuilder) int) []byte {
+    var b fbBuilder
+    return b.finish(func(b *fbBuilder) int {
+        return b.table([]fbField{
+            fbScalar(2, arrowMetadataV5),
+            fbScalar(1, uint64(header)),
+            fbOffset(fn),
+            fbScalar(8, uint64(body)),
+        })
+    })
+}
+
+func (f arrowField) encode(b *fbBuilder) int {
+    typ := func(b *fbBuilder) int {
+        signed := uint64(1)
+        if f.kind == arrowUint64 {
+            signed = 0
+        }
+        return b.table([]fbField{fbScalar(4, 64), fbScalar(1, signed)})
+    }
+    kind := uint64(arrowTypeInt)
+    if f.kind == arrowUtf8 {
+        kind = arrowTypeUtf8
+        typ = func(b *fbBuilder) int {
+            return b.table(nil)
+        }
+    }
+
+    return b.table([]fbField{
+        fbOffset(func(b *fbBuilder) int { return b.string(f.name) }),
+        fbScalar(1, 0),
+        fbScalar(1, kind),
+        fbOffset(typ),
+        {},
+        fbOffset(func(b *fbBuilder) int { return b.tables(nil) }),
+    })
+}
+
+type arrowWriter struct {
+    w io.Writer
+    fields []arrowField
+}
+
+func (a *arrowWriter) message(meta, body []byte) error {
+    var prefix [8]byte
+    binary.LittleEndian.PutUint32(prefix[:], 0xffffffff)
+    binary.LittleEndian.PutUint32(prefix[4:], uint32(len(meta)))
+    if _, err := a.w.Write(prefix[:]); err != nil {
+        return err
+    }
+    if _, err := a.w.Write(meta); err != nil {
+        return err
+    }
+    _, err := a.w.Write(body)
+    return err
+}
+
+func newArrowWriter(w io.Writer, fields []arrowField) (*arrowWriter, error) {
+    a := &arrowWriter{w: w, fields: fields}
+
+    meta := arrowMessage(arrowHeaderSchema, 0, func(b *fbBuilder) int {
+        fs := make([]func(b *fbBuilder) int, len(fields))
+        for i, f := range fields {
+            fs[i] = f.encode
+        }
+        return b.table([]fbField{
+            fbScalar(2, 0),
+            fbOffset(func(b *fbBuilder) int { return b.tables(fs) }),
+        })
+    })
+
+    return a, a.message(meta, nil)
+}
+
+func (a *arrowWriter) write(n int, cols []arrowColumn) error {
+    var body []byte
+    var nodes, buffers [][2]int64
+
+    add := func(data []byte) {
+        buffers = append(buffers, [2]int64{int64(len(body)), int64(len(data))})
+        body = append(body, data...)
+        for len(body) % 8 != 0 {
+            body = append(body, 0)
+        }
+    }
+
+    for i, f := range a.fields {
+        nodes = append(nodes, [2]int64{int64(n), 0})
+        add(nil)
+
+        c := cols[i]
+        if f.kind != arrowUtf8 {
+            data := make([]byte, 8 * n)
+            for j, v := range c.ints {
+                binary.LittleEndian.PutUint64(data[8 * j:], uint64(v))
+            }
+            add(data)
+            continue
+        }
+
+        offsets := make([]byte, 4 * (n + 1))
+        var data []byte
+        for j, s := range c.strs {
+            data = append(data, s...)
+            binary.LittleEndian.PutUint32(offsets[4 * (j + 1):], uint32(len(data)))
+        }
+        add(offsets)
+        add(data)
+    }
+
+    meta := arrowMessage(arrowHeaderRecordBatch, int64(len(body)), func(b *fbBuilder) int {
+        return b.table([]fbField{
+            fbScalar(8, uint64(n)),
+            fbOffset(func(b *fbBuilder) int { return b.longPairs(nodes) }),
+            fbOffset(func(b *fbBuilder) int { return b.longPairs(buffers) }),
+        })
+    })
+
+    return a.message(meta, body)
+}
+
+func (a *arrowWriter) close() error {
+    var eos [8]byte
+    binary.LittleEndian.PutUint32(eos[:], 0xffffffff)
+    _, err := a.w.Write(eos[:])
+    return err
+}
+
+type ArrowStatsWriter struct {
+    a *arrowWriter
+    metrics []string
+}
+
+func NewArrowStatsWriter(w io.Writer, metrics []string) (*ArrowStatsWriter, error) {
+    fields := make([]arrowField, len(metrics))
+    for i, m := range metrics {
+        fields[i] = arrowField{m, arrowInt64}
+    }
+    a, err := newArrowWriter(w, fields)
+    if err != nil {
+        return nil, err
+    }
+    return &ArrowStatsWriter{a: a, metrics: metrics}, nil
+}
+
+func (s *ArrowStatsWriter) Write(ss ...Stats) error {
+    if len(ss) == 0 {
+        return nil
+    }
+    cols := make([]arrowColumn, len(s.metrics))
+    for i, m := range s.metrics {
+        cols[i].ints = make([]int64, len(ss))
+        for j, st := range ss {
+            cols[i].ints[j] = st[m]
+        }
+    }
+    return s.a.write(len(ss), cols)
+}
+
+func (s *ArrowStatsWriter) Close() error {
+    return s.a.close()
+}
+
+func (h StatsHistory) Metrics() []string {
+    seen := map[string]bool{"Ticks": true}
+    var ms []string
+    for _, s := range h {
+        for m := range s {
+            if !seen[m] {
+                seen[m] = true
+                ms = append(ms, m)
+            }
+        }
+    }
+    sort.Strings(ms)
+    return append([]string{"Ticks"}, ms...)
+}
+
+func ExportStatsArrow(w io.Writer, h StatsHistory) error {
+    s, err := NewArrowStatsWriter(w, h.Metrics())
+    if err != nil {
+        return err
+    }
+    if err := s.Write(h...); err != nil {
+        return err
+    }
+    return s.Close()
+}
+
+func ExportCensusArrow(w io.Writer, cs []CensusEntry) error {
+    a, err := newArrowWriter(w, []arrowField{
+        {"Hash", arrowUint64},
+        {"Count", arrowInt64},
+        {"Energy", arrowInt64},
+        {"MaxGeneration", arrowInt64},
+        {"Genome", arrowUtf8},
+        {"Phenotype", arrowUtf8},
+    })
+    if err != nil {
+        return err
+    }
+
+    if len(cs) > 0 {
+        cols := make([]arrowColumn, 6)
+        for i := 0; i < 4; i++ {
+            cols[i].ints = make([]int64, len(cs))
+        }
+        cols[4].strs = make([]string, len(cs))
+        cols[5].strs = make([]string, len(cs))
+        for i, c := range cs {
+            cols[0].ints[i] = int64(c.Hash)
+            cols[1].ints[i] = c.Count
+            cols[2].ints[i] = c.Energy
+            cols[3].ints[i] = c.MaxGeneration
+            cols[4].strs[i] = c.Genome.String()
+            cols[5].strs[i] = c.Phenotype
+        }
+        if err := a.write(len(cs), cols); err != nil {
+            return err
+        }
+    }
+
+    return a.close()
+}
@@ -0,0 +1,86 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+type EnergyAudit struct {
+    Inflow int64
+    Outflow int64
+    External int64
+    Stored int64
+    Violations int64
+}
+
+func (e *Env) storedEnergyLocked() int64 {
+    var n int64
+    for _, idx := range e.liveCells.all() {
+        n += e.cells[idx].Energy
+    }
+    return n
+}
+
+func (e *Env) auditEnergy(config Config, dt *Delta) {
+    if !config.AuditEnergy {
+        e.audit = nil
+        return
+    }
+    if e.audit == nil {
+        e.audit = &EnergyAudit{Stored: e.storedEnergyLocked()}
+    }
+
+    var actual int64
+    for _, c := range dt.Cells {
+        actual += c.Energy - e.cells[c.Idx].Energy
+    }
+    e.audit.Stored += actual
+
+    if !dt.ledger {
+        e.audit.External += actual
+        return
+    }
+
+    e.audit.Inflow += dt.energyIn
+    e.audit.Outflow += dt.energyOut
+
+    expected := dt.energyIn - dt.energyOut
+    if actual == expected {
+        return
+    }
+
+    e.audit.Violations++
+    dt.Stats.inc("EnergyViolations", 1)
+
+    ev := dt.addEvent(EventEnergyViolation, nil)
+    ev.Values = Stats{
+        "Expected": expected,
+        "Actual": actual,
+        "Inflow": dt.energyIn,
+        "Outflow": dt.energyOut,
+    }
+    if dt.exec {
+        for _, c := range dt.Cells {
+            if c.Idx == dt.execIdx {
+                ev.Cell = c.clone()
+            }
+        }
+    }
+}
+
+func (e *Env) EnergyAudit() (EnergyAudit, bool) {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+
+    if e.audit == nil {
+        return EnergyAudit{}, false
+    }
+    return *e.audit, true
+}
+
+func (e *Env) VerifyEnergy() (EnergyAudit, bool) {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+
+    if e.audit == nil {
+        return EnergyAudit{}, false
+    }
+    return *e.audit, e.audit.Stored == e.storedEnergyLocked()
+}
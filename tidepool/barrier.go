@@ -0,0 +1,55 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "fmt"
+)
+
+func (e *Env) SetBarrier(x, y int32, on bool) error {
+    if x < 0 || y < 0 || x >= e.Width || y >= e.Height {
+        return fmt.Errorf("cell %d,%d out of bounds", x, y)
+    }
+
+    e.mutex.Lock()
+    defer e.mutex.Unlock()
+
+    if on {
+        e.barriers.add(x + e.Width * y)
+    } else {
+        e.barriers.remove(x + e.Width * y)
+    }
+    e.markDirty(x, y)
+
+    return nil
+}
+
+func (e *Env) IsBarrier(x, y int32) bool {
+    if x < 0 || y < 0 || x >= e.Width || y >= e.Height {
+        return false
+    }
+    return e.barriers.has(x + e.Width * y)
+}
+
+func (e *Env) Barriers() []Rect {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+
+    rs := make([]Rect, 0, e.barriers.len())
+    for _, idx := range e.barriers.all() {
+        rs = append(rs, Rect{X: idx % e.Width, Y: idx / e.Width, W: 1, H: 1})
+    }
+    return rs
+}
+
+func (c *Cell) swap(n *Cell) {
+    c.ID, n.ID = n.ID, c.ID
+    c.Origin, n.Origin = n.Origin, c.Origin
+    c.Parent, n.Parent = n.Parent, c.Parent
+    c.Generation, n.Generation = n.Generation, c.Generation
+    c.Born, n.Born = n.Born, c.Born
+    c.Execs, n.Execs = n.Execs, c.Execs
+    c.Energy, n.Energy = n.Energy, c.Energy
+    c.Dormant, n.Dormant = n.Dormant, c.Dormant
+    c.Genome, n.Genome = n.Genome, c.Genome
+}
@@ -0,0 +1,173 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "fmt"
+    "math/rand"
+    "os"
+    "path/filepath"
+    "runtime"
+    "sync"
+)
+
+type BatchOptions struct {
+    Width int32
+    Height int32
+    GenomeSize int32
+    InitPop int32
+    Ticks int64
+    Parallel int
+    SampleEvery int64
+    StopOnExtinction bool
+    SnapshotDir string
+    Perturb *PerturbationSchedule
+    StopWhen func(Stats) bool `json:"-"`
+}
+
+type BatchSample struct {
+    Tick int64
+    LiveCells int64
+    ViableLiveCells int64
+    Diversity int64
+}
+
+type RunReport struct {
+    RunID string
+    Seed int64
+    Ticks int64
+    Stats Stats
+    FirstReplicationTick int64
+    FirstViableTick int64
+    ExtinctionTick int64
+    Diversity int64
+    Samples []BatchSample `json:",omitempty"`
+    Dominant []GenomeRank `json:",omitempty"`
+    Snapshot string `json:",omitempty"`
+    Scenario *Scenario `json:",omitempty"`
+    PrePerturbation int64 `json:",omitempty"`
+    Err error `json:"-"`
+}
+
+func (r *RunReport) Extinct() bool {
+    return r.ExtinctionTick > 0
+}
+
+func RunBatch(cfg Config, seeds []int64, opts BatchOptions) []RunReport {
+    reports := make([]RunReport, len(seeds))
+
+    n := opts.Parallel
+    if n < 1 {
+        n = runtime.NumCPU()
+    }
+
+    jobs := make(chan int)
+    var wg sync.WaitGroup
+    wg.Add(n)
+
+    for i := 0; i < n; i++ {
+        go func() {
+            defer wg.Done()
+            for j := range jobs {
+                reports[j] = runReplicate(cfg, seeds[j], opts)
+            }
+        }()
+    }
+
+    for j := range seeds {
+        jobs <- j
+    }
+    close(jobs)
+    wg.Wait()
+
+    return reports
+}
+
+func runReplicate(cfg Config, seed int64, opts BatchOptions) RunReport {
+    e := NewEnv(opts.Width, opts.Height, opts.GenomeSize, opts.InitPop, seed)
+    e.SetConfig(cfg)
+    e.inflowTick = cfg.InflowFrequency
+
+    r := RunReport{
+        RunID: e.RunID(),
+        Seed: e.Seed,
+        Stats: make(Stats),
+    }
+
+    var perturbAt int64
+    if opts.Perturb != nil {
+        r.Scenario = opts.Perturb.Generate(rand.New(rand.NewSource(e.Seed)), e.Width, e.Height)
+        if err := e.SetScenario(r.Scenario); err != nil {
+            r.Err = err
+            return r
+        }
+        if len(r.Scenario.Steps) > 0 {
+            perturbAt = r.Scenario.Steps[0].Tick
+        }
+    }
+
+    var dts []*Delta
+    for e.Ticks() < opts.Ticks {
+        dts = e.AdvanceInto(dts[:0])
+
+        for _, dt := range dts {
+            r.Stats.Add(dt.Stats)
+            if r.FirstReplicationTick == 0 && dt.Stats["Reproductions"] > 0 {
+                r.FirstReplicationTick = dt.Tick
+            }
+            if r.FirstViableTick == 0 && dt.Stats["ViableLiveCells"] > 0 {
+                r.FirstViableTick = dt.Tick
+            }
+            for _, ev := range dt.Events {
+                if ev.Type == EventExtinction && r.ExtinctionTick == 0 {
+                    r.ExtinctionTick = ev.Tick
+                }
+            }
+            dt.Release()
+        }
+
+        if e.Ticks() < perturbAt {
+            r.PrePerturbation = r.Stats["LiveCells"]
+        }
+
+        if opts.SampleEvery > 0 && e.Ticks() % opts.SampleEvery == 0 {
+            r.Samples = append(r.Samples, BatchSample{
+                Tick: e.Ticks(),
+                LiveCells: r.Stats["LiveCells"],
+                ViableLiveCells: r.Stats["ViableLiveCells"],
+                Diversity: e.Diversity(),
+            })
+        }
+
+        if opts.StopOnExtinction && r.ExtinctionTick > 0 {
+            break
+        }
+        if opts.StopWhen != nil && opts.StopWhen(r.Stats) {
+            break
+        }
+    }
+
+    r.Ticks = e.Ticks()
+    r.Diversity = e.Diversity()
+    r.Dominant = e.TopGenomes()
+
+    if opts.SnapshotDir != "" {
+        r.Snapshot, r.Err = writeSnapshot(e, opts.SnapshotDir)
+    }
+
+    return r
+}
+
+func writeSnapshot(e *Env, dir string) (string, error) {
+    data, err := e.MarshalBinary()
+    if err != nil {
+        return "", err
+    }
+
+    path := filepath.Join(dir, fmt.Sprintf("seed-%d.bin", e.Seed))
+    if err := os.WriteFile(path, data, 0644); err != nil {
+        return "", err
+    }
+
+    return path, nil
+}
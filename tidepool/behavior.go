@@ -0,0 +1,55 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+type Action int
+
+const (
+    ActionKill Action = iota
+    ActionShare
+    ActionReplicate
+    ActionMove
+)
+
+type Behavior interface {
+    Allow(ctx *Context, a Action, c *Cell, n *Cell) bool
+    Apply(ctx *Context, a Action, c *Cell, n *Cell)
+}
+
+type BaseBehavior struct{}
+
+func (BaseBehavior) Allow(ctx *Context, a Action, c *Cell, n *Cell) bool {
+    return true
+}
+
+func (BaseBehavior) Apply(ctx *Context, a Action, c *Cell, n *Cell) {}
+
+func (e *Env) AddBehavior(b Behavior) {
+    e.behaviorMutex.Lock()
+    defer e.behaviorMutex.Unlock()
+
+    bs := e.GetBehaviors()
+    n := make([]Behavior, len(bs), len(bs) + 1)
+    copy(n, bs)
+    e.behaviors.Store(append(n, b))
+}
+
+func (e *Env) GetBehaviors() []Behavior {
+    bs, _ := e.behaviors.Load().([]Behavior)
+    return bs
+}
+
+func (vm *VM) allow(a Action, c *Cell, n *Cell) bool {
+    for _, b := range vm.ctx.env.GetBehaviors() {
+        if !b.Allow(vm.ctx, a, c, n) {
+            return false
+        }
+    }
+    return true
+}
+
+func (vm *VM) apply(a Action, c *Cell, n *Cell) {
+    for _, b := range vm.ctx.env.GetBehaviors() {
+        b.Apply(vm.ctx, a, c, n)
+    }
+}
@@ -0,0 +1,150 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "fmt"
+    "sort"
+    "sync/atomic"
+
+    "tidepool/tidepool/gene"
+)
+
+type Breakpoint struct {
+    CellID int64
+    GenomeIdx int32
+}
+
+type BreakpointHit struct {
+    Breakpoint
+    Tick int64
+    State TraceStep
+    LoopDepth int32
+    Buffer gene.Genome
+    Genome gene.Genome
+}
+
+func (e *Env) SetBreakpoint(id int64, idx int32) error {
+    if id == 0 {
+        return ErrCellNotFound
+    }
+    if idx < 0 || idx >= e.GenomeSize {
+        return fmt.Errorf("genome index %d out of range", idx)
+    }
+
+    e.breakMutex.Lock()
+    defer e.breakMutex.Unlock()
+
+    if e.breakpoints == nil {
+        e.breakpoints = make(map[int64]map[int32]bool)
+    }
+    bs := e.breakpoints[id]
+    if bs == nil {
+        bs = make(map[int32]bool)
+        e.breakpoints[id] = bs
+    }
+    if !bs[idx] {
+        bs[idx] = true
+        atomic.AddInt32(&e.breaking, 1)
+    }
+
+    return nil
+}
+
+func (e *Env) ClearBreakpoint(id int64, idx int32) {
+    e.breakMutex.Lock()
+    defer e.breakMutex.Unlock()
+
+    bs := e.breakpoints[id]
+    if !bs[idx] {
+        return
+    }
+    delete(bs, idx)
+    if len(bs) == 0 {
+        delete(e.breakpoints, id)
+    }
+    atomic.AddInt32(&e.breaking, -1)
+}
+
+func (e *Env) ClearBreakpoints() {
+    e.breakMutex.Lock()
+    e.breakpoints = nil
+    e.lastBreak = nil
+    atomic.StoreInt32(&e.breaking, 0)
+    e.breakMutex.Unlock()
+}
+
+func (e *Env) Breakpoints() []Breakpoint {
+    e.breakMutex.Lock()
+    defer e.breakMutex.Unlock()
+
+    var bs []Breakpoint
+    for id, idxs := range e.breakpoints {
+        for idx := range idxs {
+            bs = append(bs, Breakpoint{CellID: id, GenomeIdx: idx})
+        }
+    }
+    sort.Slice(bs, func(i, j int) bool {
+        if bs[i].CellID != bs[j].CellID {
+            return bs[i].CellID < bs[j].CellID
+        }
+        return bs[i].GenomeIdx < bs[j].GenomeIdx
+    })
+
+    return bs
+}
+
+func (e *Env) LastBreak() (BreakpointHit, bool) {
+    e.breakMutex.Lock()
+    defer e.breakMutex.Unlock()
+
+    if e.lastBreak == nil {
+        return BreakpointHit{}, false
+    }
+    return *e.lastBreak, true
+}
+
+func (e *Env) takeBreakpoints(id int64) map[int32]bool {
+    e.breakMutex.Lock()
+    defer e.breakMutex.Unlock()
+
+    bs := e.breakpoints[id]
+    if len(bs) == 0 {
+        return nil
+    }
+    m := make(map[int32]bool, len(bs))
+    for idx := range bs {
+        m[idx] = true
+    }
+    return m
+}
+
+func (vm *VM) breakAt(c *Cell, g gene.Gene) *BreakpointHit {
+    ctx := vm.ctx
+    env := ctx.env
+
+    hit := &BreakpointHit{
+        Breakpoint: Breakpoint{CellID: c.ID, GenomeIdx: vm.genomeIdx},
+        Tick: ctx.tick,
+        State: TraceStep{
+            GenomeIdx: vm.genomeIdx,
+            Gene: g,
+            Op: g.Name(),
+            Skipped: vm.loopDepth > 0,
+            Pointer: vm.pointer,
+            Register: vm.register,
+            Direction: vm.direction,
+            Energy: c.Energy,
+        },
+        LoopDepth: vm.loopDepth,
+        Buffer: append(gene.Genome(nil), vm.buffer...),
+        Genome: append(gene.Genome(nil), c.Genome...),
+    }
+
+    env.breakMutex.Lock()
+    env.lastBreak = hit
+    env.breakMutex.Unlock()
+    env.Pause()
+
+    return hit
+}
@@ -0,0 +1,51 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "sync/atomic"
+)
+
+func (e *Env) EnergyBudget() int64 {
+    return atomic.LoadInt64(&e.budget)
+}
+
+func (e *Env) replenishBudget(config Config) {
+    if config.EnergyBudget <= 0 {
+        return
+    }
+
+    for {
+        old := atomic.LoadInt64(&e.budget)
+        n := old + config.EnergyReplenish
+        if n > config.EnergyBudget {
+            n = config.EnergyBudget
+        }
+        if atomic.CompareAndSwapInt64(&e.budget, old, n) {
+            return
+        }
+    }
+}
+
+func (e *Env) drawEnergy(config Config, n int64) int64 {
+    if config.EnergyBudget <= 0 {
+        return n
+    }
+
+    for {
+        old := atomic.LoadInt64(&e.budget)
+        if old <= 0 {
+            return 0
+        }
+        if n > old {
+            n = old
+        }
+        if atomic.CompareAndSwapInt64(&e.budget, old, old - n) {
+            return n
+        }
+    }
+}
+
+func (e *Env) budgetExhausted(config Config) bool {
+    return config.EnergyBudget > 0 && e.EnergyBudget() <= 0
+}
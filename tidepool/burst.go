@@ -0,0 +1,104 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "errors"
+)
+
+var ErrEmptyRegion = errors.New("empty region")
+
+type Rect struct {
+    X int32
+    Y int32
+    W int32
+    H int32
+}
+
+func (r Rect) Contains(x, y int32) bool {
+    return x >= r.X && y >= r.Y && x < r.X + r.W && y < r.Y + r.H
+}
+
+func (r Rect) clip(width, height int32) Rect {
+    if r.X < 0 {
+        r.W += r.X
+        r.X = 0
+    }
+    if r.Y < 0 {
+        r.H += r.Y
+        r.Y = 0
+    }
+    if r.X + r.W > width {
+        r.W = width - r.X
+    }
+    if r.Y + r.H > height {
+        r.H = height - r.Y
+    }
+    if r.W < 0 {
+        r.W = 0
+    }
+    if r.H < 0 {
+        r.H = 0
+    }
+    return r
+}
+
+type burst struct {
+    n int
+    region *Rect
+}
+
+func (e *Env) InflowBurst(n int, region *Rect) error {
+    if n <= 0 {
+        return nil
+    }
+
+    if region != nil {
+        r := region.clip(e.Width, e.Height)
+        if r.W == 0 || r.H == 0 {
+            return ErrEmptyRegion
+        }
+        region = &r
+    }
+
+    e.externalMutex.Lock()
+    e.bursts = append(e.bursts, burst{n, region})
+    e.externalMutex.Unlock()
+
+    return nil
+}
+
+func (e *Env) PendingInflows() int {
+    e.externalMutex.Lock()
+    defer e.externalMutex.Unlock()
+
+    n := 0
+    for _, b := range e.bursts {
+        n += b.n
+    }
+    return n
+}
+
+func (e *Env) takeBursts(rate int64) []*Rect {
+    e.externalMutex.Lock()
+    defer e.externalMutex.Unlock()
+
+    var rs []*Rect
+    for int64(len(rs)) < rate && len(e.bursts) > 0 {
+        b := &e.bursts[0]
+        rs = append(rs, b.region)
+        if b.n--; b.n == 0 {
+            e.bursts = e.bursts[1:]
+        }
+    }
+    return rs
+}
+
+func (e *Env) burstDeltas(ctx *Context, ticks int64, apply func(*Delta)) {
+    for _, r := range e.takeBursts(e.GetConfig().BurstRate) {
+        if dt := e.inflowDeltaIn(ctx, ticks, r); dt != nil {
+            dt.Stats.inc("BurstInflows", 1)
+            apply(dt)
+        }
+    }
+}
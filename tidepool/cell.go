@@ -3,6 +3,9 @@
 package tidepool
 
 import (
+    "bytes"
+    "encoding/gob"
+
     "tidepool/tidepool/gene"
 )
 
@@ -14,15 +17,49 @@ type Cell struct {
     Origin int64
     Parent int64
     Generation int64
+    Born int64 `json:",omitempty"`
+    Execs int64 `json:",omitempty"`
     Energy int64
     X int32
     Y int32
+    Version int64
+    Dormant bool `json:",omitempty"`
     Genome gene.Genome
 }
 
+type cellData Cell
+
 type Delta struct {
+    RunID string `json:",omitempty"`
+    Seq int64
+    Tick int64
+    Keyframe bool `json:",omitempty"`
     Cells []*Cell
     Stats Stats
+    Mutations []Mutation `json:",omitempty"`
+    Interactions []Interaction `json:",omitempty"`
+    ProfileHash uint64 `json:",omitempty"`
+    Profile GeneProfile `json:",omitempty"`
+    Events []*Event `json:",omitempty"`
+    exec bool
+    execIdx int32
+    ledger bool
+    energyIn int64
+    energyOut int64
+    force bool
+    payloads []payloadWrite
+    done chan bool
+    env *Env
+}
+
+func (dt *Delta) minIdx() int32 {
+    idx := int32(-1)
+    for _, c := range dt.Cells {
+        if idx < 0 || c.Idx < idx {
+            idx = c.Idx
+        }
+    }
+    return idx
 }
 
 func newCell(idx, x, y, g int32) *Cell {
@@ -38,9 +75,7 @@ func newCell(idx, x, y, g int32) *Cell {
 }
 
 func (c *Cell) randomizeGenome(ctx *Context) {
-    for i := range c.Genome {
-        c.Genome[i] = ctx.getRandomGene()
-    }
+    ctx.fillRandomGenes(c.Genome)
 }
 
 func (c *Cell) resetGenome() {
@@ -56,7 +91,11 @@ func (c *Cell) clone() *Cell {
     n.Origin = c.Origin
     n.Parent = c.Parent
     n.Generation = c.Generation
+    n.Born = c.Born
+    n.Execs = c.Execs
     n.Energy = c.Energy
+    n.Version = c.Version
+    n.Dormant = c.Dormant
 
     for i, v := range c.Genome {
         n.Genome[i] = v
@@ -73,7 +112,14 @@ func (c *Cell) live() bool {
     return c.Energy > 0
 }
 
+func (c *Cell) dormant() bool {
+    return c.Dormant && c.live()
+}
+
 func (c *Cell) viable(config Config) bool {
+    if config.ViabilityFunc != nil {
+        return config.ViabilityFunc(c)
+    }
     return c.Generation >= config.ViableCellGeneration
 }
 
@@ -85,27 +131,31 @@ func (c *Cell) resetMetadata(ctx *Context) {
     c.resetID(ctx)
     c.Parent = 0
     c.Generation = 0
+    c.Dormant = false
 }
 
 func (c *Cell) resetID(ctx *Context) {
     if c.live() {
-        c.ID = ctx.env.getNextCellID()
+        c.ID = ctx.newCellID()
+        c.Born = ctx.tick
     } else {
         c.ID = 0
+        c.Born = 0
     }
     c.Origin = c.ID
+    c.Execs = 0
 }
 
 func (c *Cell) seed(ctx *Context) *Delta {
-    c.Energy += ctx.env.GetRNG().Energy(ctx)
+    n := ctx.env.drawEnergy(ctx.env.GetConfig(), ctx.env.GetRNG().Energy(ctx))
+    c.Energy += n
     c.resetMetadata(ctx)
     c.randomizeGenome(ctx)
 
-    dt := &Delta{
-        Cells: make([]*Cell, 1),
-        Stats: make(Stats),
-    }
-    dt.Cells[0] = c
+    dt := ctx.env.acquireDelta()
+    dt.Cells = append(dt.Cells, c)
+    dt.ledger = true
+    dt.energyIn = n
 
     return dt
 }
@@ -113,3 +163,15 @@ func (c *Cell) seed(ctx *Context) *Delta {
 func (c *Cell) accessible(ctx *Context, g gene.Gene, x gene.Gene) bool {
     return ctx.env.GetRNG().CellAccessible(ctx, c, g, x)
 }
+
+func (c *Cell) MarshalBinary() ([]byte, error) {
+    var buf bytes.Buffer
+    if err := gob.NewEncoder(&buf).Encode((*cellData)(c)); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+func (c *Cell) UnmarshalBinary(data []byte) error {
+    return gob.NewDecoder(bytes.NewReader(data)).Decode((*cellData)(c))
+}
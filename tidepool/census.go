@@ -0,0 +1,78 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "sort"
+
+    "tidepool/tidepool/gene"
+)
+
+func (e *Env) Diversity() int64 {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+
+    return e.diversityLocked()
+}
+
+func (e *Env) diversityLocked() int64 {
+    hashes := make(map[uint64]struct{})
+    for _, idx := range e.liveCells.all() {
+        hashes[e.cells[idx].Genome.Hash()] = struct{}{}
+    }
+    return int64(len(hashes))
+}
+
+type CensusEntry struct {
+    Hash uint64
+    Genome gene.Genome
+    Count int64
+    Energy int64
+    MaxGeneration int64
+    Phenotype string
+}
+
+func (e *Env) Census() []CensusEntry {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+
+    entries := make(map[uint64]*CensusEntry)
+    for _, idx := range e.liveCells.all() {
+        c := e.cells[idx]
+        h := c.Genome.Hash()
+        ent, ok := entries[h]
+        if !ok {
+            ent = &CensusEntry{
+                Hash: h,
+                Genome: append(gene.Genome(nil), c.Genome...),
+            }
+            entries[h] = ent
+        }
+        ent.Count++
+        ent.Energy += c.Energy
+        if c.Generation > ent.MaxGeneration {
+            ent.MaxGeneration = c.Generation
+        }
+    }
+
+    cs := make([]CensusEntry, 0, len(entries))
+    for _, ent := range entries {
+        cs = append(cs, *ent)
+    }
+    sort.Slice(cs, func(i, j int) bool {
+        if cs[i].Count != cs[j].Count {
+            return cs[i].Count > cs[j].Count
+        }
+        return cs[i].Hash < cs[j].Hash
+    })
+    return cs
+}
+
+func (e *Env) LabelCensus(cs []CensusEntry, n int) {
+    if n <= 0 || n > len(cs) {
+        n = len(cs)
+    }
+    for i := range cs[:n] {
+        cs[i].Phenotype = e.Phenotype(cs[i].Genome).Class
+    }
+}
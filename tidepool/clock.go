@@ -0,0 +1,139 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "fmt"
+    "math"
+    "sync/atomic"
+    "time"
+)
+
+type Pacing int32
+
+const (
+    PacingWallClock Pacing = iota
+    PacingTurbo
+)
+
+type Clock interface {
+    Now() time.Time
+    Ticker(d time.Duration) (<-chan time.Time, func())
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+    return time.Now()
+}
+
+func (systemClock) Ticker(d time.Duration) (<-chan time.Time, func()) {
+    t := time.NewTicker(d)
+    return t.C, t.Stop
+}
+
+type clockValue struct {
+    Clock
+}
+
+func (e *Env) SetClock(c Clock) {
+    e.clock.Store(clockValue{c})
+}
+
+func (e *Env) GetClock() Clock {
+    if c, ok := e.clock.Load().(clockValue); ok {
+        return c.Clock
+    }
+    return systemClock{}
+}
+
+var turboTicks = func() chan time.Time {
+    ch := make(chan time.Time)
+    close(ch)
+    return ch
+}()
+
+func (p Pacing) String() string {
+    switch p {
+    case PacingWallClock:
+        return "wall"
+    case PacingTurbo:
+        return "turbo"
+    }
+    return "unknown"
+}
+
+func ParsePacing(s string) (Pacing, bool) {
+    switch s {
+    case "wall":
+        return PacingWallClock, true
+    case "turbo":
+        return PacingTurbo, true
+    }
+    return PacingWallClock, false
+}
+
+func (e *Env) SetPacing(p Pacing) {
+    atomic.StoreInt32(&e.pacing, int32(p))
+    e.pacingChanged()
+}
+
+func (e *Env) GetPacing() Pacing {
+    return Pacing(atomic.LoadInt32(&e.pacing))
+}
+
+func (e *Env) pacingChanged() {
+    select {
+    case e.tickChanged <- struct{}{}:
+    default:
+    }
+}
+
+func (e *Env) SetSpeed(m float64) error {
+    switch {
+    case math.IsNaN(m) || m < 0:
+        return fmt.Errorf("invalid speed %v", m)
+    case m == 0:
+        e.Pause()
+    case math.IsInf(m, 1):
+        atomic.StoreInt32(&e.pacing, int32(PacingTurbo))
+        e.Resume()
+    default:
+        atomic.StoreUint64(&e.speed, math.Float64bits(m))
+        atomic.StoreInt32(&e.pacing, int32(PacingWallClock))
+        e.Resume()
+    }
+    return nil
+}
+
+func (e *Env) Speed() float64 {
+    if e.Paused() {
+        return 0
+    }
+    if e.GetPacing() == PacingTurbo {
+        return math.Inf(1)
+    }
+    return e.speedMultiplier()
+}
+
+func (e *Env) speedMultiplier() float64 {
+    if b := atomic.LoadUint64(&e.speed); b != 0 {
+        return math.Float64frombits(b)
+    }
+    return 1
+}
+
+func (e *Env) pacedTick() time.Duration {
+    d := time.Duration(float64(e.TickDuration()) / e.speedMultiplier())
+    if d < minTick {
+        d = minTick
+    }
+    return d
+}
+
+func (e *Env) pace() (<-chan time.Time, func()) {
+    if e.GetPacing() == PacingTurbo && !e.Paused() {
+        return turboTicks, func() {}
+    }
+    return e.GetClock().Ticker(e.pacedTick())
+}
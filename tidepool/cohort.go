@@ -0,0 +1,271 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "math"
+    "sort"
+)
+
+type Metric func(r *RunReport) (float64, bool)
+
+func MetricFirstReplication(r *RunReport) (float64, bool) {
+    return float64(r.FirstReplicationTick), r.FirstReplicationTick > 0
+}
+
+func MetricFirstViable(r *RunReport) (float64, bool) {
+    return float64(r.FirstViableTick), r.FirstViableTick > 0
+}
+
+func MetricEquilibriumDiversity(r *RunReport) (float64, bool) {
+    n := len(r.Samples)
+    if n == 0 {
+        return float64(r.Diversity), true
+    }
+
+    var sum float64
+    tail := r.Samples[n / 2:]
+    for _, s := range tail {
+        sum += float64(s.Diversity)
+    }
+
+    return sum / float64(len(tail)), true
+}
+
+func MetricExtinct(r *RunReport) (float64, bool) {
+    if r.Extinct() {
+        return 1, true
+    }
+    return 0, true
+}
+
+type Summary struct {
+    N int
+    Mean float64
+    StdDev float64
+    Median float64
+    Min float64
+    Max float64
+}
+
+func NewSummary(xs []float64) Summary {
+    s := Summary{N: len(xs)}
+    if s.N == 0 {
+        return s
+    }
+
+    sorted := append([]float64(nil), xs...)
+    sort.Float64s(sorted)
+
+    s.Min = sorted[0]
+    s.Max = sorted[s.N - 1]
+    if s.N % 2 == 1 {
+        s.Median = sorted[s.N / 2]
+    } else {
+        s.Median = (sorted[s.N / 2 - 1] + sorted[s.N / 2]) / 2
+    }
+
+    s.Mean, s.StdDev = meanStdDev(xs)
+
+    return s
+}
+
+type Comparison struct {
+    Metric string
+    A Summary
+    B Summary
+    T float64
+    DF float64
+    P float64
+    U float64
+    PU float64
+}
+
+func (c Comparison) Significant(alpha float64) bool {
+    return c.P < alpha
+}
+
+func metricValues(rs []RunReport, m Metric) []float64 {
+    var xs []float64
+    for i := range rs {
+        if v, ok := m(&rs[i]); ok {
+            xs = append(xs, v)
+        }
+    }
+    return xs
+}
+
+func CompareCohorts(name string, a, b []RunReport, m Metric) Comparison {
+    xa, xb := metricValues(a, m), metricValues(b, m)
+
+    c := Comparison{
+        Metric: name,
+        A: NewSummary(xa),
+        B: NewSummary(xb),
+        P: 1,
+        PU: 1,
+    }
+
+    c.T, c.DF, c.P = welchTTest(xa, xb)
+    c.U, c.PU = mannWhitneyU(xa, xb)
+
+    return c
+}
+
+func meanStdDev(xs []float64) (float64, float64) {
+    n := float64(len(xs))
+    if n == 0 {
+        return 0, 0
+    }
+
+    var mean float64
+    for _, x := range xs {
+        mean += x
+    }
+    mean /= n
+
+    if n < 2 {
+        return mean, 0
+    }
+
+    var ss float64
+    for _, x := range xs {
+        ss += (x - mean) * (x - mean)
+    }
+
+    return mean, math.Sqrt(ss / (n - 1))
+}
+
+func welchTTest(a, b []float64) (float64, float64, float64) {
+    na, nb := float64(len(a)), float64(len(b))
+    if na < 2 || nb < 2 {
+        return 0, 0, 1
+    }
+
+    ma, sa := meanStdDev(a)
+    mb, sb := meanStdDev(b)
+    va, vb := sa * sa / na, sb * sb / nb
+
+    if va + vb == 0 {
+        if ma == mb {
+            return 0, na + nb - 2, 1
+        }
+        return math.Inf(1), na + nb - 2, 0
+    }
+
+    t := (ma - mb) / math.Sqrt(va + vb)
+    df := (va + vb) * (va + vb) /
+        (va * va / (na - 1) + vb * vb / (nb - 1))
+    p := incompleteBeta(df / 2, 0.5, df / (df + t * t))
+
+    return t, df, p
+}
+
+func mannWhitneyU(a, b []float64) (float64, float64) {
+    na, nb := len(a), len(b)
+    if na == 0 || nb == 0 {
+        return 0, 1
+    }
+
+    type obs struct {
+        v float64
+        a bool
+    }
+    all := make([]obs, 0, na + nb)
+    for _, v := range a {
+        all = append(all, obs{v, true})
+    }
+    for _, v := range b {
+        all = append(all, obs{v, false})
+    }
+    sort.Slice(all, func(i, j int) bool {
+        return all[i].v < all[j].v
+    })
+
+    var ra, ties float64
+    for i := 0; i < len(all); {
+        j := i
+        for j < len(all) && all[j].v == all[i].v {
+            j++
+        }
+        rank := float64(i + j + 1) / 2
+        for k := i; k < j; k++ {
+            if all[k].a {
+                ra += rank
+            }
+        }
+        t := float64(j - i)
+        ties += t * t * t - t
+        i = j
+    }
+
+    fa, fb := float64(na), float64(nb)
+    n := fa + fb
+    u := ra - fa * (fa + 1) / 2
+    mu := fa * fb / 2
+    sigma := math.Sqrt(fa * fb / 12 * ((n + 1) - ties / (n * (n - 1))))
+    if sigma == 0 {
+        return u, 1
+    }
+
+    z := (math.Abs(u - mu) - 0.5) / sigma
+    if z < 0 {
+        z = 0
+    }
+
+    return u, math.Erfc(z / math.Sqrt2)
+}
+
+func incompleteBeta(a, b, x float64) float64 {
+    if x <= 0 {
+        return 0
+    }
+    if x >= 1 {
+        return 1
+    }
+
+    lbeta, _ := math.Lgamma(a + b)
+    la, _ := math.Lgamma(a)
+    lb, _ := math.Lgamma(b)
+    front := math.Exp(lbeta - la - lb + a * math.Log(x) + b * math.Log(1 - x))
+
+    if x > (a + 1) / (a + b + 2) {
+        return 1 - front * betaFraction(b, a, 1 - x) / b
+    }
+    return front * betaFraction(a, b, x) / a
+}
+
+func betaFraction(a, b, x float64) float64 {
+    const eps = 1e-12
+    const tiny = 1e-300
+
+    c, d := 1.0, 1 - (a + b) * x / (a + 1)
+    if math.Abs(d) < tiny {
+        d = tiny
+    }
+    d = 1 / d
+    h := d
+
+    for m := 1; m <= 200; m++ {
+        fm := float64(m)
+        num := fm * (b - fm) * x / ((a + 2 * fm - 1) * (a + 2 * fm))
+        for k := 0; k < 2; k++ {
+            d = 1 + num * d
+            if math.Abs(d) < tiny {
+                d = tiny
+            }
+            c = 1 + num / c
+            if math.Abs(c) < tiny {
+                c = tiny
+            }
+            d = 1 / d
+            h *= d * c
+            num = -(a + fm) * (a + b + fm) * x / ((a + 2 * fm) * (a + 2 * fm + 1))
+        }
+        if math.Abs(d * c - 1) < eps {
+            break
+        }
+    }
+
+    return h
+}
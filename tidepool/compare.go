@@ -0,0 +1,204 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "sort"
+)
+
+type RunRecord struct {
+    Label string
+    Ticks int64
+    Stats Stats
+    FirstReplicationTick int64
+    FirstViableTick int64
+    ExtinctionTick int64
+    Samples []BatchSample
+    Dominant []GenomeRank
+}
+
+type MetricDiff struct {
+    Name string
+    A float64
+    B float64
+    Delta float64
+    Ratio float64 `json:",omitempty"`
+}
+
+type ComparisonSeries struct {
+    Name string
+    Ticks []int64
+    A []float64
+    B []float64
+}
+
+type ComparisonReport struct {
+    A string
+    B string
+    Metrics []MetricDiff
+    Events []MetricDiff
+    SharedDominant []uint64 `json:",omitempty"`
+    OnlyA []GenomeRank `json:",omitempty"`
+    OnlyB []GenomeRank `json:",omitempty"`
+    Series []ComparisonSeries `json:",omitempty"`
+}
+
+func (r *RunReport) Record(label string) RunRecord {
+    return RunRecord{
+        Label: label,
+        Ticks: r.Ticks,
+        Stats: r.Stats,
+        FirstReplicationTick: r.FirstReplicationTick,
+        FirstViableTick: r.FirstViableTick,
+        ExtinctionTick: r.ExtinctionTick,
+        Samples: r.Samples,
+        Dominant: r.Dominant,
+    }
+}
+
+func newMetricDiff(name string, a, b float64) MetricDiff {
+    d := MetricDiff{
+        Name: name,
+        A: a,
+        B: b,
+        Delta: b - a,
+    }
+    if a != 0 {
+        d.Ratio = b / a
+    }
+    return d
+}
+
+type sampleSummary struct {
+    meanLive float64
+    peakLive float64
+    meanDiversity float64
+    peakDiversity float64
+}
+
+func summarizeSamples(ss []BatchSample) sampleSummary {
+    var s sampleSummary
+    if len(ss) == 0 {
+        return s
+    }
+    for _, b := range ss {
+        s.meanLive += float64(b.LiveCells)
+        s.meanDiversity += float64(b.Diversity)
+        if v := float64(b.LiveCells); v > s.peakLive {
+            s.peakLive = v
+        }
+        if v := float64(b.Diversity); v > s.peakDiversity {
+            s.peakDiversity = v
+        }
+    }
+    s.meanLive /= float64(len(ss))
+    s.meanDiversity /= float64(len(ss))
+    return s
+}
+
+func compareSeries(a, b []BatchSample) []ComparisonSeries {
+    if len(a) == 0 && len(b) == 0 {
+        return nil
+    }
+
+    seen := make(map[int64]bool)
+    var ticks []int64
+    for _, ss := range [][]BatchSample{a, b} {
+        for _, s := range ss {
+            if !seen[s.Tick] {
+                seen[s.Tick] = true
+                ticks = append(ticks, s.Tick)
+            }
+        }
+    }
+    sort.Slice(ticks, func(i, j int) bool {
+        return ticks[i] < ticks[j]
+    })
+
+    at := func(ss []BatchSample, f func(BatchSample) int64) []float64 {
+        vs := make([]float64, len(ticks))
+        j := -1
+        for i, t := range ticks {
+            for j + 1 < len(ss) && ss[j + 1].Tick <= t {
+                j++
+            }
+            if j >= 0 {
+                vs[i] = float64(f(ss[j]))
+            }
+        }
+        return vs
+    }
+
+    metrics := []struct {
+        name string
+        f func(BatchSample) int64
+    }{
+        {"LiveCells", func(s BatchSample) int64 { return s.LiveCells }},
+        {"ViableLiveCells", func(s BatchSample) int64 { return s.ViableLiveCells }},
+        {"Diversity", func(s BatchSample) int64 { return s.Diversity }},
+    }
+
+    series := make([]ComparisonSeries, len(metrics))
+    for i, m := range metrics {
+        series[i] = ComparisonSeries{
+            Name: m.name,
+            Ticks: ticks,
+            A: at(a, m.f),
+            B: at(b, m.f),
+        }
+    }
+    return series
+}
+
+func CompareRuns(a, b RunRecord) ComparisonReport {
+    r := ComparisonReport{
+        A: a.Label,
+        B: b.Label,
+    }
+
+    for _, n := range []string{"LiveCells", "ViableLiveCells", "Reproductions",
+        "Mutations", "NaturalDeaths", "LiveCellsKilled", "MaxGeneration"} {
+        r.Metrics = append(r.Metrics, newMetricDiff(n, float64(a.Stats[n]), float64(b.Stats[n])))
+    }
+
+    sa, sb := summarizeSamples(a.Samples), summarizeSamples(b.Samples)
+    r.Metrics = append(r.Metrics,
+        newMetricDiff("MeanLiveCells", sa.meanLive, sb.meanLive),
+        newMetricDiff("PeakLiveCells", sa.peakLive, sb.peakLive),
+        newMetricDiff("MeanDiversity", sa.meanDiversity, sb.meanDiversity),
+        newMetricDiff("PeakDiversity", sa.peakDiversity, sb.peakDiversity),
+    )
+
+    r.Events = []MetricDiff{
+        newMetricDiff("Ticks", float64(a.Ticks), float64(b.Ticks)),
+        newMetricDiff("FirstReplicationTick", float64(a.FirstReplicationTick),
+            float64(b.FirstReplicationTick)),
+        newMetricDiff("FirstViableTick", float64(a.FirstViableTick),
+            float64(b.FirstViableTick)),
+        newMetricDiff("ExtinctionTick", float64(a.ExtinctionTick),
+            float64(b.ExtinctionTick)),
+    }
+
+    inB := make(map[uint64]bool, len(b.Dominant))
+    for _, g := range b.Dominant {
+        inB[g.Hash] = true
+    }
+    inA := make(map[uint64]bool, len(a.Dominant))
+    for _, g := range a.Dominant {
+        inA[g.Hash] = true
+        if inB[g.Hash] {
+            r.SharedDominant = append(r.SharedDominant, g.Hash)
+        } else {
+            r.OnlyA = append(r.OnlyA, g)
+        }
+    }
+    for _, g := range b.Dominant {
+        if !inA[g.Hash] {
+            r.OnlyB = append(r.OnlyB, g)
+        }
+    }
+
+    r.Series = compareSeries(a.Samples, b.Samples)
+
+    return r
+}
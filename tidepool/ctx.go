@@ -3,6 +3,7 @@
 package tidepool
 
 import (
+    "math/bits"
     "math/rand"
 
     "tidepool/tidepool/gene"
@@ -13,6 +14,11 @@ type Context struct {
     rand *rand.Rand
     vm *VM
     cellsBuf []int32
+    claimed int32
+    tick int64
+    genes int
+    deferIDs bool
+    provisional int64
 }
 
 func newContext(e *Env) *Context {
@@ -20,16 +26,76 @@ func newContext(e *Env) *Context {
         env: e,
         rand: rand.New(rand.NewSource(e.Seed)),
         cellsBuf: make([]int32, e.Width * e.Height),
+        claimed: -1,
+        genes: e.GetConfig().Alphabet(),
     }
     ctx.vm = newVM(ctx)
 
     return ctx
 }
 
+func (ctx *Context) refresh(config Config) {
+    ctx.genes = config.Alphabet()
+}
+
+func (ctx *Context) newCellID() int64 {
+    if ctx.deferIDs {
+        ctx.provisional--
+        return ctx.provisional
+    }
+    return ctx.env.getNextCellID()
+}
+
 func (ctx *Context) getRandomGene() gene.Gene {
-    return gene.Gene(ctx.rand.Intn(int(gene.N)))
+    return gene.Gene(ctx.rand.Intn(ctx.genes))
+}
+
+func (ctx *Context) fillRandomGenes(g gene.Genome) {
+    n := uint(bits.Len(uint(ctx.genes - 1)))
+    mask := uint64(1) << n - 1
+    if n == 0 {
+        for i := range g {
+            g[i] = gene.ZERO
+        }
+        return
+    }
+
+    var r uint64
+    var left uint
+    for i := 0; i < len(g); {
+        if left < n {
+            r = ctx.rand.Uint64()
+            left = 64
+        }
+        v := r & mask
+        r >>= n
+        left -= n
+        if int(v) < ctx.genes {
+            g[i] = gene.Gene(v)
+            i++
+        }
+    }
 }
 
 func (ctx *Context) getRandomBool() bool {
     return ctx.rand.Intn(2) == 1
 }
+
+func (ctx *Context) Env() *Env {
+    return ctx.env
+}
+
+func (ctx *Context) Rand() *rand.Rand {
+    return ctx.rand
+}
+
+func (ctx *Context) Tick() int64 {
+    return ctx.tick
+}
+
+func (ctx *Context) Neighbor(c *Cell, dir int) *Cell {
+    idx := ctx.env.getNeighborIdx(c, dir)
+    n := ctx.vm.getCell(idx)
+    ctx.vm.addCell(n)
+    return n
+}
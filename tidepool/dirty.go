@@ -0,0 +1,93 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+const defaultDirtyChunkSize = 32
+
+type dirtyChunks struct {
+    size int32
+    cols int32
+    rows int32
+    stamps []int64
+}
+
+func newDirtyChunks(width, height, size int32, stamp int64) *dirtyChunks {
+    cols := (width + size - 1) / size
+    rows := (height + size - 1) / size
+    d := &dirtyChunks{
+        size: size,
+        cols: cols,
+        rows: rows,
+        stamps: make([]int64, cols * rows),
+    }
+    for i := range d.stamps {
+        d.stamps[i] = stamp
+    }
+    return d
+}
+
+func (d *dirtyChunks) mark(x, y int32, stamp int64) {
+    d.stamps[y / d.size * d.cols + x / d.size] = stamp
+}
+
+func dirtyChunkSize(config Config) int32 {
+    if config.DirtyChunkSize > 0 {
+        return config.DirtyChunkSize
+    }
+    return defaultDirtyChunkSize
+}
+
+func (e *Env) dirtyStamp(config Config) int64 {
+    e.dirtySeq++
+    size := dirtyChunkSize(config)
+    if e.dirty == nil || e.dirty.size != size {
+        e.dirty = newDirtyChunks(e.Width, e.Height, size, e.dirtySeq)
+    }
+    return e.dirtySeq
+}
+
+func (e *Env) updateDirty(config Config, dt *Delta) {
+    if len(dt.Cells) == 0 {
+        return
+    }
+    stamp := e.dirtyStamp(config)
+    for _, c := range dt.Cells {
+        e.dirty.mark(c.X, c.Y, stamp)
+    }
+}
+
+func (e *Env) markDirty(x, y int32) {
+    e.dirty.mark(x, y, e.dirtyStamp(e.GetConfig()))
+}
+
+func (e *Env) DirtyChunks(cursor int64) ([]Rect, int64) {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+
+    d := e.dirty
+    if d == nil {
+        return []Rect{{W: e.Width, H: e.Height}}, e.dirtySeq
+    }
+
+    var rs []Rect
+    for i, s := range d.stamps {
+        if s <= cursor {
+            continue
+        }
+        r := Rect{
+            X: int32(i) % d.cols * d.size,
+            Y: int32(i) / d.cols * d.size,
+            W: d.size,
+            H: d.size,
+        }
+        if r.X + r.W > e.Width {
+            r.W = e.Width - r.X
+        }
+        if r.Y + r.H > e.Height {
+            r.H = e.Height - r.Y
+        }
+        rs = append(rs, r)
+    }
+
+    return rs, e.dirtySeq
+}
@@ -0,0 +1,103 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "sort"
+    "strings"
+
+    "tidepool/tidepool/gene"
+)
+
+const discoveryCensusSize = 32
+const maxDiscoveries = 1024
+
+type Discovery struct {
+    Tick int64
+    Signature string
+    Genome gene.Genome
+    Count int64
+    Phenotype Phenotype
+}
+
+func (p Phenotype) Signature() string {
+    if p.Class == PhenotypeInert {
+        return p.Class
+    }
+    names := make([]string, 0, len(p.Instructions))
+    for n := range p.Instructions {
+        names = append(names, n)
+    }
+    sort.Strings(names)
+    return p.Class + ":" + strings.Join(names, ",")
+}
+
+func (e *Env) runDiscovery(config Config, ticks int64) {
+    if config.DiscoveryInterval <= 0 || ticks % config.DiscoveryInterval != 0 {
+        return
+    }
+
+    var dts []*Delta
+    for i, ent := range e.Census() {
+        if i >= discoveryCensusSize || ent.Count < config.DiscoveryMinCount {
+            break
+        }
+
+        p := e.Phenotype(ent.Genome)
+        sig := p.Signature()
+
+        e.phenotypeMutex.Lock()
+        _, seen := e.discovered[sig]
+        if !seen {
+            e.discovered[sig] = ticks
+            if len(e.discoveries) < maxDiscoveries {
+                e.discoveries = append(e.discoveries, Discovery{
+                    Tick: ticks,
+                    Signature: sig,
+                    Genome: ent.Genome,
+                    Count: ent.Count,
+                    Phenotype: p,
+                })
+            }
+        }
+        e.phenotypeMutex.Unlock()
+        if seen {
+            continue
+        }
+
+        dt := e.acquireDelta()
+        dt.force = true
+        dt.setTick(ticks)
+        ev := dt.addEvent(EventNovelPhenotype, nil)
+        ev.Genome = ent.Genome
+        ev.Message = sig
+        ev.Values = Stats{
+            "Count": ent.Count,
+            "Execs": p.Execs,
+            "Replications": p.Replications,
+            "Kills": p.Kills,
+            "Shares": p.Shares,
+            "Moves": p.Moves,
+        }
+        dt.Stats.inc("NovelPhenotypes", 1)
+        dts = append(dts, dt)
+    }
+    if len(dts) == 0 {
+        return
+    }
+
+    e.externalMutex.Lock()
+    e.external = append(e.external, dts...)
+    e.externalMutex.Unlock()
+
+    select {
+    case e.externalReady <- struct{}{}:
+    default:
+    }
+}
+
+func (e *Env) Discoveries() []Discovery {
+    e.phenotypeMutex.Lock()
+    defer e.phenotypeMutex.Unlock()
+    return append([]Discovery(nil), e.discoveries...)
+}
@@ -3,29 +3,118 @@
 package tidepool
 
 import (
+    "bytes"
     "context"
+    "encoding/gob"
+    "errors"
+    "fmt"
+    "math"
+    "sort"
     "sync"
     "sync/atomic"
     "time"
+
+    "tidepool/tidepool/gene"
 )
 
+var ErrRunning = errors.New("env is running")
+
 type Env struct {
     Width int32
     Height int32
     GenomeSize int32
     Seed int64
 
+    runID string
     initPop int32
 
     config atomic.Value
     rng atomic.Value
+    behaviors atomic.Value
+    instructions atomic.Value
+    clock atomic.Value
+    behaviorMutex *sync.Mutex
 
     mutex *sync.RWMutex
     cells []*Cell
-    liveCells map[int32]struct{}
+    interned *genomeTable
+    liveCells *liveSet
+    barriers *liveSet
     execCells map[int32]struct{}
+    weights *weightTree
+    sweep []int32
+    sweeps int64
+    dirtySeq int64
+    chunks *chunkActivity
+    territory *territoryMap
+    dirty *dirtyChunks
+    ranking *genomeRanking
+    strains *strainWatch
+    interactions *interactionNetwork
+    stagnation *stagnationDetector
+    nutrients *nutrientField
+    nutrientMutex *sync.Mutex
+    mutations map[int64][]Mutation
+    ancestry map[int64]ancestor
+    profiles map[uint64]GeneProfile
+    phenotypes map[uint64]Phenotype
+    phenotypeMutex *sync.Mutex
+    discovered map[string]int64
+    discoveries []Discovery
+    payloads payloadTable
+    lineages map[int64]*lineage
+    audit *EnergyAudit
+    recent []*Event
+    tracked map[int64]struct{}
+    trackCh chan<- *Event
+    recentIdx int
+    cellPool sync.Pool
+    deltaPool sync.Pool
+
+    cellID int64
+    seq int64
+    rejected int64
+    dormant int64
+    ticks int64
+    execs int64
+    logged int64
+    evicted int64
+    truncated int64
+    inflowTick int64
+    budget int64
+    spikeUntil int64
+    fairnessN int
 
-    nextCellID chan int64
+    ctx *Context
+    lockstep *lockstep
+    externalMutex *sync.Mutex
+    external []*Delta
+    bursts []burst
+    externalReady chan struct{}
+    running int32
+    workers int32
+    workersChanged chan struct{}
+    tick int64
+    tickChanged chan struct{}
+    paramMutex *sync.Mutex
+    traceMutex *sync.Mutex
+    traces map[int64][]chan *Trace
+    tracing int32
+    breakMutex *sync.Mutex
+    breakpoints map[int64]map[int32]bool
+    lastBreak *BreakpointHit
+    breaking int32
+    pending []func()
+    scenario *scenarioRun
+    requests *requestQueue
+    dts atomic.Value
+    out atomic.Value
+    marks queueMarks
+
+    paused int32
+    pacing int32
+    speed uint64
+    steps int64
 
     Stop context.CancelFunc
 }
@@ -35,13 +124,84 @@ type Config struct {
     ViableCellGeneration int64
     FailedKillPenalty int64
     SeedViableCells bool
+    RecordMutations bool
+    MutationLogSize int
+    ProfileGenes bool
+    TrackLineages bool
+    TerritoryChunkSize int32
+    TopGenomes int
+    StrainAlertThreshold int64
+    StagnationWindow int64
+    StagnationTolerance float64
+    Perturbation Perturbation
+    DisturbanceFraction float64
+    SpikeMutationRate float64
+    SpikeDuration int64
+    ISA ISA
+    AlphabetSize int
+    ViabilityFunc func(*Cell) bool `json:"-"`
+    Scheduler Scheduler
+    HotChunkSize int32
+    DirtyChunkSize int32
+    HotMinRate float64
+    BurstRate int64
+    ExecDensity float64
+    ExecsPerTick int
+    ExecsPerTickFunc func(live int) int `json:"-"`
+    TrackFairness bool
+    AuditEnergy bool
+    HistoryBudget int64
+    InternGenomes bool
+    WeightFunc func(*Cell) int64 `json:"-"`
+    Placement Placement
+    ShareFraction float64
+    ShareKinThreshold float64
+    ShareCanKill bool
+    KillLoot float64
+    KillCopyGenes int
+    EnergyBudget int64
+    EnergyReplenish int64
+    Nutrients int
+    NutrientCapacity int64
+    NutrientReplenish int64
+    NutrientUptake int64
+    DormancyThreshold int64
+    DormancyWake int64
+    DormancyCost int64
+    Light int64
+    DayLength int64
+    TemperatureMin float64
+    TemperatureMax float64
+    HeatBudget int64
+    HeatMutationRate float64
+    HeatDeathRate float64
+    DiscoveryInterval int64
+    DiscoveryMinCount int64
+    InteractionWindow int64
+}
+
+type configData Config
+
+type envData struct {
+    Width int32
+    Height int32
+    GenomeSize int32
+    RunID string
+    Seed int64
+    InitPop int32
+    Config Config
+    Cells []*Cell
+    Seq int64
+    Ticks int64
+    Budget int64
+    Barriers []int32
 }
 
 const (
-    dirLeft int = iota
-    dirRight
-    dirUp
-    dirDown
+    DirLeft int = iota
+    DirRight
+    DirUp
+    DirDown
 )
 
 const (
@@ -51,44 +211,301 @@ const (
     cellAny = cellDead | cellLive
 )
 
+const maxBatch = 256
+
 var defaultConfig = Config{
     InflowFrequency: 10,
     ViableCellGeneration: 2,
     FailedKillPenalty: 3,
     SeedViableCells: false,
+    RecordMutations: false,
+    MutationLogSize: 1024,
+    ProfileGenes: false,
+    ISA: ISAv1,
+    AlphabetSize: 0,
+    Scheduler: SchedulerUniform,
+    HotChunkSize: 16,
+    HotMinRate: 0.1,
+    BurstRate: 1,
+    NutrientCapacity: 1000,
+    NutrientReplenish: 1,
+    NutrientUptake: 100,
+    DormancyCost: 1,
+    StagnationTolerance: 0.05,
+    DisturbanceFraction: 0.5,
+    SpikeMutationRate: 0.001,
+    SpikeDuration: 1000,
 }
 
 func NewEnv(width, height, genomeSize, pop int32, seed int64) *Env {
+    if genomeSize < gene.MinGenomeSize {
+        genomeSize = gene.MinGenomeSize
+    }
+
     e := &Env{
         Width: width,
         Height: height,
         GenomeSize: genomeSize,
         Seed: seed,
+        runID: newRunID(),
         initPop: pop,
         mutex: &sync.RWMutex{},
-        cells: make([]*Cell, width * height),
-        liveCells: make(map[int32]struct{}),
+        behaviorMutex: &sync.Mutex{},
+        nutrientMutex: &sync.Mutex{},
+        cells: newGrid(width, height, genomeSize),
+        liveCells: newLiveSet(int(width * height)),
+        barriers: newLiveSet(int(width * height)),
         execCells: make(map[int32]struct{}),
-        nextCellID: make(chan int64),
+        mutations: make(map[int64][]Mutation),
+        ancestry: make(map[int64]ancestor),
+        profiles: make(map[uint64]GeneProfile),
+        phenotypes: make(map[uint64]Phenotype),
+        phenotypeMutex: &sync.Mutex{},
+        discovered: make(map[string]int64),
+        externalMutex: &sync.Mutex{},
+        externalReady: make(chan struct{}, 1),
+        workersChanged: make(chan struct{}, 1),
+        tickChanged: make(chan struct{}, 1),
+        paramMutex: &sync.Mutex{},
+        traceMutex: &sync.Mutex{},
+        breakMutex: &sync.Mutex{},
     }
 
     if seed < 1 {
         e.Seed = time.Now().UnixNano()
     }
 
-    for i := range e.cells {
-        idx := int32(i)
-        x := idx % width
-        y := idx / width
-        e.cells[i] = newCell(idx, x, y, genomeSize)
-    }
-
     e.SetConfig(defaultConfig)
     e.SetRNG(defaultRNG)
+    e.inflowTick = defaultConfig.InflowFrequency
 
     return e
 }
 
+func (e *Env) MarshalBinary() ([]byte, error) {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+    return e.marshalBinary()
+}
+
+func (e *Env) marshalBinary() ([]byte, error) {
+    data := envData{
+        Width: e.Width,
+        Height: e.Height,
+        GenomeSize: e.GenomeSize,
+        RunID: e.runID,
+        Seed: e.Seed,
+        InitPop: e.initPop,
+        Config: e.GetConfig(),
+        Cells: e.cells,
+        Seq: e.seq,
+        Ticks: e.Ticks(),
+        Budget: e.EnergyBudget(),
+        Barriers: e.barriers.all(),
+    }
+
+    var buf bytes.Buffer
+    if err := gob.NewEncoder(&buf).Encode(data); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+func (e *Env) UnmarshalBinary(b []byte) error {
+    if e.Running() {
+        return ErrRunning
+    }
+
+    var data envData
+    if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&data); err != nil {
+        return err
+    }
+
+    e.Width = data.Width
+    e.Height = data.Height
+    e.GenomeSize = data.GenomeSize
+    e.Seed = data.Seed
+    e.runID = data.RunID
+    if e.runID == "" {
+        e.runID = newRunID()
+    }
+    e.initPop = data.InitPop
+    e.seq = data.Seq
+    e.ticks = data.Ticks
+    e.budget = data.Budget
+    e.mutex = &sync.RWMutex{}
+    e.behaviorMutex = &sync.Mutex{}
+    e.cells = compactGrid(data.Cells, data.GenomeSize)
+    e.interned = nil
+    if e.payloads != nil {
+        e.payloads.reset(len(e.cells))
+    }
+    e.liveCells = newLiveSet(len(e.cells))
+    e.barriers = newLiveSet(len(e.cells))
+    e.execCells = make(map[int32]struct{})
+    e.weights = nil
+    e.sweep = nil
+    e.sweeps = 0
+    e.dirtySeq = 0
+    e.chunks = nil
+    e.territory = nil
+    e.dirty = nil
+    e.ranking = nil
+    e.strains = nil
+    e.interactions = nil
+    e.stagnation = nil
+    e.nutrients = nil
+    e.nutrientMutex = &sync.Mutex{}
+    e.mutations = make(map[int64][]Mutation)
+    e.ancestry = make(map[int64]ancestor)
+    e.profiles = make(map[uint64]GeneProfile)
+    e.phenotypes = make(map[uint64]Phenotype)
+    e.phenotypeMutex = &sync.Mutex{}
+    e.discovered = make(map[string]int64)
+    e.discoveries = nil
+    e.logged = 0
+    e.evicted = 0
+    e.truncated = 0
+    e.recent = nil
+    e.recentIdx = 0
+    e.tracked = nil
+    e.lineages = nil
+    e.audit = nil
+    e.externalMutex = &sync.Mutex{}
+    e.external = nil
+    e.bursts = nil
+    e.externalReady = make(chan struct{}, 1)
+    e.workersChanged = make(chan struct{}, 1)
+    e.tickChanged = make(chan struct{}, 1)
+    e.paramMutex = &sync.Mutex{}
+    e.traceMutex = &sync.Mutex{}
+    e.traces = nil
+    e.tracing = 0
+    e.breakMutex = &sync.Mutex{}
+    e.breakpoints = nil
+    e.lastBreak = nil
+    e.breaking = 0
+    e.pending = nil
+    e.scenario = nil
+    e.ctx = nil
+    e.cellID = 0
+    e.rejected = 0
+    e.execs = 0
+    e.spikeUntil = 0
+    e.fairnessN = 0
+    e.lockstep = nil
+
+    for _, idx := range data.Barriers {
+        e.barriers.add(idx)
+    }
+    e.dormant = 0
+    for _, c := range e.cells {
+        if c.live() {
+            e.liveCells.add(c.Idx)
+        }
+        if c.dormant() {
+            e.dormant++
+        }
+        if c.ID > e.cellID {
+            e.cellID = c.ID
+        }
+    }
+
+    e.SetConfig(data.Config)
+    e.SetRNG(defaultRNG)
+    e.inflowTick = data.Config.InflowFrequency
+
+    return nil
+}
+
+func (c Config) MarshalBinary() ([]byte, error) {
+    var buf bytes.Buffer
+    if err := gob.NewEncoder(&buf).Encode(configData(c)); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+func (c *Config) UnmarshalBinary(data []byte) error {
+    return gob.NewDecoder(bytes.NewReader(data)).Decode((*configData)(c))
+}
+
+func (c Config) Alphabet() int {
+    n := c.ISA.Size()
+    if c.AlphabetSize > 0 && c.AlphabetSize < n {
+        n = c.AlphabetSize
+    }
+    return n
+}
+
+func validFraction(f float64) bool {
+    return f >= 0 && f <= 1
+}
+
+func validRate(f float64) bool {
+    return f >= 0 && !math.IsInf(f, 1)
+}
+
+func (c Config) Validate() error {
+    for _, check := range []struct {
+        name string
+        ok bool
+    }{
+        {"InflowFrequency", c.InflowFrequency >= 1},
+        {"ViableCellGeneration", c.ViableCellGeneration >= 0},
+        {"FailedKillPenalty", c.FailedKillPenalty >= 1},
+        {"MutationLogSize", c.MutationLogSize >= 0},
+        {"TerritoryChunkSize", c.TerritoryChunkSize >= 0},
+        {"TopGenomes", c.TopGenomes >= 0},
+        {"StrainAlertThreshold", c.StrainAlertThreshold >= 0},
+        {"StagnationWindow", c.StagnationWindow >= 0},
+        {"StagnationTolerance", validRate(c.StagnationTolerance)},
+        {"Perturbation", c.Perturbation >= PerturbNone && c.Perturbation <= PerturbMutationSpike},
+        {"DisturbanceFraction", validFraction(c.DisturbanceFraction)},
+        {"SpikeMutationRate", validFraction(c.SpikeMutationRate)},
+        {"SpikeDuration", c.SpikeDuration >= 0},
+        {"ISA", c.ISA >= 0 && c.ISA <= ISALatest},
+        {"AlphabetSize", c.AlphabetSize >= 0},
+        {"Scheduler", c.Scheduler >= SchedulerUniform && c.Scheduler <= SchedulerFair},
+        {"HotChunkSize", c.HotChunkSize >= 0},
+        {"DirtyChunkSize", c.DirtyChunkSize >= 0},
+        {"HotMinRate", validFraction(c.HotMinRate)},
+        {"BurstRate", c.BurstRate >= 0},
+        {"ExecDensity", validRate(c.ExecDensity)},
+        {"ExecsPerTick", c.ExecsPerTick >= 0},
+        {"HistoryBudget", c.HistoryBudget >= 0},
+        {"Placement", c.Placement >= PlacementDirected && c.Placement <= PlacementDead},
+        {"ShareFraction", validFraction(c.ShareFraction)},
+        {"ShareKinThreshold", validFraction(c.ShareKinThreshold)},
+        {"KillLoot", validFraction(c.KillLoot)},
+        {"KillCopyGenes", c.KillCopyGenes >= 0},
+        {"EnergyBudget", c.EnergyBudget >= 0},
+        {"EnergyReplenish", c.EnergyReplenish >= 0},
+        {"Nutrients", c.Nutrients >= 0},
+        {"NutrientCapacity", c.NutrientCapacity >= 0},
+        {"NutrientReplenish", c.NutrientReplenish >= 0},
+        {"NutrientUptake", c.NutrientUptake >= 0},
+        {"DormancyThreshold", c.DormancyThreshold >= 0},
+        {"DormancyWake", c.DormancyWake >= 0},
+        {"DormancyCost", c.DormancyCost >= 0},
+        {"Light", c.Light >= 0},
+        {"DayLength", c.DayLength >= 0},
+        {"TemperatureMin", !math.IsNaN(c.TemperatureMin) && !math.IsInf(c.TemperatureMin, 0)},
+        {"TemperatureMax", !math.IsNaN(c.TemperatureMax) && !math.IsInf(c.TemperatureMax, 0)},
+        {"HeatBudget", c.HeatBudget >= 0},
+        {"HeatMutationRate", validRate(c.HeatMutationRate)},
+        {"HeatDeathRate", validRate(c.HeatDeathRate)},
+        {"DiscoveryInterval", c.DiscoveryInterval >= 0},
+        {"DiscoveryMinCount", c.DiscoveryMinCount >= 0},
+        {"InteractionWindow", c.InteractionWindow >= 0},
+    } {
+        if !check.ok {
+            return fmt.Errorf("invalid config %s", check.name)
+        }
+    }
+    return nil
+}
+
 func (e *Env) GetConfig() Config {
     return e.config.Load().(Config)
 }
@@ -97,43 +514,181 @@ func (e *Env) SetConfig(c Config) {
     e.config.Store(c)
 }
 
+type rngValue struct {
+    RNG
+}
+
 func (e *Env) GetRNG() RNG {
-    return e.rng.Load().(RNG)
+    return e.rng.Load().(rngValue).RNG
 }
 
 func (e *Env) SetRNG(r RNG) {
-    e.rng.Store(r)
+    e.rng.Store(rngValue{r})
 }
 
 func (e *Env) getNextCellID() int64 {
-    return <-e.nextCellID
+    return atomic.AddInt64(&e.cellID, 1)
+}
+
+func (e *Env) LastCellID() int64 {
+    return atomic.LoadInt64(&e.cellID)
 }
 
-func (e *Env) applyDelta(dt *Delta) {
+func (e *Env) staleDelta(dt *Delta) bool {
+    if dt.force {
+        return false
+    }
+    for _, c := range dt.Cells {
+        if e.cells[c.Idx].Version != c.Version {
+            return true
+        }
+    }
+    return false
+}
+
+func (e *Env) applyDelta(dt *Delta) bool {
     e.mutex.Lock()
+    defer e.mutex.Unlock()
+
+    config := e.GetConfig()
+    if !e.applyLocked(config, dt) {
+        return false
+    }
+    dt.Stats["ViableLiveCells"] = e.viableCount(config)
+    dt.Stats["LiveCells"] = int64(e.liveCells.len())
+    dt.Stats["DormantCells"] = e.dormant
+
+    return true
+}
+
+func (e *Env) applyDeltas(dts []*Delta) []bool {
+    ok := make([]bool, len(dts))
+
+    e.mutex.Lock()
+    defer e.mutex.Unlock()
+
+    config := e.GetConfig()
+    applied := false
+
+    for i, dt := range dts {
+        if ok[i] = e.applyLocked(config, dt); ok[i] {
+            applied = true
+        }
+    }
+
+    if !applied {
+        return ok
+    }
+
+    viable := e.viableCount(config)
+    for i, dt := range dts {
+        if ok[i] {
+            dt.Stats["ViableLiveCells"] = viable
+            dt.Stats["LiveCells"] = int64(e.liveCells.len())
+            dt.Stats["DormantCells"] = e.dormant
+        }
+    }
+
+    return ok
+}
+
+func (e *Env) viableCount(config Config) int64 {
+    var viable int64
+    for _, idx := range e.liveCells.all() {
+        if e.cells[idx].viable(config) {
+            viable++
+        }
+    }
+    return viable
+}
+
+func (e *Env) applyLocked(config Config, dt *Delta) bool {
+    if e.staleDelta(dt) {
+        for _, c := range dt.Cells {
+            delete(e.execCells, c.Idx)
+        }
+        e.rejected++
+        return false
+    }
+
+    if e.rejected > 0 {
+        dt.Stats.inc("RejectedDeltas", e.rejected)
+        e.rejected = 0
+    }
+
+    e.seq++
+    dt.Seq = e.seq
+    dt.RunID = e.runID
+
+    live := e.liveCells.len()
+
+    e.auditEnergy(config, dt)
+
+    if config.TrackLineages && e.lineages == nil {
+        e.initLineages(dt.Tick)
+    }
 
     for _, c := range dt.Cells {
+        c.Version = e.cells[c.Idx].Version + 1
+        if len(e.tracked) > 0 {
+            e.trackCell(dt, e.cells[c.Idx], c)
+        }
+        if e.lineages != nil {
+            e.updateLineage(dt.Tick, e.cells[c.Idx], c)
+        }
+        if old := e.cells[c.Idx].dormant(); old != c.dormant() {
+            if old {
+                e.dormant--
+            } else {
+                e.dormant++
+            }
+        }
         if c.live() {
-            e.liveCells[c.Idx] = struct{}{}
+            e.liveCells.add(c.Idx)
         } else {
-            delete(e.liveCells, c.Idx)
+            e.liveCells.remove(c.Idx)
         }
-        e.cells[c.Idx] = c.clone()
+        if e.payloads != nil {
+            e.commitPayload(e.cells[c.Idx], c)
+        }
+        e.commitCell(config, c)
         delete(e.execCells, c.Idx)
+        e.updateWeights(config, c)
+    }
+    if e.payloads != nil {
+        e.commitPayloads(dt)
     }
+    e.updateActivity(config, dt)
+    e.updateLineages(config, dt)
+    e.updateTerritory(config, dt)
+    e.updateDirty(config, dt)
+    e.updateRanking(config, dt)
+    e.updateStrains(config, dt)
+    e.updateInteractions(config, dt)
+    e.detectStagnation(config, dt)
+    e.updateFairness(config, dt)
 
-    var i int64
-    config := e.GetConfig()
-    for idx := range e.liveCells {
-        c := e.cells[idx]
-        if c.viable(config) {
-            i++
-        }
+    for _, m := range dt.Mutations {
+        e.logMutation(config, m)
+    }
+    if config.RecordMutations {
+        e.logBirths(dt)
     }
-    dt.Stats["ViableLiveCells"] = i
-    dt.Stats["LiveCells"] = int64(len(e.liveCells))
 
-    e.mutex.Unlock()
+    if dt.Profile != nil {
+        e.addProfile(dt.ProfileHash, dt.Profile)
+    }
+    e.enforceHistoryBudget(config, dt)
+
+    if live > 0 && e.liveCells.len() == 0 {
+        dt.addEvent(EventExtinction, nil)
+    }
+
+    for _, ev := range dt.Events {
+        e.recordEvent(ev)
+    }
+
+    return true
 }
 
 func (e *Env) GetCell(x, y int32) *Cell {
@@ -148,19 +703,158 @@ func (e *Env) GetCellByIdx(idx int32) *Cell {
     return e.cells[idx].clone()
 }
 
+func (e *Env) GetRegion(x, y, w, h int32) []*Cell {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+
+    cs := make([]*Cell, 0, w * h)
+    for j := y; j < y + h; j++ {
+        for i := x; i < x + w; i++ {
+            if i < 0 || j < 0 || i >= e.Width || j >= e.Height {
+                continue
+            }
+            cs = append(cs, e.cells[i + e.Width * j].clone())
+        }
+    }
+
+    return cs
+}
+
+func (e *Env) submit(dt *Delta) {
+    e.externalMutex.Lock()
+    if atomic.LoadInt32(&e.running) == 0 {
+        e.externalMutex.Unlock()
+        dt.setTick(e.Ticks())
+        e.applyExternal(dt)
+        dt.Release()
+        return
+    }
+    e.external = append(e.external, dt)
+    e.externalMutex.Unlock()
+
+    select {
+    case e.externalReady <- struct{}{}:
+    default:
+    }
+}
+
+func (e *Env) takeExternal() []*Delta {
+    e.externalMutex.Lock()
+    defer e.externalMutex.Unlock()
+
+    dts := e.external
+    e.external = nil
+
+    for _, dt := range dts {
+        dt.setTick(e.Ticks())
+    }
+
+    return dts
+}
+
+func (e *Env) applyExternal(dt *Delta) bool {
+    ok := e.applyDelta(dt)
+    if dt.done != nil {
+        dt.done <- ok
+    }
+    return ok
+}
+
+func (e *Env) stopExternal() {
+    e.externalMutex.Lock()
+    atomic.StoreInt32(&e.running, 0)
+    e.externalMutex.Unlock()
+
+    for _, dt := range e.takeExternal() {
+        e.applyExternal(dt)
+        dt.Release()
+    }
+}
+
+func (e *Env) InjectCell(x, y int32, g gene.Genome, energy int64) (*Cell, error) {
+    if x < 0 || y < 0 || x >= e.Width || y >= e.Height {
+        return nil, fmt.Errorf("cell %d,%d out of bounds", x, y)
+    }
+    if int32(len(g)) > e.GenomeSize {
+        return nil, fmt.Errorf("genome exceeds %d genes", e.GenomeSize)
+    }
+    for i, v := range g {
+        if v < 0 || v >= gene.NMax {
+            return nil, fmt.Errorf("invalid gene %d at %d", v, i)
+        }
+    }
+
+    c := e.acquireCellByIdx(x + e.Width * y)
+    c.resetGenome()
+    copy(c.Genome, g)
+    c.Energy = energy
+    c.ID = e.getNextCellID()
+    c.Origin = c.ID
+    c.Parent = 0
+    c.Generation = 0
+    c.Born = e.Ticks()
+    c.Execs = 0
+    c.Dormant = false
+
+    n := c.clone()
+
+    dt := e.acquireDelta()
+    dt.Cells = append(dt.Cells, c)
+    dt.force = true
+    e.submit(dt)
+
+    return n, nil
+}
+
 func (e *Env) getRandomCell(ctx *Context, state int) *Cell {
+    return e.getRandomCellIn(ctx, state, nil)
+}
+
+func (e *Env) getRandomCellIn(ctx *Context, state int, r *Rect) *Cell {
     config := e.GetConfig()
 
+    if r != nil {
+        return e.getRandomCellFill(ctx, config, state, r)
+    }
+
+    if state == cellLive && config.Scheduler == SchedulerEnergy {
+        if c := e.getWeightedCell(ctx, config); c != nil {
+            return c
+        }
+    }
+    if state == cellLive && config.Scheduler == SchedulerSweep {
+        return e.getSweepCell(ctx)
+    }
+    if state == cellLive && config.Scheduler == SchedulerFair {
+        if c := e.getFairCell(ctx); c != nil {
+            return c
+        }
+    }
+    if state == cellLive && config.Scheduler == SchedulerHot {
+        if c := e.getHotCell(ctx, config); c != nil {
+            return c
+        }
+    }
+    if state == cellLive {
+        if c := e.getUniformCell(ctx); c != nil {
+            return c
+        }
+    }
+
+    return e.getRandomCellFill(ctx, config, state, nil)
+}
+
+func (e *Env) getRandomCellFill(ctx *Context, config Config, state int, r *Rect) *Cell {
     fillBuf := func(idx int32, s int, i *int) {
         if _, exec := e.execCells[idx]; exec {
             return
         }
         if s & cellLive == 0 {
-            if _, live := e.liveCells[idx]; live {
+            if e.liveCells.has(idx) {
                 return
             }
         }
-        if s & cellNonviable == 1 && e.cells[idx].viable(config) {
+        if s & cellNonviable != 0 && e.cells[idx].viable(config) {
             return
         }
         ctx.cellsBuf[*i] = idx
@@ -170,8 +864,18 @@ func (e *Env) getRandomCell(ctx *Context, state int) *Cell {
     i := 0
     e.mutex.RLock()
 
-    if state & cellLive == state {
-        for idx := range e.liveCells {
+    if r != nil {
+        for y := r.Y; y < r.Y + r.H; y++ {
+            for x := r.X; x < r.X + r.W; x++ {
+                idx := x + e.Width * y
+                if state & cellDead == 0 && !e.liveCells.has(idx) {
+                    continue
+                }
+                fillBuf(idx, state, &i)
+            }
+        }
+    } else if state & cellLive == state {
+        for _, idx := range e.liveCells.all() {
             fillBuf(idx, cellLive, &i)
         }
     } else {
@@ -185,7 +889,7 @@ func (e *Env) getRandomCell(ctx *Context, state int) *Cell {
         return nil
     }
 
-    c := e.cells[ctx.cellsBuf[ctx.rand.Intn(i)]].clone()
+    c := e.acquireCell(e.cells[ctx.cellsBuf[ctx.rand.Intn(i)]])
     e.mutex.RUnlock()
 
     e.mutex.Lock()
@@ -199,25 +903,25 @@ func (e *Env) getNeighborIdx(c *Cell, dir int) int32 {
     x, y := c.X, c.Y
 
     switch dir {
-    case dirLeft:
+    case DirLeft:
         if x == 0 {
             x = e.Width - 1
         } else {
             x--
         }
-    case dirRight:
+    case DirRight:
         if x == e.Width - 1 {
             x = 0
         } else {
             x++
         }
-    case dirUp:
+    case DirUp:
         if y == 0 {
             y = e.Height - 1
         } else {
             y--
         }
-    case dirDown:
+    case DirDown:
         if y == e.Height - 1 {
             y = 0
         } else {
@@ -228,8 +932,207 @@ func (e *Env) getNeighborIdx(c *Cell, dir int) int32 {
     return x + e.Width * y
 }
 
+func (e *Env) KillCell(x, y int32) error {
+    if x < 0 || y < 0 || x >= e.Width || y >= e.Height {
+        return fmt.Errorf("cell %d,%d out of bounds", x, y)
+    }
+
+    c := e.acquireCellByIdx(x + e.Width * y)
+    c.Energy = 0
+    c.ID = 0
+    c.Origin = 0
+    c.Parent = 0
+    c.Generation = 0
+    c.resetGenome()
+
+    dt := e.acquireDelta()
+    dt.Cells = append(dt.Cells, c)
+    dt.force = true
+    e.submit(dt)
+
+    return nil
+}
+
+func (e *Env) killRegion(dt *Delta, r Rect) {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+
+    for y := r.Y; y < r.Y + r.H; y++ {
+        for x := r.X; x < r.X + r.W; x++ {
+            if !e.cells[x + e.Width * y].live() {
+                continue
+            }
+            c := e.acquireCell(e.cells[x + e.Width * y])
+            c.Energy = 0
+            c.ID = 0
+            c.Origin = 0
+            c.Parent = 0
+            c.Generation = 0
+            c.Born = 0
+            c.Execs = 0
+            c.Dormant = false
+            c.resetGenome()
+            dt.Cells = append(dt.Cells, c)
+        }
+    }
+}
+
+func (e *Env) KillRegion(r Rect) (int, error) {
+    r = r.clip(e.Width, e.Height)
+    if r.W == 0 || r.H == 0 {
+        return 0, ErrEmptyRegion
+    }
+
+    dt := e.acquireDelta()
+    dt.force = true
+    e.killRegion(dt, r)
+    n := len(dt.Cells)
+    if n == 0 {
+        dt.Release()
+        return 0, nil
+    }
+    e.submit(dt)
+
+    return n, nil
+}
+
+func (e *Env) Ticks() int64 {
+    return atomic.LoadInt64(&e.ticks)
+}
+
+func (e *Env) Execs() int64 {
+    return atomic.LoadInt64(&e.execs)
+}
+
+func (e *Env) nextTick() (int64, int) {
+    e.applyParams()
+
+    config := e.GetConfig()
+    freq := config.InflowFrequency
+    n := 0
+
+    ticks := atomic.AddInt64(&e.ticks, 1)
+    e.runScenario(ticks)
+    e.runDiscovery(config, ticks)
+    e.replenishBudget(config)
+    if e.initPop > 0 {
+        n++
+        e.initPop--
+        e.inflowTick = freq
+    }
+    e.inflowTick--
+    if e.inflowTick <= 0 {
+        n++
+        e.inflowTick = freq
+    }
+
+    return ticks, n
+}
+
+func (e *Env) inflowDelta(ctx *Context, ticks int64) *Delta {
+    return e.inflowDeltaIn(ctx, ticks, nil)
+}
+
+func (e *Env) inflowDeltaIn(ctx *Context, ticks int64, r *Rect) *Delta {
+    config := e.GetConfig()
+    ctx.tick = ticks
+    ctx.refresh(config)
+
+    state := cellAny
+    if !config.SeedViableCells {
+        state |= cellNonviable
+    }
+    if e.budgetExhausted(config) {
+        return nil
+    }
+    c := e.getRandomCellIn(ctx, state, r)
+    if c == nil {
+        return nil
+    }
+
+    ctx.claimed = c.Idx
+    dt := c.seed(ctx)
+    ctx.claimed = -1
+    dt.Tick = ticks
+    dt.Stats["Ticks"] = ticks
+    if config.EnergyBudget > 0 {
+        dt.Stats["EnergyBudget"] = e.EnergyBudget()
+    }
+
+    return dt
+}
+
+func (e *Env) execDelta(ctx *Context, ticks int64) *Delta {
+    ctx.tick = ticks
+    ctx.refresh(e.GetConfig())
+
+    c := e.getRandomCell(ctx, cellLive)
+    if c == nil {
+        return nil
+    }
+    return e.execCell(ctx, c, ticks)
+}
+
+func (e *Env) execCell(ctx *Context, c *Cell, ticks int64) *Delta {
+    ctx.claimed = c.Idx
+    dt := c.exec(ctx)
+    ctx.claimed = -1
+    atomic.AddInt64(&e.execs, 1)
+    dt.exec = true
+    dt.Tick = ticks
+    dt.Stats["Ticks"] = ticks
+
+    return dt
+}
+
+func (e *Env) Advance() []*Delta {
+    return e.AdvanceInto(nil)
+}
+
+func (e *Env) AdvanceInto(dts []*Delta) []*Delta {
+    if e.ctx == nil {
+        e.ctx = newContext(e)
+    }
+
+    apply := func(dt *Delta) {
+        if dt != nil {
+            e.applyDelta(dt)
+            dts = append(dts, dt)
+        }
+    }
+
+    for _, dt := range e.takeExternal() {
+        if !e.applyExternal(dt) {
+            dt.Release()
+            continue
+        }
+        dts = append(dts, dt)
+    }
+
+    ticks, n := e.nextTick()
+    for i := 0; i < n; i++ {
+        apply(e.inflowDelta(e.ctx, ticks))
+    }
+    e.burstDeltas(e.ctx, ticks, apply)
+
+    if dt := e.execDelta(e.ctx, ticks); dt != nil {
+        apply(dt)
+    } else {
+        apply(e.inflowDelta(e.ctx, ticks))
+    }
+    for i := e.execCount(e.GetConfig()) - 1; i > 0; i-- {
+        dt := e.execDelta(e.ctx, ticks)
+        if dt == nil {
+            break
+        }
+        apply(dt)
+    }
+
+    return dts
+}
+
 func (e *Env) process(wg *sync.WaitGroup, context context.Context,
-    exec <-chan int64, inflow chan int64, dts chan<- *Delta) {
+    exec <-chan execBatch, inflow chan int64, dts chan<- *Delta) {
     defer wg.Done()
 
     ctx := newContext(e)
@@ -239,101 +1142,295 @@ func (e *Env) process(wg *sync.WaitGroup, context context.Context,
         case <-context.Done():
             return
         case ticks := <-inflow:
-            var c *Cell
-            if !e.GetConfig().SeedViableCells {
-                if c = e.getRandomCell(ctx, cellAny | cellNonviable); c == nil {
-                    break
+            dt, crash := e.supervise(ctx, ticks, e.inflowDelta)
+            if crash != nil {
+                ctx = newContext(e)
+                dt = crash
+            }
+            if dt != nil {
+                select {
+                case <-context.Done():
+                    e.release(dt)
+                    dt.Release()
+                case dts <- dt:
                 }
-            } else {
-                c = e.getRandomCell(ctx, cellAny)
             }
-            dt := c.seed(ctx)
-            dt.Stats["Ticks"] = ticks
-            dts <- dt
-        case ticks := <-exec:
-            if c := e.getRandomCell(ctx, cellLive); c != nil {
-                dt := c.exec(ctx)
-                dt.Stats["Ticks"] = ticks
-                dts <- dt
-            } else {
-                go func() {
-                    inflow <- ticks
-                }()
+        case b := <-exec:
+            for i := 0; i < b.n; i++ {
+                dt, crash := e.supervise(ctx, b.ticks, e.execDelta)
+                if crash != nil {
+                    ctx = newContext(e)
+                    dt = crash
+                }
+                if dt == nil {
+                    if i == 0 && b.fill {
+                        e.retry(&Delta{Tick: b.ticks})
+                    }
+                    break
+                }
+                select {
+                case <-context.Done():
+                    e.release(dt)
+                    dt.Release()
+                    return
+                case dts <- dt:
+                }
             }
         }
     }
 }
 
+func (e *Env) Pause() {
+    atomic.StoreInt32(&e.paused, 1)
+    e.pacingChanged()
+}
+
+func (e *Env) Resume() {
+    atomic.StoreInt64(&e.steps, 0)
+    atomic.StoreInt32(&e.paused, 0)
+    e.pacingChanged()
+}
+
+func (e *Env) Paused() bool {
+    return atomic.LoadInt32(&e.paused) == 1
+}
+
+func (e *Env) Running() bool {
+    return atomic.LoadInt32(&e.running) == 1
+}
+
+func (e *Env) Step(n int64) {
+    atomic.AddInt64(&e.steps, n)
+}
+
+func (e *Env) unpaused() bool {
+    if !e.Paused() {
+        return true
+    }
+    for {
+        n := atomic.LoadInt64(&e.steps)
+        if n <= 0 {
+            return false
+        }
+        if atomic.CompareAndSwapInt64(&e.steps, n, n - 1) {
+            return true
+        }
+    }
+}
+
 func (e *Env) WithCells(f func([]*Cell)) {
     e.mutex.RLock()
     defer e.mutex.RUnlock()
     f(e.cells)
 }
 
+func (e *Env) withSnapshot(f func(seq, ticks int64, cs []*Cell)) {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+    f(e.seq, e.Ticks(), e.cells)
+}
+
+type RunOptions struct {
+    Workers int
+    Tick time.Duration
+    DeltaBuffer int
+    Pacing Pacing
+    StopWhen func(Stats) bool
+    OnCrash CrashPolicy
+    Context context.Context
+    Lockstep bool
+}
+
 func (e *Env) Run(processN int, tick time.Duration, deltas chan<- *Delta) {
-    exec := make(chan int64)
+    e.RunWith(RunOptions{Workers: processN, Tick: tick}, deltas)
+}
+
+func (e *Env) RunWith(opts RunOptions, deltas chan<- *Delta) {
+    processN := opts.Workers
+    atomic.StoreInt64(&e.tick, int64(opts.Tick))
+    exec := make(chan execBatch)
     inflow := make(chan int64)
-    dts := make(chan *Delta, processN)
+    buffer := opts.DeltaBuffer
+    if buffer < 1 {
+        buffer = processN
+    }
+    dts := make(chan *Delta, buffer)
+    e.dts.Store(dts)
+    e.out.Store(deltas)
+    atomic.StoreInt64(&e.marks.delta, 0)
+    atomic.StoreInt64(&e.marks.output, 0)
+    requests := newRequestQueue()
+    e.mutex.Lock()
+    e.requests = requests
+    e.mutex.Unlock()
+    defer func() {
+        e.mutex.Lock()
+        e.requests = nil
+        e.mutex.Unlock()
+    }()
 
-    context, stop := context.WithCancel(context.Background())
+    parent := opts.Context
+    if parent == nil {
+        parent = context.Background()
+    }
+    context, stop := context.WithCancel(parent)
     e.Stop = stop
 
-    var wg sync.WaitGroup
-    wg.Add(processN)
+    atomic.StoreInt32(&e.running, 1)
+    defer e.stopExternal()
 
-    for i := 0; i < processN; i++ {
-        go e.process(&wg, context, exec, inflow, dts)
+    pool := &workerPool{context: context}
+    e.SetWorkers(processN)
+    if !opts.Lockstep {
+        e.resizeWorkers(pool, exec, inflow, dts)
     }
 
-    go func() {
-        defer close(e.nextCellID)
-        var id int64 = 1
+    defer close(deltas)
+
+    if opts.Pacing != PacingWallClock {
+        e.SetPacing(opts.Pacing)
+    }
+    ticker, stopTicker := e.pace()
+    defer func() {
+        stopTicker()
+    }()
+
+    defer func() {
+        pool.wg.Wait()
         for {
             select {
-            case <-context.Done():
-                return
+            case dt := <-dts:
+                e.release(dt)
+                dt.Release()
             default:
-                e.nextCellID <- id
-                id++
+                return
             }
         }
     }()
 
-    defer close(inflow)
-    defer close(dts)
-    defer close(deltas)
+    batch := make([]*Delta, 0, maxBatch)
+    var stepped []*Delta
+    burstCtx := newContext(e)
+    stats := make(Stats)
 
-    ticker := time.NewTicker(tick)
-    defer ticker.Stop()
+    emit := func(dt *Delta) {
+        if opts.StopWhen != nil {
+            stats.Add(dt.Stats)
+        }
+        crashed := dt.Stats["WorkerCrashes"] > 0
+        markHighWater(&e.marks.output, len(deltas))
+        deltas <- dt
+        if crashed && opts.OnCrash == CrashHalt {
+            stop()
+        }
+    }
+    check := func() {
+        if opts.StopWhen != nil && opts.StopWhen(stats) {
+            stop()
+        }
+    }
 
-    var ticks int64 = 0
+    apply := func(dt *Delta) {
+        batch = append(batch[:0], dt)
+        markHighWater(&e.marks.delta, len(dts) + 1)
+    drain:
+        for len(batch) < maxBatch {
+            select {
+            case dt := <-dts:
+                batch = append(batch, dt)
+            default:
+                break drain
+            }
+        }
 
-    inflowTick := e.GetConfig().InflowFrequency
-    sendInflow := func () {
-        inflow <- ticks
-        inflowTick = e.GetConfig().InflowFrequency
+        sort.SliceStable(batch, func(i, j int) bool {
+            return batch[i].minIdx() < batch[j].minIdx()
+        })
+
+        for i, ok := range e.applyDeltas(batch) {
+            if !ok {
+                e.retry(batch[i])
+                batch[i].Release()
+                continue
+            }
+            emit(batch[i])
+        }
+        check()
     }
 
-    defer wg.Wait()
+    send := func(ch chan<- int64, ticks int64) {
+        for {
+            select {
+            case <-context.Done():
+                return
+            case ch <- ticks:
+                return
+            case dt := <-dts:
+                apply(dt)
+            }
+        }
+    }
+    sendExec := func(b execBatch) {
+        for {
+            select {
+            case <-context.Done():
+                return
+            case exec <- b:
+                return
+            case dt := <-dts:
+                apply(dt)
+            }
+        }
+    }
 
     for {
         select {
         case <-context.Done():
             return
-        case <-ticker.C:
-            ticks++
-            if e.initPop > 0 {
-                sendInflow()
-                e.initPop--
+        case <-ticker:
+            if !e.unpaused() {
+                break
+            }
+            if opts.Lockstep {
+                stepped = e.stepLockstep(e.Workers(), stepped[:0])
+                for _, dt := range stepped {
+                    emit(dt)
+                }
+                check()
+                break
+            }
+            ticks, n := e.nextTick()
+            for i := 0; i < n; i++ {
+                send(inflow, ticks)
             }
-            inflowTick--
-            if inflowTick == 0 {
-                sendInflow()
+            e.burstDeltas(burstCtx, ticks, apply)
+            e.execBatches(ticks, sendExec)
+        case <-requests.ready:
+            inflows, execs, ticks := requests.take()
+            for i := 0; i < inflows; i++ {
+                send(inflow, ticks)
+            }
+            for i := 0; i < execs; i++ {
+                sendExec(execBatch{ticks: ticks, n: 1, fill: true})
             }
-            exec <- ticks
         case dt := <-dts:
-            e.applyDelta(dt)
-            deltas <- dt
+            apply(dt)
+        case <-e.workersChanged:
+            if !opts.Lockstep {
+                e.resizeWorkers(pool, exec, inflow, dts)
+            }
+        case <-e.tickChanged:
+            stopTicker()
+            ticker, stopTicker = e.pace()
+        case <-e.externalReady:
+            for _, dt := range e.takeExternal() {
+                if !e.applyExternal(dt) {
+                    dt.Release()
+                    continue
+                }
+                emit(dt)
+            }
+            check()
         }
     }
 }
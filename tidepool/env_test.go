@@ -4,6 +4,10 @@ package tidepool
 
 import (
     "encoding/json"
+    "math"
+    "reflect"
+    "strings"
+    "sync/atomic"
     "testing"
 )
 
@@ -42,3 +46,254 @@ func BenchmarkJSONMarshalCells(b *testing.B) {
         json.Marshal(dt)
     }
 }
+
+func TestEnvMarshalBinary(t *testing.T) {
+    env := NewEnv(8, 8, 16, 0, 1)
+
+    ctx := newContext(env)
+    c := env.GetCell(2, 3)
+    c.Energy = 100
+    c.randomizeGenome(ctx)
+    env.applyDelta(&Delta{Cells: []*Cell{c}, Stats: make(Stats)})
+
+    data, err := env.MarshalBinary()
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    var e Env
+    if err := e.UnmarshalBinary(data); err != nil {
+        t.Fatal(err)
+    }
+
+    if e.Width != env.Width || e.Height != env.Height || e.Seed != env.Seed {
+        t.Fatalf("dimensions mismatch: %dx%d seed %d", e.Width, e.Height, e.Seed)
+    }
+    if !reflect.DeepEqual(e.GetConfig(), env.GetConfig()) {
+        t.Fatalf("config mismatch: %+v", e.GetConfig())
+    }
+
+    n := e.GetCell(2, 3)
+    if n.Energy != c.Energy || n.Genome.String() != c.Genome.String() {
+        t.Fatalf("cell mismatch: %+v", n)
+    }
+    if e.liveCells.len() != 1 {
+        t.Fatalf("expected 1 live cell, got %d", e.liveCells.len())
+    }
+
+    used := NewEnv(8, 8, 16, 32, 2)
+    config := used.GetConfig()
+    config.AuditEnergy = true
+    config.StrainAlertThreshold = 2
+    config.TrackLineages = true
+    config.RecordMutations = true
+    used.SetConfig(config)
+    used.Track(used.LastCellID())
+    for i := 0; i < 200; i++ {
+        for _, dt := range used.Advance() {
+            dt.Release()
+        }
+    }
+    if used.Execs() == 0 || len(used.recent) == 0 {
+        t.Fatal("expected used env to have executed")
+    }
+
+    if err := used.UnmarshalBinary(data); err != nil {
+        t.Fatal(err)
+    }
+    if used.Width != env.Width || used.Seed != env.Seed || used.liveCells.len() != 1 {
+        t.Fatalf("expected decoded env, got %dx%d seed %d", used.Width, used.Height, used.Seed)
+    }
+    if !reflect.DeepEqual(used.GetConfig(), env.GetConfig()) {
+        t.Fatalf("config mismatch: %+v", used.GetConfig())
+    }
+    if used.LastCellID() != e.LastCellID() || used.Execs() != 0 || used.Sweeps() != 0 {
+        t.Fatalf("expected counters from data, got cell %d execs %d sweeps %d",
+            used.LastCellID(), used.Execs(), used.Sweeps())
+    }
+    if used.strains != nil || used.audit != nil || used.lineages != nil || used.ranking != nil || used.territory != nil {
+        t.Fatal("expected derived state to be reset")
+    }
+    if len(used.recent) != 0 || len(used.Tracked()) != 0 || len(used.mutations) != 0 || len(used.ancestry) != 0 {
+        t.Fatal("expected history to be reset")
+    }
+}
+
+func TestEnvMarshalBinaryRunning(t *testing.T) {
+    env := NewEnv(8, 8, 16, 0, 1)
+    data, err := env.MarshalBinary()
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    atomic.StoreInt32(&env.running, 1)
+    if err := env.UnmarshalBinary(data); err != ErrRunning {
+        t.Fatalf("expected ErrRunning, got %v", err)
+    }
+}
+
+func advanceReleased(env *Env, dts []*Delta) []*Delta {
+    dts = env.AdvanceInto(dts[:0])
+    for _, dt := range dts {
+        dt.Release()
+    }
+    return dts
+}
+
+func BenchmarkAdvance(b *testing.B) {
+    b.ReportAllocs()
+
+    env := NewEnv(64, 64, 256, 64, 1)
+    dts := make([]*Delta, 0, 8)
+    for i := 0; i < 10000; i++ {
+        dts = advanceReleased(env, dts)
+    }
+
+    b.ResetTimer()
+
+    for i := 0; i < b.N; i++ {
+        dts = advanceReleased(env, dts)
+    }
+}
+
+func TestConfigValidate(t *testing.T) {
+    if err := defaultConfig.Validate(); err != nil {
+        t.Fatal(err)
+    }
+    for _, p := range Presets() {
+        c := defaultConfig
+        if p.Configure != nil {
+            p.Configure(&c)
+        }
+        if err := c.Validate(); err != nil {
+            t.Fatalf("preset %s: %v", p.Name, err)
+        }
+    }
+
+    for name, f := range map[string]func(*Config){
+        "InflowFrequency": func(c *Config) { c.InflowFrequency = 0 },
+        "FailedKillPenalty": func(c *Config) { c.FailedKillPenalty = 0 },
+        "Scheduler": func(c *Config) { c.Scheduler = -1 },
+        "ShareFraction": func(c *Config) { c.ShareFraction = 1.5 },
+        "DormancyCost": func(c *Config) { c.DormancyCost = -1 },
+        "HeatDeathRate": func(c *Config) { c.HeatDeathRate = math.NaN() },
+        "TemperatureMax": func(c *Config) { c.TemperatureMax = math.Inf(1) },
+    } {
+        c := defaultConfig
+        f(&c)
+        if err := c.Validate(); err == nil || !strings.Contains(err.Error(), name) {
+            t.Fatalf("expected invalid %s, got %v", name, err)
+        }
+    }
+}
+
+func TestReleaseKeepsEventCells(t *testing.T) {
+    env := NewEnv(16, 16, 64, 32, 1)
+    dts := make([]*Delta, 0, 8)
+
+    var births []*Event
+    var want []Cell
+    for i := 0; i < 20000 && len(births) < 8; i++ {
+        dts = env.AdvanceInto(dts[:0])
+        for _, dt := range dts {
+            for _, ev := range dt.Events {
+                if ev.Type == EventBirth {
+                    births = append(births, ev)
+                    want = append(want, *ev.Cell)
+                }
+            }
+            dt.Release()
+        }
+    }
+    if len(births) == 0 {
+        t.Fatal("expected births")
+    }
+
+    for i := 0; i < 2000; i++ {
+        dts = advanceReleased(env, dts)
+    }
+    for i, ev := range births {
+        if ev.Cell.ID != want[i].ID || ev.Cell.Idx != want[i].Idx || ev.Cell.Energy != want[i].Energy {
+            t.Fatalf("birth event cell changed after release: %d/%d -> %d/%d",
+                want[i].ID, want[i].Idx, ev.Cell.ID, ev.Cell.Idx)
+        }
+    }
+}
+
+func TestAdvanceAllocs(t *testing.T) {
+    if raceEnabled {
+        t.Skip("race detector allocates")
+    }
+
+    env := NewEnv(64, 64, 256, 64, 1)
+    dts := make([]*Delta, 0, 8)
+    for i := 0; i < 10000; i++ {
+        dts = advanceReleased(env, dts)
+    }
+
+    if n := testing.AllocsPerRun(1000, func() {
+        dts = advanceReleased(env, dts)
+    }); n >= 1 {
+        t.Fatalf("expected no allocations per tick, got %v", n)
+    }
+}
+
+func TestAdvanceDeterministic(t *testing.T) {
+    run := func() ([]int64, string) {
+        env := NewEnv(32, 32, 64, 32, 7)
+        var order []int64
+        for i := 0; i < 5000; i++ {
+            for _, dt := range env.Advance() {
+                for _, c := range dt.Cells {
+                    order = append(order, int64(c.Idx))
+                }
+            }
+        }
+        data, err := json.Marshal(env.GetRegion(0, 0, 32, 32))
+        if err != nil {
+            t.Fatal(err)
+        }
+        return order, string(data)
+    }
+
+    o1, w1 := run()
+    o2, w2 := run()
+
+    if !reflect.DeepEqual(o1, o2) {
+        t.Fatal("delta cell order differs between identical runs")
+    }
+    if w1 != w2 {
+        t.Fatal("worlds differ between identical runs")
+    }
+}
+
+func TestAdvanceLockstepWorkers(t *testing.T) {
+    run := func(workers int) string {
+        env := NewEnv(32, 32, 64, 32, 7)
+        config := env.GetConfig()
+        config.ExecsPerTick = 16
+        env.SetConfig(config)
+        for i := 0; i < 2000; i++ {
+            for _, dt := range env.AdvanceLockstep(workers, nil) {
+                dt.Release()
+            }
+        }
+        data, err := json.Marshal(env.GetRegion(0, 0, 32, 32))
+        if err != nil {
+            t.Fatal(err)
+        }
+        return string(data)
+    }
+
+    if run(1) != run(4) {
+        t.Fatal("lockstep worlds differ between 1 and 4 workers")
+    }
+}
+
+func TestGoldenVectors(t *testing.T) {
+    for _, v := range GoldenVectors() {
+        if _, err := v.Verify(10000); err != nil {
+            t.Error(err)
+        }
+    }
+}
@@ -0,0 +1,67 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "tidepool/tidepool/gene"
+)
+
+const (
+    EventBirth = "Birth"
+    EventExtinction = "Extinction"
+    EventTransaction = "Transaction"
+    EventTrackedExec = "TrackedExec"
+    EventTrackedChange = "TrackedChange"
+    EventTopGenomeEntered = "TopGenomeEntered"
+    EventTopGenomeTakeover = "TopGenomeTakeover"
+    EventStagnation = "Stagnation"
+    EventWorkerCrashed = "WorkerCrashed"
+    EventEnergyViolation = "EnergyViolation"
+    EventBreakpoint = "Breakpoint"
+    EventStrainExtinct = "StrainExtinct"
+    EventScenario = "Scenario"
+    EventNovelPhenotype = "NovelPhenotype"
+)
+
+type Event struct {
+    Type string
+    Tick int64
+    Cell *Cell `json:",omitempty"`
+    Genome gene.Genome `json:",omitempty"`
+    Values Stats `json:",omitempty"`
+    Message string `json:",omitempty"`
+}
+
+func (dt *Delta) addEvent(t string, c *Cell) *Event {
+    ev := &Event{
+        Type: t,
+        Tick: dt.Tick,
+        Cell: c,
+    }
+    dt.Events = append(dt.Events, ev)
+    return ev
+}
+
+func (ev *Event) clone() *Event {
+    n := *ev
+    if ev.Cell != nil {
+        n.Cell = ev.Cell.clone()
+    }
+    if ev.Genome != nil {
+        n.Genome = append(gene.Genome(nil), ev.Genome...)
+    }
+    if ev.Values != nil {
+        n.Values = make(Stats, len(ev.Values))
+        for k, v := range ev.Values {
+            n.Values[k] = v
+        }
+    }
+    return &n
+}
+
+func (dt *Delta) setTick(t int64) {
+    dt.Tick = t
+    for _, ev := range dt.Events {
+        ev.Tick = t
+    }
+}
@@ -0,0 +1,52 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "math"
+)
+
+type execBatch struct {
+    ticks int64
+    n int
+    fill bool
+}
+
+func (e *Env) execCount(config Config) int {
+    live := e.liveCells.len()
+
+    n := 1
+    if config.ExecsPerTick > 0 {
+        n = config.ExecsPerTick
+    }
+    if config.ExecDensity > 0 {
+        if d := int(math.Ceil(config.ExecDensity * float64(live))); d > n {
+            n = d
+        }
+    }
+    if config.ExecsPerTickFunc != nil {
+        n = config.ExecsPerTickFunc(live)
+    }
+    if n > live {
+        n = live
+    }
+    if n < 1 {
+        n = 1
+    }
+
+    return n
+}
+
+func (e *Env) execBatches(ticks int64, send func(execBatch)) {
+    n := e.execCount(e.GetConfig())
+    w := e.Workers()
+    size := (n + w - 1) / w
+
+    for sent := 0; sent < n; sent += size {
+        b := execBatch{ticks: ticks, n: size, fill: sent == 0}
+        if n - sent < size {
+            b.n = n - sent
+        }
+        send(b)
+    }
+}
@@ -0,0 +1,91 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "expvar"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+const expvarPrefix = "petri."
+
+type execRate struct {
+    mutex sync.Mutex
+    at time.Time
+    execs int64
+    rate float64
+}
+
+var (
+    expvarEnv atomic.Value
+    expvarOnce sync.Once
+    expvarRate execRate
+)
+
+func expvarLoad() *Env {
+    e, _ := expvarEnv.Load().(*Env)
+    return e
+}
+
+func (r *execRate) sample(e *Env) float64 {
+    r.mutex.Lock()
+    defer r.mutex.Unlock()
+
+    now := e.GetClock().Now()
+    execs := e.Execs()
+    if !r.at.IsZero() && execs >= r.execs {
+        if d := now.Sub(r.at); d >= time.Second {
+            r.rate = float64(execs - r.execs) / d.Seconds()
+        } else {
+            return r.rate
+        }
+    }
+    r.at = now
+    r.execs = execs
+
+    return r.rate
+}
+
+func PublishExpvar(e *Env) {
+    expvarEnv.Store(e)
+
+    expvarRate.mutex.Lock()
+    expvarRate.at = time.Time{}
+    expvarRate.rate = 0
+    expvarRate.mutex.Unlock()
+
+    expvarOnce.Do(func() {
+        publish := func(name string, f func(e *Env) interface{}) {
+            expvar.Publish(expvarPrefix + name, expvar.Func(func() interface{} {
+                if e := expvarLoad(); e != nil {
+                    return f(e)
+                }
+                return nil
+            }))
+        }
+
+        publish("run_id", func(e *Env) interface{} {
+            return e.RunID()
+        })
+        publish("tick", func(e *Env) interface{} {
+            return e.Ticks()
+        })
+        publish("population", func(e *Env) interface{} {
+            return e.liveCells.len()
+        })
+        publish("execs", func(e *Env) interface{} {
+            return e.Execs()
+        })
+        publish("execs_per_sec", func(e *Env) interface{} {
+            return expvarRate.sample(e)
+        })
+        publish("backlog", func(e *Env) interface{} {
+            return e.Backlog()
+        })
+        publish("queues", func(e *Env) interface{} {
+            return e.QueueStats()
+        })
+    })
+}
@@ -0,0 +1,75 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+const fairnessEvery = 256
+const fairSamples = 4
+
+func (c *Cell) execRate(tick int64) float64 {
+    age := tick - c.Born + 1
+    if age < 1 {
+        age = 1
+    }
+    return float64(c.Execs) / float64(age)
+}
+
+func (e *Env) fairnessLocked(tick int64) float64 {
+    var sum, sq float64
+    n := 0
+    for _, idx := range e.liveCells.all() {
+        r := e.cells[idx].execRate(tick)
+        sum += r
+        sq += r * r
+        n++
+    }
+    if n == 0 || sq == 0 {
+        return 1
+    }
+    return sum * sum / (float64(n) * sq)
+}
+
+func (e *Env) Fairness() float64 {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+    return e.fairnessLocked(e.Ticks())
+}
+
+func (e *Env) updateFairness(config Config, dt *Delta) {
+    if config.Scheduler != SchedulerFair && !config.TrackFairness {
+        return
+    }
+    e.fairnessN++
+    if e.fairnessN < fairnessEvery {
+        return
+    }
+    e.fairnessN = 0
+    dt.Stats["ExecFairness"] = int64(e.fairnessLocked(dt.Tick) * 1000)
+}
+
+func (e *Env) getFairCell(ctx *Context) *Cell {
+    e.mutex.Lock()
+    defer e.mutex.Unlock()
+
+    live := e.liveCells.all()
+    if len(live) == 0 {
+        return nil
+    }
+
+    best := int32(-1)
+    var rate float64
+    for i := 0; i < fairSamples; i++ {
+        idx := live[ctx.rand.Intn(len(live))]
+        if _, exec := e.execCells[idx]; exec {
+            continue
+        }
+        if r := e.cells[idx].execRate(ctx.tick); best < 0 || r < rate {
+            best, rate = idx, r
+        }
+    }
+    if best < 0 {
+        return nil
+    }
+
+    e.execCells[best] = struct{}{}
+    return e.acquireCell(e.cells[best])
+}
@@ -0,0 +1,81 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "sort"
+)
+
+type Frame struct {
+    Tick int64
+    FirstSeq int64
+    LastSeq int64
+    Deltas int
+    Cells []*Cell
+    Stats Stats
+}
+
+type frameBuilder struct {
+    frame *Frame
+    cellMap CellMap
+}
+
+func (fb *frameBuilder) add(dt *Delta) {
+    if fb.frame == nil {
+        fb.frame = &Frame{
+            Tick: dt.Tick,
+            FirstSeq: dt.Seq,
+            Stats: make(Stats),
+        }
+    }
+
+    f := fb.frame
+    if dt.Seq < f.FirstSeq {
+        f.FirstSeq = dt.Seq
+    }
+    if dt.Seq > f.LastSeq {
+        f.LastSeq = dt.Seq
+    }
+    f.Deltas++
+    f.Stats.Add(dt.Stats)
+
+    for _, c := range dt.Cells {
+        fb.cellMap.AddCell(c)
+    }
+}
+
+func (fb *frameBuilder) flush() *Frame {
+    f := fb.frame
+    if f == nil {
+        return nil
+    }
+
+    f.Cells = fb.cellMap.Cells()
+    sort.Slice(f.Cells, func(i, j int) bool {
+        return f.Cells[i].Idx < f.Cells[j].Idx
+    })
+
+    fb.frame = nil
+    fb.cellMap.Reset()
+
+    return f
+}
+
+func Frames(deltas <-chan *Delta, frames chan<- *Frame) {
+    defer close(frames)
+
+    fb := &frameBuilder{
+        cellMap: make(CellMap),
+    }
+
+    for dt := range deltas {
+        if fb.frame != nil && dt.Tick > fb.frame.Tick {
+            frames <- fb.flush()
+        }
+        fb.add(dt)
+    }
+
+    if f := fb.flush(); f != nil {
+        frames <- f
+    }
+}
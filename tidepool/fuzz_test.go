@@ -0,0 +1,53 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "testing"
+
+    "tidepool/tidepool/gene"
+)
+
+func FuzzExecGenome(f *testing.F) {
+    f.Add([]byte("0B."), int64(100))
+    f.Add([]byte("[[[]]]+[-]"), int64(1000))
+    f.Add([]byte("}}}}xkstre{{{{"), int64(50))
+
+    f.Fuzz(func(t *testing.T, b []byte, energy int64) {
+        if energy < 0 {
+            energy = -energy
+        }
+        energy %= 1 << 16
+
+        env := NewEnv(4, 4, 32, 0, 1)
+        config := env.GetConfig()
+        config.ISA = ISALatest
+        env.SetConfig(config)
+
+        g := gene.NormalizeGenome(b, int(env.GenomeSize))
+        if _, err := env.InjectCell(1, 1, g, energy); err != nil {
+            t.Fatal(err)
+        }
+        for i := 0; i < 8; i++ {
+            env.Advance()
+        }
+    })
+}
+
+func FuzzValidateGenome(f *testing.F) {
+    f.Add([]byte("0B."))
+    f.Add([]byte(""))
+
+    f.Fuzz(func(t *testing.T, b []byte) {
+        if err := gene.ValidateGenome(b); err != nil {
+            return
+        }
+        g, err := gene.ParseGenome(string(b))
+        if err != nil {
+            t.Fatalf("valid genome failed to parse: %v", err)
+        }
+        if g.String() != string(b) {
+            t.Fatalf("round trip mismatch: %q", b)
+        }
+    })
+}
@@ -0,0 +1,153 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package gene
+
+import (
+    "fmt"
+    "strings"
+)
+
+type AlignOp int
+
+const (
+    AlignMatch AlignOp = iota
+    AlignSubst
+    AlignInsert
+    AlignDelete
+)
+
+const alignLineWidth = 64
+
+type AlignStep struct {
+    Op AlignOp
+    A int
+    B int
+}
+
+type Alignment struct {
+    A Genome
+    B Genome
+    Steps []AlignStep
+    Distance int
+}
+
+func AlignGenomes(a, b Genome) Alignment {
+    n, m := len(a), len(b)
+
+    d := make([][]int, n + 1)
+    for i := range d {
+        d[i] = make([]int, m + 1)
+        d[i][0] = i
+    }
+    for j := 0; j <= m; j++ {
+        d[0][j] = j
+    }
+
+    for i := 1; i <= n; i++ {
+        for j := 1; j <= m; j++ {
+            cost := 1
+            if a[i - 1] == b[j - 1] {
+                cost = 0
+            }
+            v := d[i - 1][j - 1] + cost
+            if x := d[i - 1][j] + 1; x < v {
+                v = x
+            }
+            if x := d[i][j - 1] + 1; x < v {
+                v = x
+            }
+            d[i][j] = v
+        }
+    }
+
+    steps := make([]AlignStep, 0, n + m)
+    i, j := n, m
+    for i > 0 || j > 0 {
+        switch {
+        case i > 0 && j > 0 && a[i - 1] == b[j - 1] && d[i][j] == d[i - 1][j - 1]:
+            i--
+            j--
+            steps = append(steps, AlignStep{AlignMatch, i, j})
+        case i > 0 && j > 0 && d[i][j] == d[i - 1][j - 1] + 1:
+            i--
+            j--
+            steps = append(steps, AlignStep{AlignSubst, i, j})
+        case i > 0 && d[i][j] == d[i - 1][j] + 1:
+            i--
+            steps = append(steps, AlignStep{AlignDelete, i, -1})
+        default:
+            j--
+            steps = append(steps, AlignStep{AlignInsert, -1, j})
+        }
+    }
+
+    for l, r := 0, len(steps) - 1; l < r; l, r = l + 1, r - 1 {
+        steps[l], steps[r] = steps[r], steps[l]
+    }
+
+    return Alignment{
+        A: a,
+        B: b,
+        Steps: steps,
+        Distance: d[n][m],
+    }
+}
+
+func (al Alignment) String() string {
+    var sb, ra, rm, rb strings.Builder
+
+    flush := func() {
+        if ra.Len() == 0 {
+            return
+        }
+        fmt.Fprintf(&sb, "A %s\n  %s\nB %s\n\n", ra.String(), rm.String(),
+            rb.String())
+        ra.Reset()
+        rm.Reset()
+        rb.Reset()
+    }
+
+    for i, s := range al.Steps {
+        if i > 0 && i % alignLineWidth == 0 {
+            flush()
+        }
+        switch s.Op {
+        case AlignMatch:
+            ra.WriteString(al.A[s.A].String())
+            rm.WriteByte('|')
+            rb.WriteString(al.B[s.B].String())
+        case AlignSubst:
+            ra.WriteString(al.A[s.A].String())
+            rm.WriteByte('*')
+            rb.WriteString(al.B[s.B].String())
+        case AlignDelete:
+            ra.WriteString(al.A[s.A].String())
+            rm.WriteByte(' ')
+            rb.WriteByte(' ')
+        case AlignInsert:
+            ra.WriteByte(' ')
+            rm.WriteByte(' ')
+            rb.WriteString(al.B[s.B].String())
+        }
+    }
+    flush()
+
+    return sb.String()
+}
+
+func (al Alignment) Diff() string {
+    var sb strings.Builder
+
+    for _, s := range al.Steps {
+        switch s.Op {
+        case AlignSubst:
+            fmt.Fprintf(&sb, "%d: %s -> %s\n", s.A, al.A[s.A], al.B[s.B])
+        case AlignDelete:
+            fmt.Fprintf(&sb, "%d: -%s\n", s.A, al.A[s.A])
+        case AlignInsert:
+            fmt.Fprintf(&sb, "%d: +%s\n", s.B, al.B[s.B])
+        }
+    }
+
+    return sb.String()
+}
@@ -0,0 +1,78 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package gene
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestAlignGenomes(t *testing.T) {
+    tests := []struct {
+        name string
+        a Genome
+        b Genome
+        steps []AlignStep
+        distance int
+        diff string
+    }{
+        {
+            name: "identical",
+            a: Genome{INC, FWD, DEC},
+            b: Genome{INC, FWD, DEC},
+            steps: []AlignStep{{AlignMatch, 0, 0}, {AlignMatch, 1, 1}, {AlignMatch, 2, 2}},
+        },
+        {
+            name: "substitution",
+            a: Genome{INC, FWD},
+            b: Genome{INC, BACK},
+            steps: []AlignStep{{AlignMatch, 0, 0}, {AlignSubst, 1, 1}},
+            distance: 1,
+            diff: "1: " + FWD.String() + " -> " + BACK.String() + "\n",
+        },
+        {
+            name: "insertion",
+            a: Genome{INC, DEC},
+            b: Genome{INC, FWD, DEC},
+            steps: []AlignStep{{AlignMatch, 0, 0}, {AlignInsert, -1, 1}, {AlignMatch, 1, 2}},
+            distance: 1,
+            diff: "1: +" + FWD.String() + "\n",
+        },
+        {
+            name: "deletion",
+            a: Genome{INC, FWD, DEC},
+            b: Genome{INC, DEC},
+            steps: []AlignStep{{AlignMatch, 0, 0}, {AlignDelete, 1, -1}, {AlignMatch, 2, 1}},
+            distance: 1,
+            diff: "1: -" + FWD.String() + "\n",
+        },
+        {
+            name: "empty",
+            a: Genome{},
+            b: Genome{INC},
+            steps: []AlignStep{{AlignInsert, -1, 0}},
+            distance: 1,
+            diff: "0: +" + INC.String() + "\n",
+        },
+        {
+            name: "both empty",
+            steps: []AlignStep{},
+        },
+    }
+
+    for _, tt := range tests {
+        al := AlignGenomes(tt.a, tt.b)
+        if !reflect.DeepEqual(al.Steps, tt.steps) {
+            t.Errorf("%s: expected steps %v, got %v", tt.name, tt.steps, al.Steps)
+        }
+        if al.Distance != tt.distance {
+            t.Errorf("%s: expected distance %d, got %d", tt.name, tt.distance, al.Distance)
+        }
+        if d := al.Diff(); d != tt.diff {
+            t.Errorf("%s: expected diff %q, got %q", tt.name, tt.diff, d)
+        }
+        if len(tt.steps) > 0 && al.String() == "" {
+            t.Errorf("%s: expected rendered alignment", tt.name)
+        }
+    }
+}
@@ -4,6 +4,9 @@ package gene
 
 import (
     "encoding/json"
+    "fmt"
+    "hash/fnv"
+    "strings"
 )
 
 type Gene int
@@ -30,6 +33,15 @@ const (
     N
 )
 
+const (
+    RAND Gene = N + iota
+    SENSE
+    MOVE
+    DORM
+
+    NMax
+)
+
 var geneChars = map[Gene]string{
     ZERO: "0",
     FWD: "}",
@@ -47,12 +59,63 @@ var geneChars = map[Gene]string{
     KILL: "k",
     SHARE: "s",
     STOP: ".",
+    RAND: "r",
+    SENSE: "e",
+    MOVE: "m",
+    DORM: "z",
+}
+
+var geneNames = map[Gene]string{
+    ZERO: "ZERO",
+    FWD: "FWD",
+    BACK: "BACK",
+    INC: "INC",
+    DEC: "DEC",
+    READG: "READG",
+    WRITEG: "WRITEG",
+    READB: "READB",
+    WRITEB: "WRITEB",
+    LOOP: "LOOP",
+    REP: "REP",
+    TURN: "TURN",
+    XCHG: "XCHG",
+    KILL: "KILL",
+    SHARE: "SHARE",
+    STOP: "STOP",
+    RAND: "RAND",
+    SENSE: "SENSE",
+    MOVE: "MOVE",
+    DORM: "DORM",
+}
+
+var charGenes = make(map[rune]Gene, NMax)
+
+func init() {
+    for g, c := range geneChars {
+        charGenes[rune(c[0])] = g
+    }
+}
+
+func ParseGenome(s string) (Genome, error) {
+    g := make(Genome, 0, len(s))
+    for i, c := range s {
+        v, ok := charGenes[c]
+        if !ok {
+            return nil, fmt.Errorf("gene: invalid gene %q at %d", c, i)
+        }
+        g = append(g, v)
+    }
+    return g, nil
 }
 
 func (g Gene) String() string {
     return geneChars[g]
 }
 
+func (g Gene) Name() string {
+    return geneNames[g]
+}
+
 func (g Genome) String() string {
     var s string
     for _, gene := range g {
@@ -61,6 +124,37 @@ func (g Genome) String() string {
     return s
 }
 
+func (g Genome) Disassemble() string {
+    var sb strings.Builder
+    for i, gene := range g {
+        fmt.Fprintf(&sb, "%04d  %s  %s\n", i, gene, gene.Name())
+    }
+    return sb.String()
+}
+
 func (g Genome) MarshalJSON() ([]byte, error) {
     return json.Marshal(g.String())
 }
+
+func (g *Genome) UnmarshalJSON(data []byte) error {
+    var s string
+    if err := json.Unmarshal(data, &s); err != nil {
+        return err
+    }
+    v, err := ParseGenome(s)
+    if err != nil {
+        return err
+    }
+    *g = v
+    return nil
+}
+
+func (g Genome) Hash() uint64 {
+    h := fnv.New64a()
+    buf := make([]byte, len(g))
+    for i, v := range g {
+        buf[i] = byte(v)
+    }
+    h.Write(buf)
+    return h.Sum64()
+}
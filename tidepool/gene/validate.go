@@ -0,0 +1,54 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package gene
+
+import (
+    "errors"
+    "fmt"
+)
+
+const (
+    MinGenomeSize = 2
+    MaxGenomeSize = 1 << 16
+)
+
+var ErrEmptyGenome = errors.New("gene: empty genome")
+
+func ValidateGenome(b []byte) error {
+    if len(b) == 0 {
+        return ErrEmptyGenome
+    }
+    if len(b) > MaxGenomeSize {
+        return fmt.Errorf("gene: genome exceeds %d genes", MaxGenomeSize)
+    }
+    for i, c := range b {
+        if _, ok := charGenes[rune(c)]; !ok {
+            return fmt.Errorf("gene: invalid gene %q at %d", c, i)
+        }
+    }
+    return nil
+}
+
+func NormalizeGenome(b []byte, size int) Genome {
+    if size < MinGenomeSize {
+        size = MinGenomeSize
+    }
+    if size > MaxGenomeSize {
+        size = MaxGenomeSize
+    }
+
+    g := make(Genome, 0, size)
+    for _, c := range b {
+        if len(g) == size {
+            break
+        }
+        if v, ok := charGenes[rune(c)]; ok {
+            g = append(g, v)
+        }
+    }
+    for len(g) < size {
+        g = append(g, STOP)
+    }
+
+    return g
+}
@@ -0,0 +1,147 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+
+    "tidepool/tidepool/gene"
+)
+
+const genomeLineWidth = 80
+
+type GenomeRecord struct {
+    Hash uint64
+    Tick int64
+    Origin int64
+    Parent int64
+    Generation int64
+    Genome gene.Genome
+}
+
+func NewGenomeRecord(c *Cell, tick int64) GenomeRecord {
+    g := make(gene.Genome, len(c.Genome))
+    copy(g, c.Genome)
+
+    return GenomeRecord{
+        Hash: g.Hash(),
+        Tick: tick,
+        Origin: c.Origin,
+        Parent: c.Parent,
+        Generation: c.Generation,
+        Genome: g,
+    }
+}
+
+func ExportGenomes(w io.Writer, rs []GenomeRecord) error {
+    bw := bufio.NewWriter(w)
+
+    for _, r := range rs {
+        fmt.Fprintf(bw, ">%016x tick=%d origin=%d parent=%d generation=%d\n",
+            r.Genome.Hash(), r.Tick, r.Origin, r.Parent, r.Generation)
+
+        s := r.Genome.String()
+        for len(s) > genomeLineWidth {
+            fmt.Fprintln(bw, s[:genomeLineWidth])
+            s = s[genomeLineWidth:]
+        }
+        fmt.Fprintln(bw, s)
+    }
+
+    return bw.Flush()
+}
+
+func parseGenomeHeader(line string, r *GenomeRecord) error {
+    fields := strings.Fields(line)
+    if len(fields) == 0 {
+        return fmt.Errorf("missing genome hash")
+    }
+
+    h, err := strconv.ParseUint(fields[0], 16, 64)
+    if err != nil {
+        return err
+    }
+    r.Hash = h
+
+    for _, f := range fields[1:] {
+        kv := strings.SplitN(f, "=", 2)
+        if len(kv) != 2 {
+            return fmt.Errorf("invalid header field %q", f)
+        }
+        v, err := strconv.ParseInt(kv[1], 10, 64)
+        if err != nil {
+            return err
+        }
+        switch kv[0] {
+        case "tick":
+            r.Tick = v
+        case "origin":
+            r.Origin = v
+        case "parent":
+            r.Parent = v
+        case "generation":
+            r.Generation = v
+        }
+    }
+
+    return nil
+}
+
+func ImportGenomes(r io.Reader) ([]GenomeRecord, error) {
+    var rs []GenomeRecord
+    var seq strings.Builder
+
+    flush := func() error {
+        if len(rs) == 0 {
+            return nil
+        }
+        g, err := gene.ParseGenome(seq.String())
+        if err != nil {
+            return err
+        }
+        rec := &rs[len(rs) - 1]
+        if g.Hash() != rec.Hash {
+            return fmt.Errorf("genome hash mismatch: %016x", rec.Hash)
+        }
+        rec.Genome = g
+        seq.Reset()
+        return nil
+    }
+
+    s := bufio.NewScanner(r)
+    n := 0
+    for s.Scan() {
+        n++
+        line := strings.TrimSpace(s.Text())
+        if line == "" {
+            continue
+        }
+        if line[0] == '>' {
+            if err := flush(); err != nil {
+                return nil, fmt.Errorf("line %d: %v", n, err)
+            }
+            var rec GenomeRecord
+            if err := parseGenomeHeader(line[1:], &rec); err != nil {
+                return nil, fmt.Errorf("line %d: %v", n, err)
+            }
+            rs = append(rs, rec)
+            continue
+        }
+        if len(rs) == 0 {
+            return nil, fmt.Errorf("line %d: genome without header", n)
+        }
+        seq.WriteString(line)
+    }
+    if err := s.Err(); err != nil {
+        return nil, err
+    }
+    if err := flush(); err != nil {
+        return nil, fmt.Errorf("line %d: %v", n, err)
+    }
+
+    return rs, nil
+}
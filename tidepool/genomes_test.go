@@ -0,0 +1,45 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "fmt"
+    "reflect"
+    "strings"
+    "testing"
+
+    "tidepool/tidepool/gene"
+)
+
+func TestGenomesRoundTrip(t *testing.T) {
+    g := make(gene.Genome, 200)
+    for i := range g {
+        g[i] = gene.Gene(i % int(gene.N))
+    }
+    c := &Cell{Origin: 3, Parent: 5, Generation: 2, Genome: g}
+    rs := []GenomeRecord{
+        NewGenomeRecord(c, 42),
+        NewGenomeRecord(&Cell{Genome: gene.Genome{gene.STOP}}, 7),
+    }
+
+    var buf strings.Builder
+    if err := ExportGenomes(&buf, rs); err != nil {
+        t.Fatal(err)
+    }
+    got, err := ImportGenomes(strings.NewReader(buf.String()))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(got, rs) {
+        t.Fatalf("expected %+v, got %+v", rs, got)
+    }
+}
+
+func TestImportGenomesHashMismatch(t *testing.T) {
+    g := gene.Genome{gene.INC, gene.WRITEB, gene.STOP}
+    in := fmt.Sprintf(">%016x tick=1\n%s\n", g.Hash() + 1, g)
+    if _, err := ImportGenomes(strings.NewReader(in)); err == nil ||
+        !strings.Contains(err.Error(), "hash mismatch") {
+        t.Fatalf("expected hash mismatch, got %v", err)
+    }
+}
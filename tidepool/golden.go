@@ -0,0 +1,204 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "encoding/binary"
+    "fmt"
+    "hash/fnv"
+)
+
+const goldenLockstepWorkers = 4
+
+type GoldenCheckpoint struct {
+    Tick int64
+    Checksum uint64
+}
+
+type GoldenVector struct {
+    Name string
+    Width int32
+    Height int32
+    GenomeSize int32
+    Pop int32
+    Seed int64
+    Preset string `json:",omitempty"`
+    Lockstep bool `json:",omitempty"`
+    Checkpoints []GoldenCheckpoint
+}
+
+type GoldenResult struct {
+    Tick int64
+    Want uint64
+    Got uint64
+}
+
+func (r GoldenResult) OK() bool {
+    return r.Want == r.Got
+}
+
+var goldenVectors = []GoldenVector{
+    {
+        Name: "default",
+        Width: 64,
+        Height: 64,
+        GenomeSize: 256,
+        Pop: 64,
+        Seed: 1,
+        Checkpoints: []GoldenCheckpoint{
+            {1000, 0x38fede324433c672},
+            {10000, 0x8b6d49499a25b117},
+            {100000, 0x3c966a2017bfe212},
+        },
+    },
+    {
+        Name: "predation",
+        Width: 64,
+        Height: 64,
+        GenomeSize: 256,
+        Pop: 64,
+        Seed: 2,
+        Preset: "predation",
+        Checkpoints: []GoldenCheckpoint{
+            {1000, 0xebe29f85a0274aaa},
+            {10000, 0x08274ef1ad07d10d},
+            {100000, 0xed0d5f92fdf3ddee},
+        },
+    },
+    {
+        Name: "lockstep",
+        Width: 64,
+        Height: 64,
+        GenomeSize: 256,
+        Pop: 64,
+        Seed: 3,
+        Lockstep: true,
+        Checkpoints: []GoldenCheckpoint{
+            {1000, 0xcce4e52b9dca4ca5},
+            {10000, 0xd132d57314212df9},
+            {100000, 0xe7743ee59ff5e0b7},
+        },
+    },
+}
+
+func GoldenVectors() []GoldenVector {
+    vs := make([]GoldenVector, len(goldenVectors))
+    for i, v := range goldenVectors {
+        vs[i] = v
+        vs[i].Checkpoints = append([]GoldenCheckpoint(nil), v.Checkpoints...)
+    }
+    return vs
+}
+
+func LookupGoldenVector(name string) (GoldenVector, error) {
+    for _, v := range GoldenVectors() {
+        if v.Name == name {
+            return v, nil
+        }
+    }
+    return GoldenVector{}, fmt.Errorf("unknown golden vector %q", name)
+}
+
+func (e *Env) StateChecksum() uint64 {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+
+    h := fnv.New64a()
+    var buf []byte
+    put := func(vs ...int64) {
+        for _, v := range vs {
+            buf = binary.LittleEndian.AppendUint64(buf, uint64(v))
+        }
+    }
+
+    put(int64(e.Width), int64(e.Height), int64(e.GenomeSize),
+        e.Ticks(), e.LastCellID())
+    h.Write(buf)
+
+    for _, c := range e.cells {
+        buf = buf[:0]
+        dormant := int64(0)
+        if c.Dormant {
+            dormant = 1
+        }
+        put(c.ID, c.Origin, c.Parent, c.Generation, c.Born, c.Execs,
+            c.Energy, dormant)
+        for _, g := range c.Genome {
+            buf = append(buf, byte(g))
+        }
+        h.Write(buf)
+    }
+
+    return h.Sum64()
+}
+
+func (v GoldenVector) NewEnv() (*Env, error) {
+    e := NewEnv(v.Width, v.Height, v.GenomeSize, v.Pop, v.Seed)
+    if v.Preset != "" {
+        p, err := LookupPreset(v.Preset)
+        if err == nil {
+            err = e.ApplyPreset(p)
+        }
+        if err != nil {
+            return nil, err
+        }
+    }
+    return e, nil
+}
+
+func (v GoldenVector) run(maxTick int64, f func(i int, sum uint64) bool) error {
+    e, err := v.NewEnv()
+    if err != nil {
+        return err
+    }
+
+    var dts []*Delta
+    for i, cp := range v.Checkpoints {
+        if maxTick > 0 && cp.Tick > maxTick {
+            break
+        }
+        for e.Ticks() < cp.Tick {
+            if v.Lockstep {
+                dts = e.AdvanceLockstep(goldenLockstepWorkers, dts[:0])
+            } else {
+                dts = e.AdvanceInto(dts[:0])
+            }
+            for _, dt := range dts {
+                dt.Release()
+            }
+        }
+        if !f(i, e.StateChecksum()) {
+            break
+        }
+    }
+    return nil
+}
+
+func (v GoldenVector) Record() (GoldenVector, error) {
+    v.Checkpoints = append([]GoldenCheckpoint(nil), v.Checkpoints...)
+    err := v.run(0, func(i int, sum uint64) bool {
+        v.Checkpoints[i].Checksum = sum
+        return true
+    })
+    return v, err
+}
+
+func (v GoldenVector) Verify(maxTick int64) ([]GoldenResult, error) {
+    var rs []GoldenResult
+    var mismatch error
+    err := v.run(maxTick, func(i int, sum uint64) bool {
+        cp := v.Checkpoints[i]
+        r := GoldenResult{Tick: cp.Tick, Want: cp.Checksum, Got: sum}
+        rs = append(rs, r)
+        if !r.OK() {
+            mismatch = fmt.Errorf("%s: checksum mismatch at tick %d: got %016x, want %016x",
+                v.Name, r.Tick, r.Got, r.Want)
+            return false
+        }
+        return true
+    })
+    if err != nil {
+        return rs, err
+    }
+    return rs, mismatch
+}
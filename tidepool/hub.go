@@ -0,0 +1,202 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "sync"
+    "sync/atomic"
+)
+
+type Policy int
+
+const (
+    PolicyBlock Policy = iota
+    PolicyDropOldest
+    PolicySample
+)
+
+type SubscribeOptions struct {
+    Policy Policy
+    Buffer int
+    SampleEvery int64
+    KeyframeEvery int64
+}
+
+type Subscription struct {
+    // Deltas are shared between subscribers and must not be Released.
+    C <-chan *Delta
+
+    id int
+    ch chan *Delta
+    done chan struct{}
+    stop sync.Once
+    opts SubscribeOptions
+    keyframeRun string
+    keyframeSeq int64
+
+    received int64
+    delivered int64
+    dropped int64
+}
+
+type Hub struct {
+    env *Env
+    deltas <-chan *Delta
+
+    mutex *sync.RWMutex
+    subs map[int]*Subscription
+    views map[int]*ViewportSubscription
+    nextID int
+}
+
+func NewHub(e *Env, d <-chan *Delta) *Hub {
+    return &Hub{
+        env: e,
+        deltas: d,
+        mutex: &sync.RWMutex{},
+        subs: make(map[int]*Subscription),
+        views: make(map[int]*ViewportSubscription),
+    }
+}
+
+func (h *Hub) Subscribe(opts SubscribeOptions) *Subscription {
+    if opts.Policy == PolicySample && opts.SampleEvery < 1 {
+        opts.SampleEvery = 1
+    }
+    if opts.Policy != PolicyBlock && opts.Buffer < 1 {
+        opts.Buffer = 1
+    }
+
+    ch := make(chan *Delta, opts.Buffer)
+    s := &Subscription{
+        C: ch,
+        ch: ch,
+        done: make(chan struct{}),
+        opts: opts,
+    }
+
+    h.mutex.Lock()
+    s.id = h.nextID
+    h.nextID++
+    h.subs[s.id] = s
+    h.mutex.Unlock()
+
+    return s
+}
+
+func (s *Subscription) cancel() {
+    s.stop.Do(func() {
+        close(s.done)
+    })
+}
+
+func (h *Hub) Unsubscribe(s *Subscription) {
+    s.cancel()
+
+    h.mutex.Lock()
+    defer h.mutex.Unlock()
+
+    if _, ok := h.subs[s.id]; !ok {
+        return
+    }
+    delete(h.subs, s.id)
+    close(s.ch)
+}
+
+func (s *Subscription) Dropped() int64 {
+    return atomic.LoadInt64(&s.dropped)
+}
+
+func (h *Hub) keyframe(dt *Delta) *Delta {
+    kf := &Delta{
+        RunID: dt.RunID,
+        Stats: make(Stats, len(dt.Stats)),
+        Keyframe: true,
+    }
+    kf.Stats.Add(dt.Stats)
+    h.env.withSnapshot(func(seq, ticks int64, cs []*Cell) {
+        kf.Seq = seq
+        kf.Tick = ticks
+        kf.Cells = make([]*Cell, len(cs))
+        for i, c := range cs {
+            kf.Cells[i] = c.clone()
+        }
+    })
+    return kf
+}
+
+func (h *Hub) send(s *Subscription, dt *Delta) {
+    select {
+    case <-s.done:
+        return
+    default:
+    }
+    if dt.RunID == s.keyframeRun && dt.Seq <= s.keyframeSeq {
+        return
+    }
+
+    s.received++
+
+    if s.opts.Policy == PolicySample && (s.received - 1) % s.opts.SampleEvery != 0 {
+        return
+    }
+
+    s.delivered++
+    if n := s.opts.KeyframeEvery; n > 0 && s.delivered % n == 0 {
+        dt = h.keyframe(dt)
+        s.keyframeRun, s.keyframeSeq = dt.RunID, dt.Seq
+    }
+
+    if s.opts.Policy == PolicyBlock {
+        select {
+        case s.ch <- dt:
+        case <-s.done:
+        }
+        return
+    }
+
+    for {
+        select {
+        case s.ch <- dt:
+            return
+        default:
+        }
+        if s.opts.Policy != PolicyDropOldest {
+            atomic.AddInt64(&s.dropped, 1)
+            return
+        }
+        select {
+        case <-s.ch:
+            atomic.AddInt64(&s.dropped, 1)
+        default:
+        }
+    }
+}
+
+func (h *Hub) Run() {
+    defer func() {
+        h.mutex.Lock()
+        for id, s := range h.subs {
+            s.cancel()
+            close(s.ch)
+            delete(h.subs, id)
+        }
+        for id, s := range h.views {
+            close(s.done)
+            close(s.ch)
+            delete(h.views, id)
+        }
+        h.mutex.Unlock()
+    }()
+
+    for dt := range h.deltas {
+        h.mutex.RLock()
+        for _, s := range h.subs {
+            h.send(s, dt)
+        }
+        for _, s := range h.views {
+            h.sendView(s, dt)
+        }
+        h.mutex.RUnlock()
+    }
+}
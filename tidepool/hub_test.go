@@ -0,0 +1,94 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "testing"
+    "time"
+)
+
+func TestHubUnsubscribeBlocked(t *testing.T) {
+    env := NewEnv(8, 8, 16, 0, 1)
+    deltas := make(chan *Delta)
+    hub := NewHub(env, deltas)
+
+    sub := hub.Subscribe(SubscribeOptions{Policy: PolicyBlock})
+    done := make(chan struct{})
+    go func() {
+        hub.Run()
+        close(done)
+    }()
+
+    deltas <- &Delta{Seq: 1, Stats: make(Stats)}
+    time.Sleep(10 * time.Millisecond)
+
+    unsubscribed := make(chan struct{})
+    go func() {
+        hub.Unsubscribe(sub)
+        close(unsubscribed)
+    }()
+
+    select {
+    case <-unsubscribed:
+    case <-time.After(time.Second):
+        t.Fatal("unsubscribing a stalled subscriber deadlocked the hub")
+    }
+
+    select {
+    case deltas <- &Delta{Seq: 2, Stats: make(Stats)}:
+    case <-time.After(time.Second):
+        t.Fatal("hub stopped consuming deltas")
+    }
+
+    close(deltas)
+    <-done
+
+    for range sub.C {
+    }
+}
+
+func TestHubKeyframeSeq(t *testing.T) {
+    env := NewEnv(8, 8, 16, 0, 1)
+    for i := 0; i < 3; i++ {
+        c := env.GetCell(int32(i), 0)
+        c.Energy = 100
+        env.applyDelta(&Delta{Cells: []*Cell{c}, Stats: Stats{"Ticks": 1}})
+    }
+
+    deltas := make(chan *Delta, 8)
+    hub := NewHub(env, deltas)
+    sub := hub.Subscribe(SubscribeOptions{
+        Policy: PolicyDropOldest,
+        Buffer: 4,
+        KeyframeEvery: 2,
+    })
+
+    stats := Stats{"Ticks": 1}
+    runID := env.RunID()
+    deltas <- &Delta{RunID: runID, Seq: 1, Stats: make(Stats)}
+    deltas <- &Delta{RunID: runID, Seq: 2, Stats: stats}
+    deltas <- &Delta{RunID: runID, Seq: 3, Stats: make(Stats)}
+    deltas <- &Delta{RunID: runID, Seq: 4, Stats: make(Stats)}
+    close(deltas)
+    hub.Run()
+
+    var got []*Delta
+    for dt := range sub.C {
+        got = append(got, dt)
+    }
+    if len(got) != 3 {
+        t.Fatalf("expected 3 deltas, got %d", len(got))
+    }
+
+    kf := got[1]
+    if !kf.Keyframe || kf.Seq != 3 {
+        t.Fatalf("expected keyframe at seq 3, got %+v", kf)
+    }
+    kf.Stats["Ticks"]++
+    if stats["Ticks"] != 1 {
+        t.Fatal("keyframe aliases delta stats")
+    }
+    if got[0].Seq != 1 || got[2].Seq != 4 {
+        t.Fatalf("expected seqs 1, 3, 4, got %d, %d, %d", got[0].Seq, kf.Seq, got[2].Seq)
+    }
+}
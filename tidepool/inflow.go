@@ -0,0 +1,75 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "sync"
+)
+
+const maxPendingRequests = maxBatch
+
+type requestQueue struct {
+    mutex sync.Mutex
+    inflows int
+    execs int
+    ticks int64
+    ready chan struct{}
+}
+
+func newRequestQueue() *requestQueue {
+    return &requestQueue{
+        ready: make(chan struct{}, 1),
+    }
+}
+
+func (q *requestQueue) add(inflow bool, ticks int64) {
+    q.mutex.Lock()
+    n := &q.execs
+    if inflow {
+        n = &q.inflows
+    }
+    if *n < maxPendingRequests {
+        *n++
+    }
+    if ticks > q.ticks {
+        q.ticks = ticks
+    }
+    q.mutex.Unlock()
+
+    select {
+    case q.ready <- struct{}{}:
+    default:
+    }
+}
+
+func (q *requestQueue) take() (int, int, int64) {
+    q.mutex.Lock()
+    defer q.mutex.Unlock()
+
+    inflows, execs := q.inflows, q.execs
+    q.inflows, q.execs = 0, 0
+    return inflows, execs, q.ticks
+}
+
+func (q *requestQueue) pending() int {
+    q.mutex.Lock()
+    defer q.mutex.Unlock()
+    return q.inflows + q.execs
+}
+
+func (e *Env) retry(dt *Delta) {
+    if q := e.requests; q != nil {
+        q.add(!dt.exec, dt.Tick)
+    }
+}
+
+func (e *Env) PendingRequests() int {
+    e.mutex.RLock()
+    q := e.requests
+    e.mutex.RUnlock()
+
+    if q == nil {
+        return 0
+    }
+    return q.pending()
+}
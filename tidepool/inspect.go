@@ -0,0 +1,109 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+const recentEventsSize = 256
+
+type LineageSummary struct {
+    Origin int64
+    Parent int64
+    Generation int64
+    Relatives int64
+    Mutations int
+    SubstitutionRate float64
+}
+
+type Inspection struct {
+    Cell *Cell
+    Live bool
+    Viable bool
+    Disassembly string
+    Lineage LineageSummary
+    Neighbors []*Cell
+    Events []*Event
+    Break *BreakpointHit `json:",omitempty"`
+    Phenotype *Phenotype `json:",omitempty"`
+}
+
+func (e *Env) recordEvent(ev *Event) {
+    ev = ev.clone()
+    if len(e.recent) < recentEventsSize {
+        e.recent = append(e.recent, ev)
+        return
+    }
+    e.recent[e.recentIdx] = ev
+    e.recentIdx = (e.recentIdx + 1) % recentEventsSize
+}
+
+func (e *Env) recentEvents(f func(*Event) bool) []*Event {
+    var evs []*Event
+    n := len(e.recent)
+    for i := 0; i < n; i++ {
+        ev := e.recent[(e.recentIdx + i) % n]
+        if f(ev) {
+            evs = append(evs, ev)
+        }
+    }
+    return evs
+}
+
+func (e *Env) Inspect(x, y int32) Inspection {
+    var in Inspection
+    if x < 0 || y < 0 || x >= e.Width || y >= e.Height {
+        return in
+    }
+
+    config := e.GetConfig()
+
+    e.mutex.RLock()
+    c := e.cells[x + e.Width * y].clone()
+    in.Cell = c
+    in.Live = c.live()
+    in.Viable = in.Live && c.viable(config)
+    in.Disassembly = c.Genome.Disassemble()
+
+    in.Neighbors = make([]*Cell, 4)
+    for _, dir := range []int{DirLeft, DirRight, DirUp, DirDown} {
+        in.Neighbors[dir] = e.cells[e.getNeighborIdx(c, dir)].clone()
+    }
+
+    in.Lineage = LineageSummary{
+        Origin: c.Origin,
+        Parent: c.Parent,
+        Generation: c.Generation,
+    }
+    if c.Origin != 0 {
+        for _, idx := range e.liveCells.all() {
+            if e.cells[idx].Origin == c.Origin {
+                in.Lineage.Relatives++
+            }
+        }
+        in.Lineage.Mutations = len(e.mutations[c.Origin])
+    }
+
+    in.Events = e.recentEvents(func(ev *Event) bool {
+        if ev.Cell == nil {
+            return false
+        }
+        if c.ID != 0 {
+            return ev.Cell.ID == c.ID
+        }
+        return ev.Cell.Idx == c.Idx
+    })
+    e.mutex.RUnlock()
+
+    if c.Origin != 0 {
+        in.Lineage.SubstitutionRate = e.SubstitutionRate(c.Origin).Rate
+    }
+
+    if hit, ok := e.LastBreak(); ok && c.ID != 0 && hit.CellID == c.ID {
+        in.Break = &hit
+    }
+
+    if in.Live {
+        p := e.Phenotype(c.Genome)
+        in.Phenotype = &p
+    }
+
+    return in
+}
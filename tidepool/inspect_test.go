@@ -0,0 +1,55 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "testing"
+
+    "tidepool/tidepool/gene"
+)
+
+func TestInspect(t *testing.T) {
+    env := NewEnv(8, 8, 8, 0, 1)
+    g := gene.Genome{gene.STOP, gene.STOP, gene.STOP, gene.STOP}
+    parent, err := env.InjectCell(2, 3, g, 100)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    child := env.GetCell(3, 3)
+    child.ID = parent.ID + 1
+    child.Origin = parent.Origin
+    child.Parent = parent.ID
+    child.Generation = 1
+    child.Energy = 50
+    born := child.clone()
+    dt := &Delta{Cells: []*Cell{child}, Stats: make(Stats)}
+    dt.addEvent(EventBirth, born)
+    env.applyDelta(dt)
+
+    born.ID, born.Idx = 999, 0
+
+    in := env.Inspect(3, 3)
+    if in.Cell == nil || in.Cell.ID != child.ID || !in.Live {
+        t.Fatalf("unexpected inspected cell %+v", in.Cell)
+    }
+    if in.Disassembly == "" {
+        t.Fatal("expected disassembly")
+    }
+    if in.Lineage.Parent != parent.ID || in.Lineage.Generation != 1 || in.Lineage.Relatives != 2 {
+        t.Fatalf("unexpected lineage %+v", in.Lineage)
+    }
+    if n := in.Neighbors[DirLeft]; n.ID != parent.ID {
+        t.Fatalf("expected parent to the left, got %+v", n)
+    }
+    if len(in.Events) != 1 || in.Events[0].Type != EventBirth {
+        t.Fatalf("expected birth event, got %v", in.Events)
+    }
+    if c := in.Events[0].Cell; c.ID != child.ID || c.Idx != child.Idx {
+        t.Fatalf("recent event cell changed after apply: %d/%d", c.ID, c.Idx)
+    }
+
+    if in := env.Inspect(-1, 0); in.Cell != nil {
+        t.Fatal("expected empty inspection out of bounds")
+    }
+}
@@ -0,0 +1,178 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "sort"
+)
+
+const (
+    InteractionKill = "kill"
+    InteractionShare = "share"
+)
+
+type Interaction struct {
+    Kind string
+    From int64
+    To int64
+}
+
+type InteractionEdge struct {
+    Kind string
+    From int64
+    To int64
+    Count int64
+}
+
+type interactionRecord struct {
+    tick int64
+    edge Interaction
+}
+
+type interactionNetwork struct {
+    window int64
+    records []interactionRecord
+    head int
+    counts map[Interaction]int64
+}
+
+func newInteractionNetwork(window int64) *interactionNetwork {
+    return &interactionNetwork{
+        window: window,
+        counts: make(map[Interaction]int64),
+    }
+}
+
+func (n *interactionNetwork) expire(tick int64) {
+    for n.head < len(n.records) && n.records[n.head].tick <= tick - n.window {
+        r := n.records[n.head]
+        if n.counts[r.edge]--; n.counts[r.edge] <= 0 {
+            delete(n.counts, r.edge)
+        }
+        n.head++
+    }
+    if n.head > len(n.records) / 2 {
+        m := copy(n.records, n.records[n.head:])
+        n.records = n.records[:m]
+        n.head = 0
+    }
+}
+
+func (n *interactionNetwork) add(tick int64, edges []Interaction) {
+    for _, edge := range edges {
+        n.records = append(n.records, interactionRecord{tick, edge})
+        n.counts[edge]++
+    }
+    n.expire(tick)
+}
+
+func (vm *VM) interact(kind string, from, to int64) {
+    if from == 0 || to == 0 {
+        return
+    }
+    vm.interactions = append(vm.interactions, Interaction{kind, from, to})
+}
+
+func (e *Env) updateInteractions(config Config, dt *Delta) {
+    if config.InteractionWindow <= 0 {
+        e.interactions = nil
+        return
+    }
+    if e.interactions == nil || e.interactions.window != config.InteractionWindow {
+        e.interactions = newInteractionNetwork(config.InteractionWindow)
+    }
+    e.interactions.add(dt.Tick, dt.Interactions)
+}
+
+func (e *Env) InteractionGraph() []InteractionEdge {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+
+    if e.interactions == nil {
+        return nil
+    }
+
+    edges := make([]InteractionEdge, 0, len(e.interactions.counts))
+    for edge, n := range e.interactions.counts {
+        edges = append(edges, InteractionEdge{
+            Kind: edge.Kind,
+            From: edge.From,
+            To: edge.To,
+            Count: n,
+        })
+    }
+    sort.Slice(edges, func(i, j int) bool {
+        a, b := edges[i], edges[j]
+        if a.Kind != b.Kind {
+            return a.Kind < b.Kind
+        }
+        if a.From != b.From {
+            return a.From < b.From
+        }
+        return a.To < b.To
+    })
+    return edges
+}
+
+func interactionNodes(edges []InteractionEdge) []int64 {
+    seen := make(map[int64]bool)
+    var nodes []int64
+    for _, e := range edges {
+        for _, n := range []int64{e.From, e.To} {
+            if !seen[n] {
+                seen[n] = true
+                nodes = append(nodes, n)
+            }
+        }
+    }
+    sort.Slice(nodes, func(i, j int) bool {
+        return nodes[i] < nodes[j]
+    })
+    return nodes
+}
+
+func WriteInteractionDOT(w io.Writer, edges []InteractionEdge) error {
+    b := bufio.NewWriter(w)
+
+    fmt.Fprintln(b, "digraph interactions {")
+    for _, n := range interactionNodes(edges) {
+        fmt.Fprintf(b, "    %d;\n", n)
+    }
+    for _, e := range edges {
+        color := "red"
+        if e.Kind == InteractionShare {
+            color = "green"
+        }
+        fmt.Fprintf(b, "    %d -> %d [kind=%s, weight=%d, label=%d, color=%s];\n",
+            e.From, e.To, e.Kind, e.Count, e.Count, color)
+    }
+    fmt.Fprintln(b, "}")
+
+    return b.Flush()
+}
+
+func WriteInteractionGraphML(w io.Writer, edges []InteractionEdge) error {
+    b := bufio.NewWriter(w)
+
+    fmt.Fprintln(b, `<?xml version="1.0" encoding="UTF-8"?>`)
+    fmt.Fprintln(b, `<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`)
+    fmt.Fprintln(b, `  <key id="kind" for="edge" attr.name="kind" attr.type="string"/>`)
+    fmt.Fprintln(b, `  <key id="count" for="edge" attr.name="count" attr.type="long"/>`)
+    fmt.Fprintln(b, `  <graph id="interactions" edgedefault="directed">`)
+    for _, n := range interactionNodes(edges) {
+        fmt.Fprintf(b, "    <node id=\"%d\"/>\n", n)
+    }
+    for _, e := range edges {
+        fmt.Fprintf(b, "    <edge source=\"%d\" target=\"%d\">\n", e.From, e.To)
+        fmt.Fprintf(b, "      <data key=\"kind\">%s</data>\n", e.Kind)
+        fmt.Fprintf(b, "      <data key=\"count\">%d</data>\n", e.Count)
+        fmt.Fprintln(b, "    </edge>")
+    }
+    fmt.Fprintln(b, "  </graph>")
+    fmt.Fprintln(b, "</graphml>")
+
+    return b.Flush()
+}
@@ -0,0 +1,155 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "unsafe"
+
+    "tidepool/tidepool/gene"
+)
+
+type internedGenome struct {
+    hash uint64
+    genome gene.Genome
+    refs int64
+}
+
+type genomeTable struct {
+    genomes map[uint64][]*internedGenome
+    cells []*internedGenome
+    n int
+}
+
+func newGenomeTable(n int) *genomeTable {
+    return &genomeTable{
+        genomes: make(map[uint64][]*internedGenome),
+        cells: make([]*internedGenome, n),
+    }
+}
+
+func equalGenomes(a, b gene.Genome) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    for i, v := range a {
+        if b[i] != v {
+            return false
+        }
+    }
+    return true
+}
+
+func (t *genomeTable) intern(g gene.Genome) *internedGenome {
+    h := g.Hash()
+    for _, ig := range t.genomes[h] {
+        if equalGenomes(ig.genome, g) {
+            ig.refs++
+            return ig
+        }
+    }
+
+    ig := &internedGenome{
+        hash: h,
+        genome: append(gene.Genome(nil), g...),
+        refs: 1,
+    }
+    t.genomes[h] = append(t.genomes[h], ig)
+    t.n++
+
+    return ig
+}
+
+func (t *genomeTable) release(ig *internedGenome) {
+    ig.refs--
+    if ig.refs > 0 {
+        return
+    }
+
+    bucket := t.genomes[ig.hash]
+    for i, o := range bucket {
+        if o == ig {
+            bucket = append(bucket[:i], bucket[i + 1:]...)
+            break
+        }
+    }
+    if len(bucket) == 0 {
+        delete(t.genomes, ig.hash)
+    } else {
+        t.genomes[ig.hash] = bucket
+    }
+    t.n--
+}
+
+func (t *genomeTable) set(c *Cell, g gene.Genome) {
+    old := t.cells[c.Idx]
+    if old != nil && equalGenomes(old.genome, g) {
+        return
+    }
+
+    ig := t.intern(g)
+    if old != nil {
+        t.release(old)
+    }
+    t.cells[c.Idx] = ig
+    c.Genome = ig.genome
+}
+
+func (t *genomeTable) bytes() int64 {
+    n := int64(cap(t.cells)) * pointerSize
+    for _, bucket := range t.genomes {
+        n += mapEntryOverhead + 8 + sliceHeaderSize
+        for _, ig := range bucket {
+            n += pointerSize + int64(unsafe.Sizeof(internedGenome{})) + genomeBytes(ig.genome)
+        }
+    }
+    return n
+}
+
+func (e *Env) syncInterning(config Config) {
+    if config.InternGenomes == (e.interned != nil) {
+        return
+    }
+
+    if !config.InternGenomes {
+        e.interned = nil
+        e.cells = compactGrid(e.cells, e.GenomeSize)
+        return
+    }
+
+    t := newGenomeTable(len(e.cells))
+    for _, c := range e.cells {
+        t.set(c, c.Genome)
+    }
+    e.interned = t
+}
+
+func (e *Env) commitCell(config Config, c *Cell) {
+    e.syncInterning(config)
+
+    dst := e.cells[c.Idx]
+    if e.interned == nil {
+        dst.copyFrom(c)
+        return
+    }
+
+    dst.copyMetadata(c)
+    e.interned.set(dst, c.Genome)
+}
+
+func (e *Env) UniqueGenomes() int {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+    return e.uniqueGenomes()
+}
+
+func (e *Env) uniqueGenomes() int {
+    if e.interned != nil {
+        return e.interned.n
+    }
+
+    hashes := make(map[uint64]struct{})
+    for _, c := range e.cells {
+        hashes[c.Genome.Hash()] = struct{}{}
+    }
+    return len(hashes)
+}
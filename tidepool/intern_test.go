@@ -0,0 +1,89 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "testing"
+
+    "tidepool/tidepool/gene"
+)
+
+func TestGenomeTable(t *testing.T) {
+    tab := newGenomeTable(3)
+    a := gene.Genome{1, 2, 3, 4}
+    b := gene.Genome{4, 3, 2, 1}
+    cells := []*Cell{{Idx: 0}, {Idx: 1}, {Idx: 2}}
+
+    tab.set(cells[0], a)
+    tab.set(cells[1], a)
+    tab.set(cells[2], b)
+    if tab.n != 2 {
+        t.Fatalf("expected 2 interned genomes, got %d", tab.n)
+    }
+    if &cells[0].Genome[0] != &cells[1].Genome[0] {
+        t.Fatal("expected equal genomes to share storage")
+    }
+    if &cells[0].Genome[0] == &a[0] {
+        t.Fatal("expected interned genome to be copied")
+    }
+    if tab.cells[0].refs != 2 {
+        t.Fatalf("expected 2 refs, got %d", tab.cells[0].refs)
+    }
+
+    tab.set(cells[0], b)
+    tab.set(cells[1], b)
+    if tab.n != 1 || len(tab.genomes) != 1 || tab.cells[2].refs != 3 {
+        t.Fatalf("expected released genome to be dropped, got %d genomes", tab.n)
+    }
+}
+
+func TestEnvInternGenomes(t *testing.T) {
+    env := NewEnv(4, 4, 8, 0, 1)
+    config := env.GetConfig()
+    config.InternGenomes = true
+    env.SetConfig(config)
+
+    g := gene.Genome{1, 2, 3, 4, 5, 6, 7, 8}
+    set := func(x int32, g gene.Genome) {
+        c := env.GetCell(x, 0)
+        copy(c.Genome, g)
+        c.Energy = 100
+        env.applyDelta(&Delta{Cells: []*Cell{c}, Stats: make(Stats)})
+    }
+    set(0, g)
+    set(1, g)
+
+    if n := env.UniqueGenomes(); n != 2 {
+        t.Fatalf("expected 2 unique genomes, got %d", n)
+    }
+    if &env.cells[0].Genome[0] != &env.cells[1].Genome[0] {
+        t.Fatal("expected cells to share an interned genome")
+    }
+
+    h := gene.Genome{8, 7, 6, 5, 4, 3, 2, 1}
+    set(1, h)
+    if n := env.UniqueGenomes(); n != 3 {
+        t.Fatalf("expected 3 unique genomes, got %d", n)
+    }
+    if env.GetCell(0, 0).Genome.String() != g.String() {
+        t.Fatal("expected shared genome to be unchanged by a write to another cell")
+    }
+
+    set(0, h)
+    if n := env.UniqueGenomes(); n != 2 {
+        t.Fatalf("expected released genome to be dropped, got %d unique", n)
+    }
+
+    config.InternGenomes = false
+    env.SetConfig(config)
+    set(2, g)
+    if env.interned != nil {
+        t.Fatal("expected interning to be disabled")
+    }
+    if n := env.UniqueGenomes(); n != 3 {
+        t.Fatalf("expected 3 unique genomes, got %d", n)
+    }
+    if &env.cells[0].Genome[0] == &env.cells[1].Genome[0] {
+        t.Fatal("expected genomes to be copied out of the table")
+    }
+}
@@ -0,0 +1,52 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "tidepool/tidepool/gene"
+)
+
+type ISA int
+
+const (
+    ISAv1 ISA = iota + 1
+    ISAv2
+    ISAv3
+    ISAv4
+)
+
+const ISALatest = ISAv4
+
+func (isa ISA) Size() int {
+    switch isa {
+    case ISAv4:
+        return int(gene.DORM) + 1
+    case ISAv3:
+        return int(gene.MOVE) + 1
+    case ISAv2:
+        return int(gene.SENSE) + 1
+    default:
+        return int(gene.N)
+    }
+}
+
+func (isa ISA) Genes() []gene.Gene {
+    gs := make([]gene.Gene, isa.Size())
+    for i := range gs {
+        gs[i] = gene.Gene(i)
+    }
+    return gs
+}
+
+func (isa ISA) String() string {
+    switch isa {
+    case ISAv4:
+        return "v4"
+    case ISAv3:
+        return "v3"
+    case ISAv2:
+        return "v2"
+    default:
+        return "v1"
+    }
+}
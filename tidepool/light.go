@@ -0,0 +1,35 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "math"
+)
+
+func (c Config) daylight(tick int64) float64 {
+    if c.DayLength <= 0 {
+        return 1
+    }
+    phase := float64(tick % c.DayLength) / float64(c.DayLength)
+    return math.Max(0, math.Sin(2 * math.Pi * phase))
+}
+
+func (e *Env) lightAt(config Config, y int32, tick int64) int64 {
+    if config.Light <= 0 || e.Height <= 0 {
+        return 0
+    }
+    depth := 1 - float64(y) / float64(e.Height)
+    return int64(float64(config.Light) * depth * config.daylight(tick))
+}
+
+func (e *Env) LightAt(y int32) int64 {
+    return e.lightAt(e.GetConfig(), y, e.Ticks())
+}
+
+func (e *Env) photosynthesize(config Config, c *Cell, tick int64) int64 {
+    n := e.lightAt(config, c.Y, tick)
+    if n <= 0 {
+        return 0
+    }
+    return e.drawEnergy(config, n)
+}
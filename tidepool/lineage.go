@@ -0,0 +1,137 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "sort"
+
+    "tidepool/tidepool/gene"
+)
+
+type Lineage struct {
+    Origin int64
+    Founded int64
+    Descendants int64
+    Population int64
+    Territory int64
+    Drift float64
+}
+
+type lineage struct {
+    founded int64
+    descendants int64
+    population int64
+    founder gene.Genome
+}
+
+func (e *Env) initLineages(tick int64) {
+    e.lineages = make(map[int64]*lineage)
+    for _, idx := range e.liveCells.all() {
+        e.addLineageCell(tick, e.cells[idx])
+    }
+}
+
+func (e *Env) addLineageCell(tick int64, c *Cell) {
+    l, ok := e.lineages[c.Origin]
+    if !ok {
+        l = &lineage{
+            founded: tick,
+            founder: append(gene.Genome(nil), c.Genome...),
+        }
+        e.lineages[c.Origin] = l
+    }
+    l.population++
+}
+
+func (e *Env) updateLineage(tick int64, old *Cell, c *Cell) {
+    if c.live() && c.Origin != 0 {
+        e.addLineageCell(tick, c)
+    }
+    if old.live() && old.Origin != 0 {
+        if l, ok := e.lineages[old.Origin]; ok {
+            if l.population--; l.population <= 0 {
+                delete(e.lineages, old.Origin)
+            }
+        }
+    }
+}
+
+func (e *Env) updateLineages(config Config, dt *Delta) {
+    if !config.TrackLineages {
+        e.lineages = nil
+        return
+    }
+    for _, ev := range dt.Events {
+        if ev.Type != EventBirth || ev.Cell == nil {
+            continue
+        }
+        if l, ok := e.lineages[ev.Cell.Origin]; ok {
+            l.descendants++
+        }
+    }
+}
+
+func (e *Env) Lineages() []Lineage {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+
+    if e.lineages == nil {
+        return nil
+    }
+
+    type bounds struct {
+        x0, y0, x1, y1 int32
+        drift float64
+    }
+    bs := make(map[int64]*bounds, len(e.lineages))
+
+    for _, idx := range e.liveCells.all() {
+        c := e.cells[idx]
+        l, ok := e.lineages[c.Origin]
+        if !ok {
+            continue
+        }
+        b, ok := bs[c.Origin]
+        if !ok {
+            b = &bounds{c.X, c.Y, c.X, c.Y, 0}
+            bs[c.Origin] = b
+        }
+        if c.X < b.x0 {
+            b.x0 = c.X
+        }
+        if c.X > b.x1 {
+            b.x1 = c.X
+        }
+        if c.Y < b.y0 {
+            b.y0 = c.Y
+        }
+        if c.Y > b.y1 {
+            b.y1 = c.Y
+        }
+        b.drift += 1 - GenomeSimilarity(l.founder, c.Genome)
+    }
+
+    ls := make([]Lineage, 0, len(e.lineages))
+    for o, l := range e.lineages {
+        r := Lineage{
+            Origin: o,
+            Founded: l.founded,
+            Descendants: l.descendants,
+            Population: l.population,
+        }
+        if b, ok := bs[o]; ok {
+            r.Territory = int64(b.x1 - b.x0 + 1) * int64(b.y1 - b.y0 + 1)
+            r.Drift = b.drift / float64(l.population)
+        }
+        ls = append(ls, r)
+    }
+
+    sort.Slice(ls, func(i, j int) bool {
+        if ls[i].Population != ls[j].Population {
+            return ls[i].Population > ls[j].Population
+        }
+        return ls[i].Origin < ls[j].Origin
+    })
+
+    return ls
+}
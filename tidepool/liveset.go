@@ -0,0 +1,72 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "sync/atomic"
+)
+
+type liveSet struct {
+    bits []uint64
+    dense []int32
+    pos []int32
+    n int64
+}
+
+func newLiveSet(size int) *liveSet {
+    s := &liveSet{
+        bits: make([]uint64, (size + 63) / 64),
+        pos: make([]int32, size),
+    }
+    for i := range s.pos {
+        s.pos[i] = -1
+    }
+    return s
+}
+
+func (s *liveSet) has(idx int32) bool {
+    return atomic.LoadUint64(&s.bits[idx / 64]) & (1 << uint(idx % 64)) != 0
+}
+
+func (s *liveSet) add(idx int32) {
+    if s.pos[idx] >= 0 {
+        return
+    }
+    s.pos[idx] = int32(len(s.dense))
+    s.dense = append(s.dense, idx)
+    atomic.OrUint64(&s.bits[idx / 64], 1 << uint(idx % 64))
+    atomic.AddInt64(&s.n, 1)
+}
+
+func (s *liveSet) remove(idx int32) {
+    p := s.pos[idx]
+    if p < 0 {
+        return
+    }
+    last := s.dense[len(s.dense) - 1]
+    s.dense[p] = last
+    s.pos[last] = p
+    s.dense = s.dense[:len(s.dense) - 1]
+    s.pos[idx] = -1
+    atomic.AndUint64(&s.bits[idx / 64], ^(1 << uint(idx % 64)))
+    atomic.AddInt64(&s.n, -1)
+}
+
+func (s *liveSet) len() int {
+    return int(atomic.LoadInt64(&s.n))
+}
+
+func (s *liveSet) all() []int32 {
+    return s.dense
+}
+
+func (e *Env) IsLive(x, y int32) bool {
+    if x < 0 || y < 0 || x >= e.Width || y >= e.Height {
+        return false
+    }
+    return e.liveCells.has(x + e.Width * y)
+}
+
+func (e *Env) LiveCount() int {
+    return e.liveCells.len()
+}
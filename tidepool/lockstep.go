@@ -0,0 +1,236 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "math/rand"
+    "sort"
+    "sync"
+)
+
+type splitMix struct {
+    state uint64
+}
+
+func (s *splitMix) Seed(seed int64) {
+    s.state = uint64(seed)
+}
+
+func (s *splitMix) Uint64() uint64 {
+    s.state += 0x9e3779b97f4a7c15
+    z := s.state
+    z = (z ^ z >> 30) * 0xbf58476d1ce4e5b9
+    z = (z ^ z >> 27) * 0x94d049bb133111eb
+    return z ^ z >> 31
+}
+
+func (s *splitMix) Int63() int64 {
+    return int64(s.Uint64() >> 1)
+}
+
+func lockstepSeed(seed, ticks int64, idx int32) int64 {
+    s := splitMix{state: uint64(seed)}
+    s.state ^= s.Uint64() + uint64(ticks)
+    s.state ^= s.Uint64() + uint64(idx)
+    return int64(s.Uint64())
+}
+
+type lockstep struct {
+    serial *Context
+    workers []*Context
+    cells []*Cell
+    results []*Delta
+}
+
+func newLockstepContext(e *Env, deferIDs bool) *Context {
+    ctx := newContext(e)
+    ctx.rand = rand.New(&splitMix{})
+    ctx.deferIDs = deferIDs
+    return ctx
+}
+
+func (e *Env) assignIDs(dt *Delta) {
+    var ids map[int64]int64
+    id := func(v int64) int64 {
+        if v >= 0 {
+            return v
+        }
+        if ids == nil {
+            ids = make(map[int64]int64)
+        }
+        n, ok := ids[v]
+        if !ok {
+            n = e.getNextCellID()
+            ids[v] = n
+        }
+        return n
+    }
+
+    for _, c := range dt.Cells {
+        c.ID = id(c.ID)
+        c.Parent = id(c.Parent)
+        c.Origin = id(c.Origin)
+    }
+    for i := range dt.Mutations {
+        dt.Mutations[i].CellID = id(dt.Mutations[i].CellID)
+        dt.Mutations[i].Origin = id(dt.Mutations[i].Origin)
+    }
+    for i := range dt.payloads {
+        dt.payloads[i].id = id(dt.payloads[i].id)
+    }
+    edges := dt.Interactions[:0]
+    for _, edge := range dt.Interactions {
+        if edge.From < 0 {
+            if edge.From = ids[edge.From]; edge.From == 0 {
+                continue
+            }
+        }
+        if edge.To < 0 {
+            if edge.To = ids[edge.To]; edge.To == 0 {
+                continue
+            }
+        }
+        edges = append(edges, edge)
+    }
+    dt.Interactions = edges
+    for _, ev := range dt.Events {
+        if ev.Cell != nil {
+            ev.Cell.ID = id(ev.Cell.ID)
+            ev.Cell.Parent = id(ev.Cell.Parent)
+            ev.Cell.Origin = id(ev.Cell.Origin)
+        }
+    }
+}
+
+func (ls *lockstep) run(e *Env, w int, config Config, ticks int64, c *Cell) *Delta {
+    ctx := ls.workers[w]
+    ctx.tick = ticks
+    ctx.refresh(config)
+    ctx.provisional = 0
+    ctx.rand.Seed(lockstepSeed(e.Seed, ticks, c.Idx))
+
+    dt, crash := e.supervise(ctx, ticks, func(ctx *Context, ticks int64) *Delta {
+        return e.execCell(ctx, c, ticks)
+    })
+    if crash != nil {
+        ls.workers[w] = newLockstepContext(e, true)
+        return crash
+    }
+    return dt
+}
+
+func (ls *lockstep) exec(e *Env, workers int, config Config, ticks int64) {
+    n := len(ls.cells)
+
+    if config.EnergyBudget > 0 {
+        workers = 1
+    }
+    if workers > n {
+        workers = n
+    }
+    if workers < 1 {
+        workers = 1
+    }
+    for len(ls.workers) < workers {
+        ls.workers = append(ls.workers, newLockstepContext(e, true))
+    }
+
+    if cap(ls.results) < n {
+        ls.results = make([]*Delta, n)
+    }
+    ls.results = ls.results[:n]
+
+    chunk := (n + workers - 1) / workers
+    var wg sync.WaitGroup
+    for w := 0; w < workers; w++ {
+        lo, hi := w * chunk, (w + 1) * chunk
+        if hi > n {
+            hi = n
+        }
+        if lo >= hi {
+            break
+        }
+        wg.Add(1)
+        go func(w, lo, hi int) {
+            defer wg.Done()
+            for i := lo; i < hi; i++ {
+                ls.results[i] = ls.run(e, w, config, ticks, ls.cells[i])
+            }
+        }(w, lo, hi)
+    }
+    wg.Wait()
+}
+
+func (e *Env) stepLockstep(workers int, dts []*Delta) []*Delta {
+    if e.lockstep == nil {
+        e.lockstep = &lockstep{serial: newLockstepContext(e, false)}
+    }
+    ls := e.lockstep
+
+    apply := func(dt *Delta) {
+        if dt == nil {
+            return
+        }
+        if !e.applyDelta(dt) {
+            dt.Release()
+            return
+        }
+        dts = append(dts, dt)
+    }
+
+    ticks, n := e.nextTick()
+    ls.serial.rand.Seed(lockstepSeed(e.Seed, ticks, -1))
+    for i := 0; i < n; i++ {
+        apply(e.inflowDelta(ls.serial, ticks))
+    }
+    e.burstDeltas(ls.serial, ticks, apply)
+
+    config := e.GetConfig()
+    ls.serial.tick = ticks
+    ls.serial.refresh(config)
+
+    execs := e.execCount(config)
+    if execs < 1 {
+        execs = 1
+    }
+    ls.cells = ls.cells[:0]
+    for len(ls.cells) < execs {
+        c := e.getRandomCell(ls.serial, cellLive)
+        if c == nil {
+            break
+        }
+        ls.cells = append(ls.cells, c)
+    }
+    if len(ls.cells) == 0 {
+        apply(e.inflowDelta(ls.serial, ticks))
+        return dts
+    }
+
+    sort.Slice(ls.cells, func(i, j int) bool {
+        return ls.cells[i].Idx < ls.cells[j].Idx
+    })
+    ls.exec(e, workers, config, ticks)
+
+    for i, dt := range ls.results {
+        ls.results[i] = nil
+        ls.cells[i] = nil
+        if dt != nil {
+            e.assignIDs(dt)
+        }
+        apply(dt)
+    }
+
+    return dts
+}
+
+func (e *Env) AdvanceLockstep(workers int, dts []*Delta) []*Delta {
+    for _, dt := range e.takeExternal() {
+        if !e.applyExternal(dt) {
+            dt.Release()
+            continue
+        }
+        dts = append(dts, dt)
+    }
+
+    return e.stepLockstep(workers, dts)
+}
@@ -0,0 +1,32 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+func (vm *VM) loot(config Config, c *Cell, n *Cell, stats Stats) {
+    if n.Energy <= 0 {
+        return
+    }
+
+    if config.KillLoot > 0 {
+        e := int64(float64(n.Energy) * config.KillLoot)
+        if e > n.Energy {
+            e = n.Energy
+        }
+        n.Energy -= e
+        c.Energy += e
+        stats.inc("KillLoot", e)
+    }
+
+    if config.KillCopyGenes > 0 {
+        size := int32(len(c.Genome))
+        k := int32(config.KillCopyGenes)
+        if k > size {
+            k = size
+        }
+        for i := int32(0); i < k; i++ {
+            j := (vm.pointer + i) % size
+            c.Genome[j] = n.Genome[j]
+        }
+        stats.inc("KillGenesCopied", int64(k))
+    }
+}
@@ -0,0 +1,220 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "sort"
+    "sync/atomic"
+    "unsafe"
+
+    "tidepool/tidepool/gene"
+)
+
+const (
+    mapEntryOverhead = 16
+    sliceHeaderSize = int64(unsafe.Sizeof([]byte(nil)))
+    pointerSize = int64(unsafe.Sizeof(uintptr(0)))
+    geneSize = int64(unsafe.Sizeof(gene.Gene(0)))
+    mutationSize = int64(unsafe.Sizeof(Mutation{}))
+    ancestorSize = int64(unsafe.Sizeof(ancestor{}))
+    profileSize = mapEntryOverhead + 8 + sliceHeaderSize + int64(gene.NMax) * 8
+)
+
+type MemoryReport struct {
+    Grid int64
+    Genomes int64
+    Indexes int64
+    History int64
+    Total int64
+    UniqueGenomes int64
+    HistoryBudget int64
+    HistoryEvicted int64
+    HistoryTruncated int64
+}
+
+func (e *Env) MemoryUsage() MemoryReport {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+
+    r := MemoryReport{
+        Grid: int64(len(e.cells)) * (pointerSize + int64(unsafe.Sizeof(Cell{}))),
+        Genomes: e.genomeBytes(),
+        Indexes: e.indexBytes(),
+        History: e.historyBytes(),
+        UniqueGenomes: int64(e.uniqueGenomes()),
+        HistoryBudget: e.GetConfig().HistoryBudget,
+        HistoryEvicted: atomic.LoadInt64(&e.evicted),
+        HistoryTruncated: e.truncated,
+    }
+    r.Total = r.Grid + r.Genomes + r.Indexes + r.History
+
+    return r
+}
+
+func genomeBytes(g gene.Genome) int64 {
+    return int64(cap(g)) * geneSize
+}
+
+func (e *Env) genomeBytes() int64 {
+    var n int64
+    if e.interned != nil {
+        n = e.interned.bytes()
+    } else {
+        for _, c := range e.cells {
+            n += genomeBytes(c.Genome)
+        }
+    }
+    if e.ranking != nil {
+        for _, g := range e.ranking.genomes {
+            n += mapEntryOverhead + 8 + sliceHeaderSize + genomeBytes(g)
+        }
+    }
+    return n
+}
+
+func (s *liveSet) bytes() int64 {
+    return int64(cap(s.bits)) * 8 + int64(cap(s.dense) + cap(s.pos)) * 4
+}
+
+func (t *weightTree) bytes() int64 {
+    return int64(cap(t.tree) + cap(t.weights)) * 8
+}
+
+func (e *Env) indexBytes() int64 {
+    n := e.liveCells.bytes() + e.barriers.bytes()
+    n += int64(len(e.execCells)) * (mapEntryOverhead + 4)
+    n += int64(cap(e.sweep)) * 4
+
+    if e.weights != nil {
+        n += e.weights.bytes()
+    }
+    if e.chunks != nil {
+        n += e.chunks.tree.bytes()
+    }
+    if t := e.territory; t != nil {
+        n += int64(cap(t.hashes) + cap(t.dominant)) * 8
+        for _, m := range t.counts {
+            n += int64(len(m)) * (mapEntryOverhead + 12)
+        }
+    }
+    if r := e.ranking; r != nil {
+        n += int64(cap(r.hashes) + cap(r.top)) * 8
+        n += int64(len(r.counts)) * (mapEntryOverhead + 16)
+    }
+    if s := e.strains; s != nil {
+        n += int64(cap(s.hashes)) * 8
+        n += int64(len(s.strains)) * (mapEntryOverhead + 8 + int64(unsafe.Sizeof(strain{})))
+    }
+    if s := e.stagnation; s != nil {
+        n += int64(cap(s.pop) + cap(s.div)) * 8
+    }
+    if f := e.nutrients; f != nil {
+        for _, l := range f.levels {
+            n += sliceHeaderSize + int64(cap(l)) * 8
+        }
+        n += int64(cap(f.updated)) * 8
+    }
+
+    return n
+}
+
+func (e *Env) logBytes() int64 {
+    n := e.logged * mutationSize
+    n += int64(len(e.mutations)) * (mapEntryOverhead + 8 + sliceHeaderSize)
+    n += int64(len(e.ancestry)) * (mapEntryOverhead + 8 + ancestorSize)
+    n += int64(len(e.profiles)) * profileSize
+    return n
+}
+
+func (e *Env) historyBytes() int64 {
+    n := e.logBytes()
+    n += int64(len(e.recent)) * (pointerSize + int64(unsafe.Sizeof(Event{})))
+    for _, l := range e.lineages {
+        n += mapEntryOverhead + 8 + pointerSize + int64(unsafe.Sizeof(*l)) + genomeBytes(l.founder)
+    }
+    return n
+}
+
+func (e *Env) logMutation(config Config, m Mutation) {
+    log := append(e.mutations[m.Origin], m)
+    e.logged++
+    if n := config.MutationLogSize; n > 0 && len(log) > n {
+        e.logged -= int64(len(log) - n)
+        log = log[len(log) - n:]
+    }
+    e.mutations[m.Origin] = log
+}
+
+func (e *Env) enforceHistoryBudget(config Config, dt *Delta) {
+    budget := config.HistoryBudget
+    if budget <= 0 || e.logBytes() <= budget {
+        return
+    }
+
+    n := e.evictMutations(e.logBytes() - budget * 3 / 4)
+    if e.logBytes() > budget {
+        before := len(e.profiles)
+        e.pruneProfiles()
+        n += int64(before - len(e.profiles))
+    }
+    if e.logBytes() > budget {
+        before := len(e.ancestry)
+        e.pruneAncestry(e.truncated)
+        n += int64(before - len(e.ancestry))
+    }
+
+    if n > 0 {
+        atomic.AddInt64(&e.evicted, n)
+        dt.Stats["HistoryEvictions"] += n
+    }
+}
+
+func (e *Env) evictMutations(bytes int64) int64 {
+    count := bytes / mutationSize + 1
+    if count >= e.logged {
+        for _, log := range e.mutations {
+            for _, m := range log {
+                if m.Tick > e.truncated {
+                    e.truncated = m.Tick
+                }
+            }
+        }
+        n := e.logged
+        e.mutations = make(map[int64][]Mutation)
+        e.logged = 0
+        return n
+    }
+
+    ticks := make([]int64, 0, e.logged)
+    for _, log := range e.mutations {
+        for _, m := range log {
+            ticks = append(ticks, m.Tick)
+        }
+    }
+    sort.Slice(ticks, func(i, j int) bool {
+        return ticks[i] < ticks[j]
+    })
+    cutoff := ticks[count - 1]
+
+    var n int64
+    for o, log := range e.mutations {
+        i := sort.Search(len(log), func(i int) bool {
+            return log[i].Tick > cutoff
+        })
+        if i == 0 {
+            continue
+        }
+        n += int64(i)
+        if i == len(log) {
+            delete(e.mutations, o)
+            continue
+        }
+        e.mutations[o] = append([]Mutation(nil), log[i:]...)
+    }
+    e.logged -= n
+    if cutoff > e.truncated {
+        e.truncated = cutoff
+    }
+
+    return n
+}
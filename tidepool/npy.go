@@ -0,0 +1,115 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "archive/zip"
+    "bytes"
+    "encoding/binary"
+    "fmt"
+    "io"
+    "strings"
+)
+
+const (
+    ArrayGenomes = "genomes"
+    ArrayLive = "live"
+    ArrayEnergy = "energy"
+)
+
+var npyMagic = []byte("\x93NUMPY\x01\x00")
+
+type npyArray struct {
+    descr string
+    shape []int
+    data []byte
+}
+
+func writeNpy(w io.Writer, a npyArray) error {
+    dims := make([]string, len(a.shape))
+    for i, n := range a.shape {
+        dims[i] = fmt.Sprint(n)
+    }
+    shape := strings.Join(dims, ", ")
+    if len(dims) == 1 {
+        shape += ","
+    }
+
+    header := fmt.Sprintf("{'descr': '%s', 'fortran_order': False, 'shape': (%s), }",
+        a.descr, shape)
+    pad := 64 - (len(npyMagic) + 2 + len(header) + 1) % 64
+    if pad == 64 {
+        pad = 0
+    }
+    header += strings.Repeat(" ", pad) + "\n"
+
+    var b bytes.Buffer
+    b.Write(npyMagic)
+    binary.Write(&b, binary.LittleEndian, uint16(len(header)))
+    b.WriteString(header)
+    if _, err := w.Write(b.Bytes()); err != nil {
+        return err
+    }
+    _, err := w.Write(a.data)
+    return err
+}
+
+func (e *Env) gridArrays() map[string]npyArray {
+    w, h, gs := int(e.Width), int(e.Height), int(e.GenomeSize)
+    genomes := make([]byte, w * h * gs)
+    live := make([]byte, w * h)
+    energy := make([]byte, w * h * 8)
+
+    e.WithCells(func(cs []*Cell) {
+        for i, c := range cs {
+            g := genomes[i * gs:(i + 1) * gs]
+            for j, v := range c.Genome {
+                if j >= gs {
+                    break
+                }
+                g[j] = byte(v)
+            }
+            if c.live() {
+                live[i] = 1
+            }
+            binary.LittleEndian.PutUint64(energy[i * 8:], uint64(c.Energy))
+        }
+    })
+
+    return map[string]npyArray{
+        ArrayGenomes: {"|u1", []int{h, w, gs}, genomes},
+        ArrayLive: {"|b1", []int{h, w}, live},
+        ArrayEnergy: {"<i8", []int{h, w}, energy},
+    }
+}
+
+func (e *Env) ExportNpy(w io.Writer, name string) error {
+    a, ok := e.gridArrays()[name]
+    if !ok {
+        return fmt.Errorf("unknown array %q", name)
+    }
+    return writeNpy(w, a)
+}
+
+func (e *Env) ExportNpz(w io.Writer, compress bool) error {
+    method := zip.Store
+    if compress {
+        method = zip.Deflate
+    }
+
+    arrays := e.gridArrays()
+    z := zip.NewWriter(w)
+    for _, name := range []string{ArrayGenomes, ArrayLive, ArrayEnergy} {
+        f, err := z.CreateHeader(&zip.FileHeader{
+            Name: name + ".npy",
+            Method: method,
+        })
+        if err != nil {
+            return err
+        }
+        if err := writeNpy(f, arrays[name]); err != nil {
+            return err
+        }
+    }
+    return z.Close()
+}
@@ -0,0 +1,107 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "math/bits"
+    "sync"
+
+    "tidepool/tidepool/gene"
+)
+
+type nutrientField struct {
+    mutex sync.Mutex
+    levels [][]int64
+    updated []int64
+}
+
+func newNutrientField(n, size int) *nutrientField {
+    f := &nutrientField{
+        levels: make([][]int64, n),
+        updated: make([]int64, size),
+    }
+    for i := range f.levels {
+        f.levels[i] = make([]int64, size)
+    }
+    return f
+}
+
+func (f *nutrientField) replenish(config Config, idx int32, tick int64) {
+    elapsed := tick - f.updated[idx]
+    if elapsed <= 0 {
+        return
+    }
+    f.updated[idx] = tick
+
+    add := elapsed * config.NutrientReplenish
+    for _, levels := range f.levels {
+        v := levels[idx] + add
+        if v > config.NutrientCapacity || v < 0 {
+            v = config.NutrientCapacity
+        }
+        levels[idx] = v
+    }
+}
+
+func nutrientKey(k, n int) gene.Gene {
+    return gene.Gene(k * int(gene.N) / n)
+}
+
+func (c *Cell) metabolism(k, n int) float64 {
+    d := bits.OnesCount(uint(c.logo() ^ nutrientKey(k, n)))
+    w := bits.Len(uint(gene.N - 1))
+    if d >= w {
+        return 0
+    }
+    return 1 - float64(d) / float64(w)
+}
+
+func (e *Env) getNutrients(config Config) *nutrientField {
+    e.nutrientMutex.Lock()
+    defer e.nutrientMutex.Unlock()
+
+    if e.nutrients == nil || len(e.nutrients.levels) != config.Nutrients {
+        e.nutrients = newNutrientField(config.Nutrients, int(e.Width * e.Height))
+    }
+    return e.nutrients
+}
+
+func (e *Env) absorbNutrients(config Config, c *Cell, tick int64) int64 {
+    if config.Nutrients <= 0 {
+        return 0
+    }
+
+    f := e.getNutrients(config)
+    f.mutex.Lock()
+    defer f.mutex.Unlock()
+
+    f.replenish(config, c.Idx, tick)
+
+    var gain int64
+    for k, levels := range f.levels {
+        n := int64(float64(config.NutrientUptake) * c.metabolism(k, config.Nutrients))
+        if n > levels[c.Idx] {
+            n = levels[c.Idx]
+        }
+        levels[c.Idx] -= n
+        gain += n
+    }
+
+    return gain
+}
+
+func (e *Env) NutrientLevel(k int, x, y int32) int64 {
+    config := e.GetConfig()
+    if k < 0 || k >= config.Nutrients {
+        return 0
+    }
+
+    f := e.getNutrients(config)
+    f.mutex.Lock()
+    defer f.mutex.Unlock()
+
+    idx := x + e.Width * y
+    f.replenish(config, idx, e.Ticks())
+
+    return f.levels[k][idx]
+}
@@ -0,0 +1,124 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "errors"
+    "fmt"
+    "math"
+    "strconv"
+    "sync/atomic"
+    "time"
+)
+
+var ErrUnknownParam = errors.New("unknown parameter")
+
+const (
+    ParamExecsPerTick = "ExecsPerTick"
+    ParamInflowFrequency = "InflowFrequency"
+    ParamMutationRate = "MutationRate"
+    ParamPacing = "Pacing"
+    ParamSpeed = "Speed"
+    ParamTick = "Tick"
+)
+
+const minTick = time.Microsecond
+
+func (e *Env) SetParam(name, value string) error {
+    var fn func()
+
+    switch name {
+    case ParamExecsPerTick:
+        v, err := strconv.Atoi(value)
+        if err != nil || v < 1 {
+            return fmt.Errorf("invalid %s: %q", name, value)
+        }
+        fn = func() {
+            config := e.GetConfig()
+            config.ExecsPerTick = v
+            e.SetConfig(config)
+        }
+    case ParamInflowFrequency:
+        v, err := strconv.ParseInt(value, 10, 64)
+        if err != nil || v < 1 {
+            return fmt.Errorf("invalid %s: %q", name, value)
+        }
+        fn = func() {
+            config := e.GetConfig()
+            config.InflowFrequency = v
+            e.SetConfig(config)
+        }
+    case ParamMutationRate:
+        v, err := strconv.ParseFloat(value, 64)
+        if err != nil || v < 0 || v > 1 {
+            return fmt.Errorf("invalid %s: %q", name, value)
+        }
+        if _, ok := e.GetRNG().(DefaultRNG); !ok {
+            return fmt.Errorf("%s requires the default RNG", name)
+        }
+        fn = func() {
+            if r, ok := e.GetRNG().(DefaultRNG); ok {
+                r.MutationRate = v
+                e.SetRNG(r)
+            }
+        }
+    case ParamPacing:
+        p, ok := ParsePacing(value)
+        if !ok {
+            return fmt.Errorf("invalid %s: %q", name, value)
+        }
+        e.SetPacing(p)
+        return nil
+    case ParamSpeed:
+        v, err := strconv.ParseFloat(value, 64)
+        if err != nil || math.IsNaN(v) || v < 0 {
+            return fmt.Errorf("invalid %s: %q", name, value)
+        }
+        return e.SetSpeed(v)
+    case ParamTick:
+        v, err := time.ParseDuration(value)
+        if err != nil || v < minTick {
+            return fmt.Errorf("invalid %s: %q", name, value)
+        }
+        atomic.StoreInt64(&e.tick, int64(v))
+        e.pacingChanged()
+        return nil
+    default:
+        return ErrUnknownParam
+    }
+
+    e.paramMutex.Lock()
+    e.pending = append(e.pending, fn)
+    e.paramMutex.Unlock()
+
+    return nil
+}
+
+func (e *Env) Params() map[string]string {
+    ps := map[string]string{
+        ParamExecsPerTick: strconv.Itoa(e.execCount(e.GetConfig())),
+        ParamInflowFrequency: strconv.FormatInt(e.GetConfig().InflowFrequency, 10),
+        ParamTick: e.TickDuration().String(),
+        ParamPacing: e.GetPacing().String(),
+        ParamSpeed: strconv.FormatFloat(e.Speed(), 'g', -1, 64),
+    }
+    if r, ok := e.GetRNG().(DefaultRNG); ok {
+        ps[ParamMutationRate] = strconv.FormatFloat(r.MutationRate, 'g', -1, 64)
+    }
+    return ps
+}
+
+func (e *Env) TickDuration() time.Duration {
+    return time.Duration(atomic.LoadInt64(&e.tick))
+}
+
+func (e *Env) applyParams() {
+    e.paramMutex.Lock()
+    fns := e.pending
+    e.pending = nil
+    e.paramMutex.Unlock()
+
+    for _, fn := range fns {
+        fn()
+    }
+}
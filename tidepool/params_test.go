@@ -0,0 +1,62 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "testing"
+)
+
+func TestSetParamPacingWhilePaused(t *testing.T) {
+    env := NewEnv(8, 8, 16, 0, 1)
+    env.Pause()
+
+    if err := env.SetParam(ParamSpeed, "2"); err != nil {
+        t.Fatal(err)
+    }
+    if env.Paused() {
+        t.Fatal("expected Speed to unpause the env")
+    }
+    if s := env.Params()[ParamSpeed]; s != "2" {
+        t.Fatalf("expected Speed 2, got %s", s)
+    }
+
+    if err := env.SetParam(ParamSpeed, "0"); err != nil {
+        t.Fatal(err)
+    }
+    if err := env.SetParam(ParamPacing, PacingTurbo.String()); err != nil {
+        t.Fatal(err)
+    }
+    if err := env.SetParam(ParamTick, "5ms"); err != nil {
+        t.Fatal(err)
+    }
+    ps := env.Params()
+    if ps[ParamPacing] != PacingTurbo.String() || ps[ParamTick] != "5ms" || !env.Paused() {
+        t.Fatalf("expected paced params to apply while paused, got %v", ps)
+    }
+
+    if err := env.SetParam(ParamSpeed, "-1"); err == nil {
+        t.Fatal("expected negative speed to be rejected")
+    }
+}
+
+func TestSetParamDeferred(t *testing.T) {
+    env := NewEnv(8, 8, 16, 0, 1)
+
+    if err := env.SetParam(ParamInflowFrequency, "7"); err != nil {
+        t.Fatal(err)
+    }
+    if env.GetConfig().InflowFrequency == 7 {
+        t.Fatal("expected InflowFrequency to wait for the next tick")
+    }
+    env.applyParams()
+    if env.GetConfig().InflowFrequency != 7 {
+        t.Fatal("expected InflowFrequency to apply")
+    }
+
+    if err := env.SetParam(ParamExecsPerTick, "0"); err == nil {
+        t.Fatal("expected ExecsPerTick 0 to be rejected")
+    }
+    if err := env.SetParam("Width", "1"); err != ErrUnknownParam {
+        t.Fatalf("expected ErrUnknownParam, got %v", err)
+    }
+}
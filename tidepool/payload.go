@@ -0,0 +1,283 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "bytes"
+    "encoding/gob"
+    "fmt"
+    "sync"
+
+    "tidepool/tidepool/gene"
+)
+
+type payloadTable interface {
+    load(idx int32) interface{}
+    zero() interface{}
+    commit(idx int32, p interface{})
+    reset(n int)
+}
+
+type payloadWrite struct {
+    idx int32
+    id int64
+    value interface{}
+    touch bool
+}
+
+type instruction func(ctx *Context, c *Cell, register gene.Gene) gene.Gene
+
+type instructionSet [gene.NMax]instruction
+
+type payloadsOf[P any] struct {
+    mutex *sync.RWMutex
+    values []P
+}
+
+type EnvOf[P any] struct {
+    *Env
+
+    table *payloadsOf[P]
+}
+
+type BehaviorOf[P any] interface {
+    Allow(ctx *Context, a Action, c *Cell, n *Cell, cp *P, np *P) bool
+    Apply(ctx *Context, a Action, c *Cell, n *Cell, cp *P, np *P)
+}
+
+type InstructionOf[P any] func(ctx *Context, c *Cell, p *P, register gene.Gene) gene.Gene
+
+type payloadBehavior[P any] struct {
+    b BehaviorOf[P]
+}
+
+type envOfData[P any] struct {
+    Env []byte
+    Payloads []P
+}
+
+func (t *payloadsOf[P]) load(idx int32) interface{} {
+    t.mutex.RLock()
+    defer t.mutex.RUnlock()
+    p := new(P)
+    *p = t.values[idx]
+    return p
+}
+
+func (t *payloadsOf[P]) zero() interface{} {
+    return new(P)
+}
+
+func (t *payloadsOf[P]) commit(idx int32, p interface{}) {
+    t.mutex.Lock()
+    defer t.mutex.Unlock()
+    if p == nil {
+        var zero P
+        t.values[idx] = zero
+        return
+    }
+    t.values[idx] = *p.(*P)
+}
+
+func (t *payloadsOf[P]) reset(n int) {
+    t.mutex.Lock()
+    defer t.mutex.Unlock()
+    t.values = make([]P, n)
+}
+
+func NewEnvOf[P any](width, height, genomeSize, pop int32, seed int64) *EnvOf[P] {
+    e := &EnvOf[P]{
+        Env: NewEnv(width, height, genomeSize, pop, seed),
+        table: &payloadsOf[P]{
+            mutex: &sync.RWMutex{},
+            values: make([]P, width * height),
+        },
+    }
+    e.Env.payloads = e.table
+    return e
+}
+
+func (pb payloadBehavior[P]) Allow(ctx *Context, a Action, c *Cell, n *Cell) bool {
+    cp := ctx.vm.payload(c.Idx).(*P)
+    np := ctx.vm.payload(n.Idx).(*P)
+    return pb.b.Allow(ctx, a, c, n, cp, np)
+}
+
+func (pb payloadBehavior[P]) Apply(ctx *Context, a Action, c *Cell, n *Cell) {
+    cp := ctx.vm.payload(c.Idx).(*P)
+    np := ctx.vm.payload(n.Idx).(*P)
+    pb.b.Apply(ctx, a, c, n, cp, np)
+}
+
+func (e *EnvOf[P]) AddBehaviorOf(b BehaviorOf[P]) {
+    e.AddBehavior(payloadBehavior[P]{b: b})
+}
+
+func (e *EnvOf[P]) AddInstruction(g gene.Gene, f InstructionOf[P]) error {
+    if g < 0 || g >= gene.NMax {
+        return fmt.Errorf("invalid gene %d", g)
+    }
+    e.addInstruction(g, func(ctx *Context, c *Cell, register gene.Gene) gene.Gene {
+        return f(ctx, c, ctx.vm.payload(c.Idx).(*P), register)
+    })
+    return nil
+}
+
+func (e *EnvOf[P]) Payload(x, y int32) P {
+    e.table.mutex.RLock()
+    defer e.table.mutex.RUnlock()
+    return e.table.values[x + e.Width * y]
+}
+
+func (e *EnvOf[P]) SetPayload(x, y int32, p P) error {
+    if x < 0 || y < 0 || x >= e.Width || y >= e.Height {
+        return fmt.Errorf("cell %d,%d out of bounds", x, y)
+    }
+
+    idx := x + e.Width * y
+    e.mutex.RLock()
+    c := e.cells[idx]
+    id, live := c.ID, c.live()
+    e.mutex.RUnlock()
+    if !live {
+        return fmt.Errorf("no live cell at %d,%d", x, y)
+    }
+
+    dt := e.acquireDelta()
+    dt.payloads = append(dt.payloads, payloadWrite{
+        idx: idx,
+        id: id,
+        value: &p,
+        touch: true,
+    })
+    dt.force = true
+    e.submit(dt)
+
+    return nil
+}
+
+func (e *EnvOf[P]) WithPayloads(f func([]P)) {
+    e.table.mutex.RLock()
+    defer e.table.mutex.RUnlock()
+    f(e.table.values)
+}
+
+func (e *EnvOf[P]) MarshalBinary() ([]byte, error) {
+    e.mutex.RLock()
+    b, err := e.marshalBinary()
+    if err != nil {
+        e.mutex.RUnlock()
+        return nil, err
+    }
+    e.table.mutex.RLock()
+    data := envOfData[P]{
+        Env: b,
+        Payloads: e.table.values,
+    }
+
+    var buf bytes.Buffer
+    err = gob.NewEncoder(&buf).Encode(data)
+    e.table.mutex.RUnlock()
+    e.mutex.RUnlock()
+
+    if err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+func (e *EnvOf[P]) UnmarshalBinary(b []byte) error {
+    var data envOfData[P]
+    if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&data); err != nil {
+        return err
+    }
+    if err := e.Env.UnmarshalBinary(data.Env); err != nil {
+        return err
+    }
+
+    e.table.mutex.Lock()
+    defer e.table.mutex.Unlock()
+    if len(data.Payloads) != len(e.table.values) {
+        return fmt.Errorf("payloads for %d cells, want %d", len(data.Payloads), len(e.table.values))
+    }
+    copy(e.table.values, data.Payloads)
+
+    return nil
+}
+
+func (e *Env) addInstruction(g gene.Gene, f instruction) {
+    e.behaviorMutex.Lock()
+    defer e.behaviorMutex.Unlock()
+
+    var is instructionSet
+    if old := e.getInstructions(); old != nil {
+        is = *old
+    }
+    is[g] = f
+    e.instructions.Store(&is)
+}
+
+func (e *Env) getInstructions() *instructionSet {
+    is, _ := e.instructions.Load().(*instructionSet)
+    return is
+}
+
+func (e *Env) commitPayload(old *Cell, c *Cell) {
+    if !c.live() || old.ID != c.ID {
+        e.payloads.commit(c.Idx, nil)
+    }
+}
+
+func (e *Env) commitPayloads(dt *Delta) {
+    for _, w := range dt.payloads {
+        c := e.cells[w.idx]
+        if c.ID != w.id || !c.live() {
+            continue
+        }
+        e.payloads.commit(w.idx, w.value)
+        if w.touch {
+            c.Version++
+        }
+    }
+}
+
+func (vm *VM) payload(idx int32) interface{} {
+    if p, ok := vm.payloads[idx]; ok {
+        return p
+    }
+    p := vm.ctx.env.payloads.load(idx)
+    vm.stagePayload(idx, p)
+    return p
+}
+
+func (vm *VM) stagePayload(idx int32, p interface{}) {
+    if _, ok := vm.payloads[idx]; !ok {
+        vm.staged = append(vm.staged, idx)
+    }
+    vm.payloads[idx] = p
+}
+
+func (vm *VM) resetPayload(idx int32) {
+    if t := vm.ctx.env.payloads; t != nil {
+        vm.stagePayload(idx, t.zero())
+    }
+}
+
+func (vm *VM) movePayload(from, to int32) {
+    if t := vm.ctx.env.payloads; t != nil {
+        vm.stagePayload(to, vm.payload(from))
+        vm.stagePayload(from, t.zero())
+    }
+}
+
+func (vm *VM) flushPayloads(dt *Delta) {
+    for _, idx := range vm.staged {
+        if c, ok := vm.cellMap[idx]; ok {
+            dt.payloads = append(dt.payloads, payloadWrite{
+                idx: idx,
+                id: c.ID,
+                value: vm.payloads[idx],
+            })
+        }
+    }
+}
@@ -0,0 +1,212 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "testing"
+
+    "tidepool/tidepool/gene"
+)
+
+type inheritBehavior struct{}
+
+func (inheritBehavior) Allow(ctx *Context, a Action, c *Cell, n *Cell, cp *int, np *int) bool {
+    return true
+}
+
+func (inheritBehavior) Apply(ctx *Context, a Action, c *Cell, n *Cell, cp *int, np *int) {
+    if a == ActionReplicate {
+        *np += *cp + 1
+    }
+}
+
+func newPayloadEnv(t *testing.T) *EnvOf[int] {
+    env := NewEnvOf[int](8, 8, 8, 0, 1)
+    env.SetRNG(DefaultRNG{})
+    config := env.GetConfig()
+    config.ISA = ISALatest
+    env.SetConfig(config)
+    err := env.AddInstruction(gene.RAND, func(ctx *Context, c *Cell, p *int, r gene.Gene) gene.Gene {
+        *p += int(r)
+        return r + 1
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    return env
+}
+
+func execPayloadCell(env *EnvOf[int], x, y int32) *Delta {
+    ctx := newContext(env.Env)
+    return env.GetCell(x, y).exec(ctx)
+}
+
+func TestPayloadInstruction(t *testing.T) {
+    env := newPayloadEnv(t)
+    g := gene.Genome{gene.STOP, gene.INC, gene.RAND, gene.RAND, gene.STOP}
+    if _, err := env.InjectCell(2, 2, g, 100); err != nil {
+        t.Fatal(err)
+    }
+
+    dt := execPayloadCell(env, 2, 2)
+    if p := env.Payload(2, 2); p != 0 {
+        t.Fatalf("payload committed before apply: %d", p)
+    }
+    if !env.applyDelta(dt) {
+        t.Fatal("delta rejected")
+    }
+    if p := env.Payload(2, 2); p != 3 {
+        t.Fatalf("expected payload 3, got %d", p)
+    }
+
+    if err := env.AddInstruction(gene.NMax, nil); err == nil {
+        t.Fatal("expected error for invalid gene")
+    }
+}
+
+func TestPayloadStaleDelta(t *testing.T) {
+    env := newPayloadEnv(t)
+    g := gene.Genome{gene.STOP, gene.INC, gene.RAND, gene.RAND, gene.STOP}
+    if _, err := env.InjectCell(2, 2, g, 100); err != nil {
+        t.Fatal(err)
+    }
+
+    dt := execPayloadCell(env, 2, 2)
+    if err := env.SetPayload(2, 2, 10); err != nil {
+        t.Fatal(err)
+    }
+    if env.applyDelta(dt) {
+        t.Fatal("expected stale delta to be rejected")
+    }
+    if p := env.Payload(2, 2); p != 10 {
+        t.Fatalf("expected payload 10, got %d", p)
+    }
+}
+
+func TestPayloadMove(t *testing.T) {
+    env := newPayloadEnv(t)
+    g := gene.Genome{gene.STOP, gene.MOVE, gene.STOP}
+    c, err := env.InjectCell(2, 2, g, 100)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := env.SetPayload(2, 2, 7); err != nil {
+        t.Fatal(err)
+    }
+
+    to := env.getNeighborIdx(c, 0)
+    if !env.applyDelta(execPayloadCell(env, 2, 2)) {
+        t.Fatal("delta rejected")
+    }
+    x, y := to % env.Width, to / env.Width
+    if n := env.GetCell(x, y); n.ID != c.ID {
+        t.Fatalf("expected cell %d to move to %d,%d", c.ID, x, y)
+    }
+    if p := env.Payload(x, y); p != 7 {
+        t.Fatalf("expected moved payload 7, got %d", p)
+    }
+    if p := env.Payload(2, 2); p != 0 {
+        t.Fatalf("expected vacated payload 0, got %d", p)
+    }
+}
+
+func TestPayloadReset(t *testing.T) {
+    env := newPayloadEnv(t)
+    g := gene.Genome{gene.STOP, gene.STOP}
+    if _, err := env.InjectCell(2, 2, g, 100); err != nil {
+        t.Fatal(err)
+    }
+    if err := env.SetPayload(2, 2, 5); err != nil {
+        t.Fatal(err)
+    }
+    if err := env.KillCell(2, 2); err != nil {
+        t.Fatal(err)
+    }
+    if p := env.Payload(2, 2); p != 0 {
+        t.Fatalf("expected payload reset on death, got %d", p)
+    }
+
+    if _, err := env.InjectCell(2, 2, g, 1); err != nil {
+        t.Fatal(err)
+    }
+    if err := env.SetPayload(2, 2, 5); err != nil {
+        t.Fatal(err)
+    }
+    if !env.applyDelta(execPayloadCell(env, 2, 2)) {
+        t.Fatal("delta rejected")
+    }
+    if c := env.GetCell(2, 2); c.live() {
+        t.Fatal("expected cell to starve")
+    }
+    if p := env.Payload(2, 2); p != 0 {
+        t.Fatalf("expected payload reset on starvation, got %d", p)
+    }
+
+    if _, err := env.InjectCell(2, 2, g, 100); err != nil {
+        t.Fatal(err)
+    }
+    if p := env.Payload(2, 2); p != 0 {
+        t.Fatalf("expected new occupant to start empty, got %d", p)
+    }
+
+    if err := env.SetPayload(5, 5, 1); err == nil {
+        t.Fatal("expected error for empty cell")
+    }
+}
+
+func TestPayloadReplicate(t *testing.T) {
+    env := newPayloadEnv(t)
+    env.AddBehaviorOf(inheritBehavior{})
+    g := gene.Genome{gene.STOP, gene.INC, gene.WRITEB, gene.STOP}
+    parent, err := env.InjectCell(2, 2, g, 100)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := env.SetPayload(2, 2, 4); err != nil {
+        t.Fatal(err)
+    }
+
+    to := env.getNeighborIdx(parent, 0)
+    x, y := to % env.Width, to / env.Width
+    if _, err := env.InjectCell(x, y, gene.Genome{gene.STOP}, 100); err != nil {
+        t.Fatal(err)
+    }
+    if err := env.SetPayload(x, y, 9); err != nil {
+        t.Fatal(err)
+    }
+
+    if !env.applyDelta(execPayloadCell(env, 2, 2)) {
+        t.Fatal("delta rejected")
+    }
+    if c := env.GetCell(x, y); c.Parent != parent.ID {
+        t.Fatalf("expected child of %d at %d,%d", parent.ID, x, y)
+    }
+    if p := env.Payload(x, y); p != 5 {
+        t.Fatalf("expected child payload 5, got %d", p)
+    }
+    if p := env.Payload(2, 2); p != 4 {
+        t.Fatalf("expected parent payload 4, got %d", p)
+    }
+}
+
+func TestPayloadMarshalBinary(t *testing.T) {
+    env := newPayloadEnv(t)
+    if _, err := env.InjectCell(2, 2, gene.Genome{gene.STOP}, 100); err != nil {
+        t.Fatal(err)
+    }
+    if err := env.SetPayload(2, 2, 9); err != nil {
+        t.Fatal(err)
+    }
+
+    b, err := env.MarshalBinary()
+    if err != nil {
+        t.Fatal(err)
+    }
+    restored := NewEnvOf[int](1, 1, 1, 0, 0)
+    if err := restored.UnmarshalBinary(b); err != nil {
+        t.Fatal(err)
+    }
+    if p := restored.Payload(2, 2); p != 9 {
+        t.Fatalf("expected restored payload 9, got %d", p)
+    }
+}
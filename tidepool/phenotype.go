@@ -0,0 +1,163 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "tidepool/tidepool/gene"
+)
+
+const (
+    PhenotypeInert = "inert"
+    PhenotypeReplicator = "replicator"
+    PhenotypeKiller = "killer"
+    PhenotypeSharer = "sharer"
+)
+
+const (
+    sandboxSize = 9
+    sandboxExecs = 64
+    sandboxEnergy = 1000
+    sandboxSubstrate = 500
+    sandboxSeed = 1
+    maxPhenotypes = 4096
+)
+
+type Phenotype struct {
+    Class string
+    Hash uint64
+    Execs int64
+    Replications int64
+    Faithful int64
+    Fidelity float64
+    Kills int64
+    Shares int64
+    Moves int64
+    Descendants int64
+    Instructions map[string]int64
+}
+
+func (p *Phenotype) classify() {
+    if p.Replications > 0 {
+        p.Fidelity = float64(p.Faithful) / float64(p.Replications)
+    }
+
+    switch {
+    case p.Kills > 0 && p.Kills >= p.Shares:
+        p.Class = PhenotypeKiller
+    case p.Shares > 0:
+        p.Class = PhenotypeSharer
+    case p.Replications > 0:
+        p.Class = PhenotypeReplicator
+    default:
+        p.Class = PhenotypeInert
+    }
+}
+
+func (e *Env) newSandbox() *Env {
+    s := NewEnv(sandboxSize, sandboxSize, e.GenomeSize, 0, sandboxSeed)
+
+    config := e.GetConfig()
+    config.InflowFrequency = 1 << 62
+    config.ProfileGenes = true
+    config.RecordMutations = false
+    config.TrackLineages = false
+    config.AuditEnergy = false
+    config.EnergyBudget = 0
+    s.SetConfig(config)
+    s.SetRNG(e.GetRNG())
+
+    return s
+}
+
+func (e *Env) AnalyzePhenotype(g gene.Genome) Phenotype {
+    p := Phenotype{
+        Hash: g.Hash(),
+        Instructions: make(map[string]int64),
+    }
+
+    s := e.newSandbox()
+    for y := int32(0); y < s.Height; y++ {
+        for x := int32(0); x < s.Width; x++ {
+            s.InjectCell(x, y, nil, sandboxSubstrate)
+        }
+    }
+    mid := int32(sandboxSize / 2)
+    focal, err := s.InjectCell(mid, mid, g, sandboxEnergy)
+    if err != nil {
+        p.classify()
+        return p
+    }
+
+    ctx := newContext(s)
+    ctx.refresh(s.GetConfig())
+    hash := focal.Genome.Hash()
+
+    var lineage []int32
+    for tick := int64(1); tick <= sandboxExecs; tick++ {
+        lineage = lineage[:0]
+        for _, idx := range s.liveCells.all() {
+            if s.cells[idx].Origin == focal.Origin {
+                lineage = append(lineage, idx)
+            }
+        }
+        if len(lineage) == 0 {
+            break
+        }
+
+        c := s.acquireCellByIdx(lineage[ctx.rand.Intn(len(lineage))])
+        id := c.ID
+        ctx.tick = tick
+        dt := s.execCell(ctx, c, tick)
+
+        p.Execs++
+        p.Replications += dt.Stats["Reproductions"]
+        p.Kills += dt.Stats["CellsKilled"]
+        p.Shares += dt.Stats["CellsShared"]
+        p.Moves += dt.Stats["Moves"]
+        for i, n := range dt.Profile {
+            if n > 0 {
+                p.Instructions[gene.Gene(i).Name()] += n
+            }
+        }
+        for _, n := range dt.Cells {
+            if n.Parent == id && n.ID != id && n.Born == tick &&
+                n.Genome.Hash() == hash {
+                p.Faithful++
+            }
+        }
+
+        s.applyDelta(dt)
+        dt.Release()
+    }
+
+    for _, idx := range s.liveCells.all() {
+        if s.cells[idx].Origin == focal.Origin {
+            p.Descendants++
+        }
+    }
+
+    p.classify()
+    return p
+}
+
+func (e *Env) Phenotype(g gene.Genome) Phenotype {
+    h := g.Hash()
+
+    e.phenotypeMutex.Lock()
+    p, ok := e.phenotypes[h]
+    e.phenotypeMutex.Unlock()
+    if ok {
+        return p
+    }
+
+    p = e.AnalyzePhenotype(g)
+
+    e.phenotypeMutex.Lock()
+    if len(e.phenotypes) >= maxPhenotypes {
+        e.phenotypes = make(map[uint64]Phenotype)
+    }
+    e.phenotypes[h] = p
+    e.phenotypeMutex.Unlock()
+
+    return p
+}
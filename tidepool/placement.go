@@ -0,0 +1,61 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+type Placement int
+
+const (
+    PlacementDirected Placement = iota
+    PlacementRandom
+    PlacementWeakest
+    PlacementDead
+)
+
+func (vm *VM) neighbor(c *Cell, dir int) *Cell {
+    return vm.getCell(vm.ctx.env.getNeighborIdx(c, dir))
+}
+
+func (vm *VM) placement(c *Cell, config Config) *Cell {
+    ctx := vm.ctx
+
+    switch config.Placement {
+    case PlacementRandom:
+        if n := vm.neighbor(c, ctx.rand.Intn(4)); n.Energy > 0 {
+            return n
+        }
+    case PlacementWeakest:
+        var w *Cell
+        for dir := DirLeft; dir <= DirDown; dir++ {
+            n := vm.neighbor(c, dir)
+            if n.Energy > 0 && (w == nil || n.Energy < w.Energy) {
+                w = n
+            }
+        }
+        return w
+    case PlacementDead:
+        if c.Energy < 2 {
+            return nil
+        }
+        var dead [4]*Cell
+        i := 0
+        for dir := DirLeft; dir <= DirDown; dir++ {
+            idx := ctx.env.getNeighborIdx(c, dir)
+            if ctx.env.barriers.has(idx) {
+                continue
+            }
+            if n := vm.getCell(idx); n.Energy == 0 {
+                dead[i] = n
+                i++
+            }
+        }
+        if i > 0 {
+            return dead[ctx.rand.Intn(i)]
+        }
+    default:
+        if n := vm.neighbor(c, vm.direction); n.Energy > 0 {
+            return n
+        }
+    }
+
+    return nil
+}
@@ -0,0 +1,89 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "tidepool/tidepool/gene"
+)
+
+func (c *Cell) copyFrom(s *Cell) {
+    c.copyMetadata(s)
+
+    if len(c.Genome) != len(s.Genome) {
+        c.Genome = make(gene.Genome, len(s.Genome))
+    }
+    copy(c.Genome, s.Genome)
+}
+
+func (c *Cell) copyMetadata(s *Cell) {
+    c.Idx = s.Idx
+    c.ID = s.ID
+    c.Origin = s.Origin
+    c.Parent = s.Parent
+    c.Generation = s.Generation
+    c.Born = s.Born
+    c.Execs = s.Execs
+    c.Energy = s.Energy
+    c.X = s.X
+    c.Y = s.Y
+    c.Version = s.Version
+    c.Dormant = s.Dormant
+}
+
+func (e *Env) acquireCell(s *Cell) *Cell {
+    c, ok := e.cellPool.Get().(*Cell)
+    if !ok {
+        c = newCell(s.Idx, s.X, s.Y, int32(len(s.Genome)))
+    }
+    c.copyFrom(s)
+    return c
+}
+
+func (e *Env) acquireCellByIdx(idx int32) *Cell {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+    return e.acquireCell(e.cells[idx])
+}
+
+func (e *Env) releaseCell(c *Cell) {
+    e.cellPool.Put(c)
+}
+
+func (e *Env) acquireDelta() *Delta {
+    dt, ok := e.deltaPool.Get().(*Delta)
+    if !ok {
+        return &Delta{
+            Stats: make(Stats),
+            env: e,
+        }
+    }
+    return dt
+}
+
+func (dt *Delta) Release() {
+    e := dt.env
+    if e == nil {
+        return
+    }
+
+    for i, c := range dt.Cells {
+        e.releaseCell(c)
+        dt.Cells[i] = nil
+    }
+    for n := range dt.Stats {
+        delete(dt.Stats, n)
+    }
+    for i := range dt.payloads {
+        dt.payloads[i] = payloadWrite{}
+    }
+
+    *dt = Delta{
+        Cells: dt.Cells[:0],
+        Stats: dt.Stats,
+        Mutations: dt.Mutations[:0],
+        Interactions: dt.Interactions[:0],
+        payloads: dt.payloads[:0],
+        env: e,
+    }
+    e.deltaPool.Put(dt)
+}
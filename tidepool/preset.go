@@ -0,0 +1,105 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "fmt"
+    "sort"
+)
+
+type Preset struct {
+    Name string
+    Description string
+    Configure func(*Config) `json:"-"`
+    Setup func(*Env) error `json:"-"`
+}
+
+const islandSize = 32
+
+var PresetPrimordialSoup = Preset{
+    Name: "soup",
+    Description: "Frequent energy inflow into an open grid; replicators emerge from random genomes",
+    Configure: func(c *Config) {
+        c.InflowFrequency = 2
+        c.ViableCellGeneration = 2
+        c.ISA = ISAv2
+        c.Placement = PlacementDirected
+    },
+}
+
+var PresetPredation = Preset{
+    Name: "predation",
+    Description: "Killers absorb half of their victim's energy and copy part of its genome",
+    Configure: func(c *Config) {
+        c.InflowFrequency = 5
+        c.FailedKillPenalty = 2
+        c.KillLoot = 0.5
+        c.KillCopyGenes = 8
+        c.ISA = ISAv2
+    },
+}
+
+var PresetIslands = Preset{
+    Name: "islands",
+    Description: "Walled islands joined by narrow gaps, with motile cells and light from above",
+    Configure: func(c *Config) {
+        c.InflowFrequency = 5
+        c.ISA = ISAv3
+        c.Placement = PlacementDead
+        c.Light = 20
+        c.EnergyBudget = 1 << 20
+        c.EnergyReplenish = 1 << 10
+    },
+    Setup: func(e *Env) error {
+        for y := int32(0); y < e.Height; y++ {
+            for x := int32(0); x < e.Width; x++ {
+                wall := x % islandSize == 0 || y % islandSize == 0
+                gap := x % islandSize == islandSize / 2 || y % islandSize == islandSize / 2
+                if wall && !gap {
+                    if err := e.SetBarrier(x, y, true); err != nil {
+                        return err
+                    }
+                }
+            }
+        }
+        return nil
+    },
+}
+
+var presets = map[string]Preset{
+    PresetPrimordialSoup.Name: PresetPrimordialSoup,
+    PresetPredation.Name: PresetPredation,
+    PresetIslands.Name: PresetIslands,
+}
+
+func Presets() []Preset {
+    ps := make([]Preset, 0, len(presets))
+    for _, p := range presets {
+        ps = append(ps, p)
+    }
+    sort.Slice(ps, func(i, j int) bool {
+        return ps[i].Name < ps[j].Name
+    })
+    return ps
+}
+
+func LookupPreset(name string) (Preset, error) {
+    p, ok := presets[name]
+    if !ok {
+        return Preset{}, fmt.Errorf("unknown preset %q", name)
+    }
+    return p, nil
+}
+
+func (e *Env) ApplyPreset(p Preset) error {
+    config := e.GetConfig()
+    if p.Configure != nil {
+        p.Configure(&config)
+    }
+    e.SetConfig(config)
+
+    if p.Setup != nil {
+        return p.Setup(e)
+    }
+    return nil
+}
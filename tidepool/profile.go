@@ -0,0 +1,82 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "tidepool/tidepool/gene"
+)
+
+const maxProfiles = 4096
+
+type GeneProfile []int64
+
+func (p GeneProfile) clone() GeneProfile {
+    n := make(GeneProfile, len(p))
+    copy(n, p)
+    return n
+}
+
+func (p GeneProfile) Add(a GeneProfile) GeneProfile {
+    if len(a) > len(p) {
+        n := make(GeneProfile, len(a))
+        copy(n, p)
+        p = n
+    }
+    for i, v := range a {
+        p[i] += v
+    }
+    return p
+}
+
+func (e *Env) addProfile(hash uint64, p GeneProfile) {
+    if _, ok := e.profiles[hash]; !ok && len(e.profiles) >= maxProfiles {
+        e.pruneProfiles()
+    }
+    e.profiles[hash] = e.profiles[hash].Add(p)
+}
+
+func (e *Env) pruneProfiles() {
+    live := make(map[uint64]struct{}, e.liveCells.len())
+    for _, idx := range e.liveCells.all() {
+        live[e.cells[idx].Genome.Hash()] = struct{}{}
+    }
+    for h := range e.profiles {
+        if _, ok := live[h]; !ok {
+            delete(e.profiles, h)
+        }
+    }
+}
+
+func (e *Env) GeneProfile(hash uint64) GeneProfile {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+    return e.profiles[hash].clone()
+}
+
+func (e *Env) DominantGenome() (gene.Genome, int64) {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+
+    counts := make(map[uint64]int64)
+    var dom *Cell
+    var max int64
+
+    for _, idx := range e.liveCells.all() {
+        c := e.cells[idx]
+        h := c.Genome.Hash()
+        counts[h]++
+        if counts[h] > max {
+            max = counts[h]
+            dom = c
+        }
+    }
+
+    if dom == nil {
+        return nil, 0
+    }
+
+    g := make(gene.Genome, len(dom.Genome))
+    copy(g, dom.Genome)
+
+    return g, max
+}
@@ -0,0 +1,8 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+//go:build race
+// +build race
+
+package tidepool
+
+const raceEnabled = true
@@ -0,0 +1,175 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "tidepool/tidepool/gene"
+)
+
+type GenomeRank struct {
+    Hash uint64
+    Genome gene.Genome
+    Count int64
+}
+
+type genomeRanking struct {
+    n int
+    hashes []uint64
+    counts map[uint64]int64
+    genomes map[uint64]gene.Genome
+    top []uint64
+}
+
+func newGenomeRanking(e *Env, n int) *genomeRanking {
+    r := &genomeRanking{
+        n: n,
+        hashes: make([]uint64, len(e.cells)),
+        counts: make(map[uint64]int64),
+        genomes: make(map[uint64]gene.Genome),
+    }
+    for _, idx := range e.liveCells.all() {
+        r.set(e.cells[idx])
+    }
+    r.top = r.rank()
+    return r
+}
+
+func (r *genomeRanking) set(c *Cell) (uint64, uint64) {
+    var h uint64
+    if c.live() {
+        h = c.Genome.Hash()
+    }
+    old := r.hashes[c.Idx]
+    if old == h {
+        return 0, 0
+    }
+    r.hashes[c.Idx] = h
+
+    if old != 0 {
+        if r.counts[old]--; r.counts[old] <= 0 {
+            delete(r.counts, old)
+            delete(r.genomes, old)
+        }
+    }
+    if h != 0 {
+        if r.counts[h]++; r.counts[h] == 1 {
+            r.genomes[h] = append(gene.Genome(nil), c.Genome...)
+        }
+    }
+
+    return old, h
+}
+
+func (r *genomeRanking) less(a, b uint64) bool {
+    if r.counts[a] != r.counts[b] {
+        return r.counts[a] > r.counts[b]
+    }
+    return a < b
+}
+
+func (r *genomeRanking) rank() []uint64 {
+    top := make([]uint64, 0, r.n + 1)
+    for h := range r.counts {
+        i := len(top)
+        for i > 0 && r.less(h, top[i - 1]) {
+            i--
+        }
+        if i >= r.n {
+            continue
+        }
+        top = append(top, 0)
+        copy(top[i + 1:], top[i:])
+        top[i] = h
+        if len(top) > r.n {
+            top = top[:r.n]
+        }
+    }
+    return top
+}
+
+func (r *genomeRanking) affects(h uint64) bool {
+    if h == 0 {
+        return false
+    }
+    if len(r.top) < r.n {
+        return true
+    }
+    for _, t := range r.top {
+        if t == h {
+            return true
+        }
+    }
+    return r.less(h, r.top[len(r.top) - 1])
+}
+
+func (r *genomeRanking) update(dt *Delta) {
+    dirty := false
+    for _, c := range dt.Cells {
+        old, h := r.set(c)
+        if r.affects(old) || r.affects(h) {
+            dirty = true
+        }
+    }
+    if !dirty {
+        return
+    }
+
+    prev := r.top
+    r.top = r.rank()
+
+    for i, h := range r.top {
+        entered := true
+        for _, p := range prev {
+            if p == h {
+                entered = false
+                break
+            }
+        }
+        if entered {
+            r.addEvent(dt, EventTopGenomeEntered, h, i)
+        }
+    }
+    if len(prev) > 0 && len(r.top) > 0 && prev[0] != r.top[0] {
+        r.addEvent(dt, EventTopGenomeTakeover, r.top[0], 0)
+    }
+}
+
+func (r *genomeRanking) addEvent(dt *Delta, t string, h uint64, rank int) {
+    ev := dt.addEvent(t, nil)
+    ev.Genome = r.genomes[h]
+    ev.Values = Stats{
+        "Rank": int64(rank + 1),
+        "Count": r.counts[h],
+    }
+}
+
+func (e *Env) updateRanking(config Config, dt *Delta) {
+    if config.TopGenomes <= 0 {
+        e.ranking = nil
+        return
+    }
+    if e.ranking == nil || e.ranking.n != config.TopGenomes {
+        e.ranking = newGenomeRanking(e, config.TopGenomes)
+        return
+    }
+    e.ranking.update(dt)
+}
+
+func (e *Env) TopGenomes() []GenomeRank {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+
+    if e.ranking == nil {
+        return nil
+    }
+
+    rs := make([]GenomeRank, len(e.ranking.top))
+    for i, h := range e.ranking.top {
+        rs[i] = GenomeRank{
+            Hash: h,
+            Genome: e.ranking.genomes[h],
+            Count: e.ranking.counts[h],
+        }
+    }
+    return rs
+}
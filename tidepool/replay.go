@@ -0,0 +1,179 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "bufio"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "sort"
+    "sync/atomic"
+)
+
+var ErrNoBase = errors.New("replay needs a snapshot or leading keyframe")
+
+type Replay struct {
+    snapshot []byte
+    deltas []*Delta
+    env *Env
+    pos int
+}
+
+func ReadDeltas(r io.Reader) ([]*Delta, error) {
+    var dts []*Delta
+
+    s := bufio.NewScanner(r)
+    s.Buffer(make([]byte, 64 * 1024), 1 << 30)
+    for line := 1; s.Scan(); line++ {
+        if len(s.Bytes()) == 0 {
+            continue
+        }
+        dt := &Delta{}
+        if err := json.Unmarshal(s.Bytes(), dt); err != nil {
+            return nil, fmt.Errorf("line %d: %v", line, err)
+        }
+        dts = append(dts, dt)
+    }
+
+    return dts, s.Err()
+}
+
+func NewReplay(snapshot []byte, deltas []*Delta) (*Replay, error) {
+    if snapshot == nil && (len(deltas) == 0 || !deltas[0].Keyframe) {
+        return nil, ErrNoBase
+    }
+
+    r := &Replay{
+        snapshot: snapshot,
+        deltas: deltas,
+    }
+    if err := r.reset(); err != nil {
+        return nil, err
+    }
+
+    return r, nil
+}
+
+func keyframeEnv(kf *Delta) *Env {
+    var w, h, gs int32
+    for _, c := range kf.Cells {
+        if c.X >= w {
+            w = c.X + 1
+        }
+        if c.Y >= h {
+            h = c.Y + 1
+        }
+        if int32(len(c.Genome)) > gs {
+            gs = int32(len(c.Genome))
+        }
+    }
+    e := NewEnv(w, h, gs, 0, 1)
+    if kf.RunID != "" {
+        e.runID = kf.RunID
+    }
+    return e
+}
+
+func (r *Replay) reset() error {
+    if r.snapshot == nil {
+        r.env = keyframeEnv(r.deltas[0])
+        r.pos = 0
+        return nil
+    }
+
+    e := &Env{}
+    if err := e.UnmarshalBinary(r.snapshot); err != nil {
+        return err
+    }
+    r.env = e
+    r.pos = 0
+
+    return nil
+}
+
+func (r *Replay) Env() *Env {
+    return r.env
+}
+
+func (r *Replay) Len() int {
+    return len(r.deltas)
+}
+
+func (r *Replay) Pos() int {
+    return r.pos
+}
+
+func (r *Replay) Tick() int64 {
+    return r.env.Ticks()
+}
+
+func (r *Replay) Step() bool {
+    if r.pos >= len(r.deltas) {
+        return false
+    }
+    r.env.applyRecorded(r.deltas[r.pos])
+    r.pos++
+    return true
+}
+
+func (r *Replay) Seek(pos int) error {
+    if pos < 0 {
+        pos = 0
+    }
+    if pos > len(r.deltas) {
+        pos = len(r.deltas)
+    }
+
+    kf := -1
+    for i := pos - 1; i > 0; i-- {
+        if r.deltas[i].Keyframe {
+            kf = i
+            break
+        }
+    }
+
+    if pos < r.pos {
+        if err := r.reset(); err != nil {
+            return err
+        }
+    }
+    if kf > r.pos {
+        r.pos = kf
+    }
+
+    for r.pos < pos {
+        r.Step()
+    }
+
+    return nil
+}
+
+func (r *Replay) SeekTick(tick int64) error {
+    pos := sort.Search(len(r.deltas), func(i int) bool {
+        return r.deltas[i].Tick > tick
+    })
+    return r.Seek(pos)
+}
+
+func (e *Env) applyRecorded(dt *Delta) {
+    config := e.GetConfig()
+    e.mutex.Lock()
+    for _, c := range dt.Cells {
+        if c.Idx < 0 || int(c.Idx) >= len(e.cells) {
+            continue
+        }
+        if c.live() {
+            e.liveCells.add(c.Idx)
+        } else {
+            e.liveCells.remove(c.Idx)
+        }
+        e.commitCell(config, c)
+    }
+    e.updateDirty(config, dt)
+    e.seq = dt.Seq
+    e.mutex.Unlock()
+
+    atomic.StoreInt64(&e.ticks, dt.Tick)
+}
@@ -3,6 +3,8 @@
 package tidepool
 
 import (
+    "math/bits"
+
     "tidepool/tidepool/gene"
 )
 
@@ -16,14 +18,12 @@ type DefaultRNG struct {
     MutationRate float64
     InflowRateBase int64
     InflowRateModifier int64
-    bitsPerGene [gene.N]int
 }
 
 var defaultRNG = DefaultRNG{
     MutationRate: 0.00000115,
     InflowRateBase: 600,
     InflowRateModifier: 1000,
-    bitsPerGene: [gene.N]int{0, 1, 1, 2, 1, 2, 2, 3, 1, 2, 2, 3, 2, 3, 3, 4},
 }
 
 func (r DefaultRNG) Mutate(ctx *Context) bool {
@@ -40,8 +40,8 @@ func (r DefaultRNG) CellAccessible(ctx *Context, c *Cell,
         return true
     }
 
-    i := int(ctx.getRandomGene())
-    b := r.bitsPerGene[c.logo() ^ logo]
+    i := ctx.rand.Intn(int(gene.N))
+    b := bits.OnesCount(uint(c.logo() ^ logo))
 
     switch mode {
     case gene.KILL:
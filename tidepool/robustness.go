@@ -0,0 +1,229 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "math/rand"
+    "sort"
+    "strconv"
+)
+
+type PerturbationSchedule struct {
+    Count int
+    Start int64
+    End int64
+    Weights map[string]float64 `json:",omitempty"`
+    WipeFraction float64
+    BurstMax int
+    InflowMin int64
+    InflowMax int64
+}
+
+type StrainRobustness struct {
+    Hash uint64
+    Runs int
+    Dominant int
+    Presence float64
+}
+
+type RobustnessReport struct {
+    Label string `json:",omitempty"`
+    Runs int
+    Survived int
+    SurvivalRate float64
+    MeanRecovery float64
+    Strains []StrainRobustness `json:",omitempty"`
+}
+
+const robustnessTopGenomes = 5
+
+var defaultPerturbationWeights = map[string]float64{
+    ScenarioWipe: 1,
+}
+
+func (p PerturbationSchedule) weights() ([]string, []float64) {
+    ws := p.Weights
+    if len(ws) == 0 {
+        ws = defaultPerturbationWeights
+    }
+
+    actions := make([]string, 0, len(ws))
+    for a, w := range ws {
+        if w > 0 {
+            actions = append(actions, a)
+        }
+    }
+    sort.Strings(actions)
+
+    cum := make([]float64, len(actions))
+    var total float64
+    for i, a := range actions {
+        total += ws[a]
+        cum[i] = total
+    }
+    return actions, cum
+}
+
+func (p PerturbationSchedule) Generate(r *rand.Rand, width, height int32) *Scenario {
+    s := &Scenario{Name: "random"}
+
+    actions, cum := p.weights()
+    if len(actions) == 0 || p.Count < 1 {
+        return s
+    }
+
+    span := p.End - p.Start
+    if span < 1 {
+        span = 1
+    }
+    frac := p.WipeFraction
+    if frac <= 0 || frac > 1 {
+        frac = 0.5
+    }
+
+    for i := 0; i < p.Count; i++ {
+        st := ScenarioStep{
+            Tick: p.Start + r.Int63n(span),
+        }
+
+        x := r.Float64() * cum[len(cum) - 1]
+        st.Action = actions[sort.SearchFloat64s(cum, x)]
+
+        switch st.Action {
+        case ScenarioWipe:
+            w := int32(float64(width) * frac * (0.5 + r.Float64() / 2))
+            h := int32(float64(height) * frac * (0.5 + r.Float64() / 2))
+            if w < 1 {
+                w = 1
+            }
+            if h < 1 {
+                h = 1
+            }
+            st.Region = &Rect{
+                X: r.Int31n(width - w + 1),
+                Y: r.Int31n(height - h + 1),
+                W: w,
+                H: h,
+            }
+        case ScenarioBurst:
+            n := p.BurstMax
+            if n < 1 {
+                n = 1
+            }
+            st.N = 1 + r.Intn(n)
+            if r.Intn(2) == 0 {
+                st.Region = &Rect{
+                    X: r.Int31n(width),
+                    Y: r.Int31n(height),
+                    W: 1 + r.Int31n(width),
+                    H: 1 + r.Int31n(height),
+                }
+            }
+        case ScenarioParam:
+            lo, hi := p.InflowMin, p.InflowMax
+            if lo < 1 {
+                lo = 1
+            }
+            if hi < lo {
+                hi = lo
+            }
+            st.Param = ParamInflowFrequency
+            st.Value = strconv.FormatInt(lo + r.Int63n(hi - lo + 1), 10)
+        }
+
+        s.Steps = append(s.Steps, st)
+    }
+
+    sort.SliceStable(s.Steps, func(i, j int) bool {
+        return s.Steps[i].Tick < s.Steps[j].Tick
+    })
+
+    return s
+}
+
+func Robustness(label string, reports []RunReport) RobustnessReport {
+    rr := RobustnessReport{
+        Label: label,
+        Runs: len(reports),
+    }
+
+    strains := make(map[uint64]*StrainRobustness)
+    var recovered int
+    for _, r := range reports {
+        survived := !r.Extinct()
+        if survived {
+            rr.Survived++
+        }
+        if r.PrePerturbation > 0 {
+            rr.MeanRecovery += float64(r.Stats["LiveCells"]) / float64(r.PrePerturbation)
+            recovered++
+        }
+
+        for i, g := range r.Dominant {
+            s, ok := strains[g.Hash]
+            if !ok {
+                s = &StrainRobustness{Hash: g.Hash}
+                strains[g.Hash] = s
+            }
+            s.Runs++
+            if i == 0 {
+                s.Dominant++
+            }
+        }
+    }
+
+    if rr.Runs > 0 {
+        rr.SurvivalRate = float64(rr.Survived) / float64(rr.Runs)
+    }
+    if recovered > 0 {
+        rr.MeanRecovery /= float64(recovered)
+    }
+
+    for _, s := range strains {
+        s.Presence = float64(s.Runs) / float64(rr.Runs)
+        rr.Strains = append(rr.Strains, *s)
+    }
+    sort.Slice(rr.Strains, func(i, j int) bool {
+        a, b := rr.Strains[i], rr.Strains[j]
+        if a.Runs != b.Runs {
+            return a.Runs > b.Runs
+        }
+        if a.Dominant != b.Dominant {
+            return a.Dominant > b.Dominant
+        }
+        return a.Hash < b.Hash
+    })
+
+    return rr
+}
+
+func RunRobustness(configs map[string]Config, seeds []int64, opts BatchOptions) []RobustnessReport {
+    if opts.Perturb == nil {
+        opts.Perturb = &PerturbationSchedule{
+            Count: 3,
+            Start: opts.Ticks / 4,
+            End: opts.Ticks * 3 / 4,
+        }
+    }
+
+    labels := make([]string, 0, len(configs))
+    for l := range configs {
+        labels = append(labels, l)
+    }
+    sort.Strings(labels)
+
+    rrs := make([]RobustnessReport, len(labels))
+    for i, l := range labels {
+        cfg := configs[l]
+        if cfg.TopGenomes < 1 {
+            cfg.TopGenomes = robustnessTopGenomes
+        }
+        rrs[i] = Robustness(l, RunBatch(cfg, seeds, opts))
+    }
+
+    sort.SliceStable(rrs, func(i, j int) bool {
+        return rrs[i].SurvivalRate > rrs[j].SurvivalRate
+    })
+
+    return rrs
+}
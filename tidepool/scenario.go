@@ -0,0 +1,224 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "os"
+    "sort"
+
+    "tidepool/tidepool/gene"
+)
+
+const (
+    ScenarioParam = "param"
+    ScenarioWipe = "wipe"
+    ScenarioInject = "inject"
+    ScenarioBurst = "burst"
+    ScenarioPreset = "preset"
+)
+
+const defaultInjectEnergy = 1000
+
+type ScenarioStep struct {
+    Tick int64
+    Action string
+    Param string `json:",omitempty"`
+    Value string `json:",omitempty"`
+    Region *Rect `json:",omitempty"`
+    X int32 `json:",omitempty"`
+    Y int32 `json:",omitempty"`
+    Genome string `json:",omitempty"`
+    Energy int64 `json:",omitempty"`
+    N int `json:",omitempty"`
+    Preset string `json:",omitempty"`
+}
+
+type Scenario struct {
+    Name string `json:",omitempty"`
+    Steps []ScenarioStep
+}
+
+type scenarioRun struct {
+    steps []ScenarioStep
+    next int
+}
+
+func ReadScenario(r io.Reader) (*Scenario, error) {
+    var s Scenario
+    if err := json.NewDecoder(r).Decode(&s); err != nil {
+        return nil, err
+    }
+    return &s, nil
+}
+
+func LoadScenario(path string) (*Scenario, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    return ReadScenario(f)
+}
+
+func (s *Scenario) Validate(e *Env) error {
+    for i, st := range s.Steps {
+        var err error
+        switch st.Action {
+        case ScenarioParam:
+            if st.Param == "" {
+                err = fmt.Errorf("missing param")
+            }
+        case ScenarioWipe:
+        case ScenarioInject:
+            if st.X < 0 || st.Y < 0 || st.X >= e.Width || st.Y >= e.Height {
+                err = fmt.Errorf("cell %d,%d out of bounds", st.X, st.Y)
+                break
+            }
+            var g gene.Genome
+            if g, err = gene.ParseGenome(st.Genome); err == nil && int32(len(g)) > e.GenomeSize {
+                err = fmt.Errorf("genome exceeds %d genes", e.GenomeSize)
+            }
+        case ScenarioBurst:
+            if st.N < 1 {
+                err = fmt.Errorf("invalid burst size %d", st.N)
+            }
+        case ScenarioPreset:
+            _, err = LookupPreset(st.Preset)
+        default:
+            err = fmt.Errorf("unknown action %q", st.Action)
+        }
+        if err != nil {
+            return fmt.Errorf("step %d: %w", i, err)
+        }
+    }
+    return nil
+}
+
+func (e *Env) SetScenario(s *Scenario) error {
+    var run *scenarioRun
+    if s != nil {
+        if err := s.Validate(e); err != nil {
+            return err
+        }
+        run = &scenarioRun{
+            steps: append([]ScenarioStep(nil), s.Steps...),
+        }
+        sort.SliceStable(run.steps, func(i, j int) bool {
+            return run.steps[i].Tick < run.steps[j].Tick
+        })
+        ticks := e.Ticks()
+        for run.next < len(run.steps) && run.steps[run.next].Tick < ticks {
+            run.next++
+        }
+    }
+
+    e.paramMutex.Lock()
+    e.scenario = run
+    e.paramMutex.Unlock()
+
+    return nil
+}
+
+func (e *Env) ScenarioRemaining() int {
+    e.paramMutex.Lock()
+    defer e.paramMutex.Unlock()
+
+    if e.scenario == nil {
+        return 0
+    }
+    return len(e.scenario.steps) - e.scenario.next
+}
+
+func (e *Env) takeScenario(ticks int64) []ScenarioStep {
+    e.paramMutex.Lock()
+    defer e.paramMutex.Unlock()
+
+    run := e.scenario
+    if run == nil {
+        return nil
+    }
+
+    i := run.next
+    for run.next < len(run.steps) && run.steps[run.next].Tick <= ticks {
+        run.next++
+    }
+    return run.steps[i:run.next]
+}
+
+func (e *Env) runScenario(ticks int64) {
+    steps := e.takeScenario(ticks)
+    if len(steps) == 0 {
+        return
+    }
+
+    for _, st := range steps {
+        dt := e.acquireDelta()
+        dt.force = true
+        dt.setTick(ticks)
+
+        ev := dt.addEvent(EventScenario, nil)
+        ev.Message = st.Action
+        if err := e.scenarioStep(dt, st); err != nil {
+            ev.Message = fmt.Sprintf("%s: %v", st.Action, err)
+        }
+        dt.Stats.inc("ScenarioSteps", 1)
+
+        e.externalMutex.Lock()
+        e.external = append(e.external, dt)
+        e.externalMutex.Unlock()
+    }
+    e.applyParams()
+
+    select {
+    case e.externalReady <- struct{}{}:
+    default:
+    }
+}
+
+func (e *Env) scenarioStep(dt *Delta, st ScenarioStep) error {
+    switch st.Action {
+    case ScenarioParam:
+        return e.SetParam(st.Param, st.Value)
+    case ScenarioWipe:
+        r := Rect{W: e.Width, H: e.Height}
+        if st.Region != nil {
+            r = st.Region.clip(e.Width, e.Height)
+        }
+        e.killRegion(dt, r)
+        dt.Stats.inc("ScenarioKills", int64(len(dt.Cells)))
+    case ScenarioInject:
+        g, err := gene.ParseGenome(st.Genome)
+        if err != nil {
+            return err
+        }
+        energy := st.Energy
+        if energy <= 0 {
+            energy = defaultInjectEnergy
+        }
+        c := e.acquireCellByIdx(st.X + e.Width * st.Y)
+        c.resetGenome()
+        copy(c.Genome, g)
+        c.Energy = energy
+        c.ID = e.getNextCellID()
+        c.Origin = c.ID
+        c.Parent = 0
+        c.Generation = 0
+        c.Born = dt.Tick
+        c.Execs = 0
+        c.Dormant = false
+        dt.Cells = append(dt.Cells, c)
+        dt.Events[0].Cell = c.clone()
+    case ScenarioBurst:
+        return e.InflowBurst(st.N, st.Region)
+    case ScenarioPreset:
+        p, err := LookupPreset(st.Preset)
+        if err != nil {
+            return err
+        }
+        return e.ApplyPreset(p)
+    }
+    return nil
+}
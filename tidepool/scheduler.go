@@ -0,0 +1,287 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+type Scheduler int
+
+const (
+    SchedulerUniform Scheduler = iota
+    SchedulerEnergy
+    SchedulerSweep
+    SchedulerHot
+    SchedulerFair
+)
+
+const weightedRetries = 8
+const defaultHotChunkSize = 16
+const hotDecayEvery = 1024
+
+type weightTree struct {
+    tree []int64
+    weights []int64
+}
+
+func newWeightTree(n int) *weightTree {
+    return &weightTree{
+        tree: make([]int64, n + 1),
+        weights: make([]int64, n),
+    }
+}
+
+func (t *weightTree) set(i int32, w int64) {
+    d := w - t.weights[i]
+    if d == 0 {
+        return
+    }
+    t.weights[i] = w
+    for j := int(i) + 1; j < len(t.tree); j += j & -j {
+        t.tree[j] += d
+    }
+}
+
+func (t *weightTree) total() int64 {
+    var s int64
+    for j := len(t.tree) - 1; j > 0; j -= j & -j {
+        s += t.tree[j]
+    }
+    return s
+}
+
+func (t *weightTree) find(r int64) int32 {
+    pos := 0
+    step := 1
+    for step * 2 < len(t.tree) {
+        step *= 2
+    }
+    for ; step > 0; step /= 2 {
+        if next := pos + step; next < len(t.tree) && t.tree[next] <= r {
+            pos = next
+            r -= t.tree[next]
+        }
+    }
+    return int32(pos)
+}
+
+func cellWeight(config Config, c *Cell) int64 {
+    if !c.live() {
+        return 0
+    }
+    if config.WeightFunc != nil {
+        if w := config.WeightFunc(c); w > 0 {
+            return w
+        }
+        return 0
+    }
+    return c.Energy
+}
+
+func (e *Env) buildWeights(config Config) {
+    e.weights = newWeightTree(len(e.cells))
+    for _, idx := range e.liveCells.all() {
+        e.weights.set(idx, cellWeight(config, e.cells[idx]))
+    }
+}
+
+func (e *Env) updateWeights(config Config, c *Cell) {
+    if e.weights == nil {
+        return
+    }
+    if config.Scheduler != SchedulerEnergy {
+        e.weights = nil
+        return
+    }
+    e.weights.set(c.Idx, cellWeight(config, c))
+}
+
+func (e *Env) getWeightedCell(ctx *Context, config Config) *Cell {
+    e.mutex.Lock()
+    defer e.mutex.Unlock()
+
+    if e.weights == nil {
+        e.buildWeights(config)
+    }
+
+    total := e.weights.total()
+    if total <= 0 {
+        return nil
+    }
+
+    for i := 0; i < weightedRetries; i++ {
+        idx := e.weights.find(ctx.rand.Int63n(total))
+        if _, exec := e.execCells[idx]; exec {
+            continue
+        }
+        e.execCells[idx] = struct{}{}
+        return e.acquireCell(e.cells[idx])
+    }
+
+    return nil
+}
+
+func (e *Env) getUniformCell(ctx *Context) *Cell {
+    e.mutex.Lock()
+    defer e.mutex.Unlock()
+
+    live := e.liveCells.all()
+    if len(live) == 0 {
+        return nil
+    }
+
+    for i := 0; i < weightedRetries; i++ {
+        idx := live[ctx.rand.Intn(len(live))]
+        if _, exec := e.execCells[idx]; exec {
+            continue
+        }
+        e.execCells[idx] = struct{}{}
+        return e.acquireCell(e.cells[idx])
+    }
+
+    return nil
+}
+
+func (e *Env) getSweepCell(ctx *Context) *Cell {
+    e.mutex.Lock()
+    defer e.mutex.Unlock()
+
+    deferred := 0
+    for {
+        if len(e.sweep) == 0 {
+            if e.liveCells.len() == 0 {
+                return nil
+            }
+            for _, idx := range e.liveCells.all() {
+                e.sweep = append(e.sweep, idx)
+            }
+            ctx.rand.Shuffle(len(e.sweep), func(i, j int) {
+                e.sweep[i], e.sweep[j] = e.sweep[j], e.sweep[i]
+            })
+            e.sweeps++
+        }
+        if deferred >= len(e.sweep) {
+            return nil
+        }
+
+        idx := e.sweep[len(e.sweep) - 1]
+        e.sweep = e.sweep[:len(e.sweep) - 1]
+
+        if !e.liveCells.has(idx) {
+            continue
+        }
+        if _, exec := e.execCells[idx]; exec {
+            e.sweep = append(e.sweep, 0)
+            copy(e.sweep[1:], e.sweep)
+            e.sweep[0] = idx
+            deferred++
+            continue
+        }
+        e.execCells[idx] = struct{}{}
+        return e.acquireCell(e.cells[idx])
+    }
+}
+
+func (e *Env) Sweeps() int64 {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+    return e.sweeps
+}
+
+type chunkActivity struct {
+    size int32
+    cols int32
+    rows int32
+    tree *weightTree
+    updates int
+}
+
+func newChunkActivity(width, height, size int32) *chunkActivity {
+    cols := (width + size - 1) / size
+    rows := (height + size - 1) / size
+    return &chunkActivity{
+        size: size,
+        cols: cols,
+        rows: rows,
+        tree: newWeightTree(int(cols * rows)),
+    }
+}
+
+func (a *chunkActivity) touch(c *Cell) {
+    i := c.Y / a.size * a.cols + c.X / a.size
+    a.tree.set(i, a.tree.weights[i] + 1)
+}
+
+func (a *chunkActivity) decay() {
+    a.updates++
+    if a.updates < hotDecayEvery {
+        return
+    }
+    a.updates = 0
+    for i, w := range a.tree.weights {
+        a.tree.set(int32(i), w / 2)
+    }
+}
+
+func hotChunkSize(config Config) int32 {
+    if config.HotChunkSize > 0 {
+        return config.HotChunkSize
+    }
+    return defaultHotChunkSize
+}
+
+func (e *Env) updateActivity(config Config, dt *Delta) {
+    if config.Scheduler != SchedulerHot {
+        e.chunks = nil
+        return
+    }
+    size := hotChunkSize(config)
+    if e.chunks == nil || e.chunks.size != size {
+        e.chunks = newChunkActivity(e.Width, e.Height, size)
+    }
+    for _, c := range dt.Cells {
+        e.chunks.touch(c)
+    }
+    e.chunks.decay()
+}
+
+func (e *Env) getHotCell(ctx *Context, config Config) *Cell {
+    if ctx.rand.Float64() < config.HotMinRate {
+        return nil
+    }
+
+    e.mutex.Lock()
+    defer e.mutex.Unlock()
+
+    if e.chunks == nil {
+        return nil
+    }
+    total := e.chunks.tree.total()
+    if total <= 0 {
+        return nil
+    }
+
+    a := e.chunks
+    ci := a.tree.find(ctx.rand.Int63n(total))
+    x0, y0 := ci % a.cols * a.size, ci / a.cols * a.size
+
+    i := 0
+    for y := y0; y < y0 + a.size && y < e.Height; y++ {
+        for x := x0; x < x0 + a.size && x < e.Width; x++ {
+            idx := y * e.Width + x
+            if !e.liveCells.has(idx) {
+                continue
+            }
+            if _, exec := e.execCells[idx]; exec {
+                continue
+            }
+            ctx.cellsBuf[i] = idx
+            i++
+        }
+    }
+
+    if i == 0 {
+        return nil
+    }
+
+    idx := ctx.cellsBuf[ctx.rand.Intn(i)]
+    e.execCells[idx] = struct{}{}
+    return e.acquireCell(e.cells[idx])
+}
@@ -0,0 +1,219 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "math"
+    "testing"
+)
+
+func schedulerEnv(t *testing.T, scheduler Scheduler, energies ...int64) *Env {
+    env := NewEnv(8, 8, 16, 0, 1)
+    config := env.GetConfig()
+    config.Scheduler = scheduler
+    env.SetConfig(config)
+
+    for i, energy := range energies {
+        if _, err := env.InjectCell(int32(i) % env.Width, int32(i) / env.Width, nil, energy); err != nil {
+            t.Fatal(err)
+        }
+    }
+    return env
+}
+
+func finishCell(e *Env, c *Cell) {
+    e.mutex.Lock()
+    delete(e.execCells, c.Idx)
+    e.mutex.Unlock()
+    e.releaseCell(c)
+}
+
+func TestSweepSchedulerOverlap(t *testing.T) {
+    const cells = 16
+    const workers = 4
+    const sweeps = 8
+
+    energies := make([]int64, cells)
+    for i := range energies {
+        energies[i] = 100
+    }
+    env := schedulerEnv(t, SchedulerSweep, energies...)
+    ctx := newContext(env)
+
+    seen := make(map[int64]map[int32]int)
+    var running []*Cell
+    for env.Sweeps() <= sweeps {
+        if len(running) == workers {
+            finishCell(env, running[0])
+            running = running[1:]
+        }
+        c := env.getSweepCell(ctx)
+        if c == nil {
+            if len(running) == 0 {
+                t.Fatal("sweep scheduler returned no cell with nothing executing")
+            }
+            finishCell(env, running[0])
+            running = running[1:]
+            continue
+        }
+        s := env.Sweeps()
+        if seen[s] == nil {
+            seen[s] = make(map[int32]int)
+        }
+        seen[s][c.Idx]++
+        running = append(running, c)
+    }
+
+    for s := int64(1); s <= sweeps; s++ {
+        if len(seen[s]) != cells {
+            t.Fatalf("sweep %d executed %d of %d cells", s, len(seen[s]), cells)
+        }
+        for idx, n := range seen[s] {
+            if n != 1 {
+                t.Fatalf("sweep %d executed cell %d %d times", s, idx, n)
+            }
+        }
+    }
+}
+
+func TestEnergySchedulerProportional(t *testing.T) {
+    const samples = 40000
+
+    energies := []int64{100, 200, 300, 400}
+    env := schedulerEnv(t, SchedulerEnergy, energies...)
+    ctx := newContext(env)
+    config := env.GetConfig()
+
+    counts := make(map[int32]int)
+    for i := 0; i < samples; i++ {
+        c := env.getWeightedCell(ctx, config)
+        if c == nil {
+            t.Fatal("energy scheduler returned no cell")
+        }
+        counts[c.Idx]++
+        finishCell(env, c)
+    }
+
+    for i, energy := range energies {
+        want := float64(energy) / 1000
+        got := float64(counts[int32(i)]) / samples
+        if got < want - 0.02 || got > want + 0.02 {
+            t.Fatalf("cell %d: expected share %.2f, got %.3f", i, want, got)
+        }
+    }
+
+    c := env.GetCell(3, 0)
+    c.Energy = 0
+    env.applyDelta(&Delta{Cells: []*Cell{c}, Stats: make(Stats)})
+    for i := 0; i < 1000; i++ {
+        c := env.getWeightedCell(ctx, config)
+        if c.Idx == 3 {
+            t.Fatal("energy scheduler selected a dead cell")
+        }
+        finishCell(env, c)
+    }
+}
+
+func TestHotSchedulerActivity(t *testing.T) {
+    const samples = 4000
+
+    env := schedulerEnv(t, SchedulerHot)
+    config := env.GetConfig()
+    config.HotChunkSize = 4
+    config.HotMinRate = 0
+    env.SetConfig(config)
+
+    if _, err := env.InjectCell(6, 6, nil, 100); err != nil {
+        t.Fatal(err)
+    }
+    hot, err := env.InjectCell(1, 1, nil, 100)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for i := 0; i < 9; i++ {
+        env.applyDelta(&Delta{Cells: []*Cell{env.GetCell(1, 1)}, Stats: make(Stats)})
+    }
+
+    ctx := newContext(env)
+    counts := make(map[int32]int)
+    for i := 0; i < samples; i++ {
+        c := env.getHotCell(ctx, config)
+        if c == nil {
+            t.Fatal("hot scheduler returned no cell")
+        }
+        counts[c.Idx]++
+        finishCell(env, c)
+    }
+    if share := float64(counts[hot.Idx]) / samples; share < 0.85 {
+        t.Fatalf("expected the active chunk to dominate, got share %.3f", share)
+    }
+    if len(counts) != 2 {
+        t.Fatalf("expected both chunks to be selected, got %v", counts)
+    }
+
+    config.HotMinRate = 1
+    env.SetConfig(config)
+    if c := env.getHotCell(ctx, env.GetConfig()); c != nil {
+        t.Fatalf("expected min rate to defer to the base scheduler, got cell %d", c.Idx)
+    }
+}
+
+func TestFairSchedulerPrefersSlowCells(t *testing.T) {
+    const samples = 10000
+
+    env := schedulerEnv(t, SchedulerFair, 100, 100, 100, 100)
+    ctx := newContext(env)
+    for i, execs := range []int64{0, 10, 20, 40} {
+        c := env.GetCell(int32(i), 0)
+        c.Execs = execs
+        env.applyDelta(&Delta{Cells: []*Cell{c}, Stats: make(Stats)})
+    }
+
+    counts := make(map[int32]int)
+    for i := 0; i < samples; i++ {
+        c := env.getFairCell(ctx)
+        if c == nil {
+            t.Fatal("fair scheduler returned no cell")
+        }
+        counts[c.Idx]++
+        finishCell(env, c)
+    }
+    if counts[0] < samples / 2 || counts[3] > samples / 50 {
+        t.Fatalf("expected slowest cell to be preferred, got %v", counts)
+    }
+
+    held := env.getFairCell(ctx)
+    for i := 0; i < 1000; i++ {
+        c := env.getFairCell(ctx)
+        if c == nil {
+            continue
+        }
+        if c.Idx == held.Idx {
+            t.Fatalf("fair scheduler selected executing cell %d", c.Idx)
+        }
+        finishCell(env, c)
+    }
+}
+
+func TestFairness(t *testing.T) {
+    env := schedulerEnv(t, SchedulerFair, 100, 100, 100, 100)
+    if f := env.Fairness(); f != 1 {
+        t.Fatalf("expected fairness 1 without execs, got %v", f)
+    }
+
+    for i := int32(0); i < 4; i++ {
+        c := env.GetCell(i, 0)
+        c.Execs = 5
+        env.applyDelta(&Delta{Cells: []*Cell{c}, Stats: make(Stats)})
+    }
+    if f := env.Fairness(); math.Abs(f - 1) > 1e-9 {
+        t.Fatalf("expected fairness 1 for equal rates, got %v", f)
+    }
+
+    c := env.GetCell(0, 0)
+    c.Execs = 0
+    env.applyDelta(&Delta{Cells: []*Cell{c}, Stats: make(Stats)})
+    if f, want := env.Fairness(), 0.75; math.Abs(f - want) > 1e-9 {
+        t.Fatalf("expected fairness %v, got %v", want, f)
+    }
+}
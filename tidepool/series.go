@@ -0,0 +1,51 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "time"
+)
+
+type StatsHistory []Stats
+
+func (h *StatsHistory) Record(s Stats) {
+    c := make(Stats, len(s))
+    c.Add(s)
+    *h = append(*h, c)
+}
+
+func (e *Env) Sample() Stats {
+    config := e.GetConfig()
+
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+
+    return Stats{
+        "Ticks": e.Ticks(),
+        "LiveCells": int64(e.liveCells.len()),
+        "ViableLiveCells": e.viableCount(config),
+        "DormantCells": e.dormant,
+        "Diversity": e.diversityLocked(),
+    }
+}
+
+func (h StatsHistory) Series(metric string) ([]float64, []int64) {
+    vs := make([]float64, len(h))
+    ts := make([]int64, len(h))
+    for i, s := range h {
+        vs[i] = float64(s[metric])
+        ts[i] = s["Ticks"]
+    }
+    return vs, ts
+}
+
+func (w *StatsWindows) Series(width time.Duration, metric string) ([]float64, []int64) {
+    bs := w.Buckets(width)
+    vs := make([]float64, len(bs))
+    ts := make([]int64, len(bs))
+    for i, b := range bs {
+        vs[i] = float64(b.Stats[metric])
+        ts[i] = b.Stats["Ticks"]
+    }
+    return vs, ts
+}
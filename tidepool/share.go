@@ -0,0 +1,52 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "tidepool/tidepool/gene"
+)
+
+func GenomeSimilarity(a, b gene.Genome) float64 {
+    n := len(a)
+    if len(b) < n {
+        n = len(b)
+    }
+    if n == 0 {
+        return 0
+    }
+
+    same := 0
+    for i := 0; i < n; i++ {
+        if a[i] == b[i] {
+            same++
+        }
+    }
+
+    return float64(same) / float64(n)
+}
+
+func (c Config) kin(a, b *Cell) bool {
+    if c.ShareKinThreshold <= 0 {
+        return true
+    }
+    return GenomeSimilarity(a.Genome, b.Genome) >= c.ShareKinThreshold
+}
+
+func (c *Cell) share(config Config, n *Cell) {
+    if config.ShareFraction <= 0 {
+        e := c.Energy + n.Energy
+        n.Energy = e / 2
+        c.Energy = e - n.Energy
+        return
+    }
+
+    d := int64(float64(c.Energy) * config.ShareFraction)
+    if d >= c.Energy && !config.ShareCanKill {
+        d = c.Energy - 1
+    }
+    if d <= 0 {
+        return
+    }
+    n.Energy += d
+    c.Energy -= d
+}
@@ -0,0 +1,151 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "math/rand"
+    "sync/atomic"
+)
+
+type Perturbation int
+
+const (
+    PerturbNone Perturbation = iota
+    PerturbDisturbance
+    PerturbMutationSpike
+)
+
+const stagnationSamples = 16
+
+type stagnationDetector struct {
+    window int64
+    next int64
+    pop []int64
+    div []int64
+}
+
+func (p Perturbation) String() string {
+    switch p {
+    case PerturbDisturbance:
+        return "disturbance"
+    case PerturbMutationSpike:
+        return "mutation spike"
+    default:
+        return "none"
+    }
+}
+
+func flat(vs []int64, tolerance float64) bool {
+    min, max, sum := vs[0], vs[0], int64(0)
+    for _, v := range vs {
+        if v < min {
+            min = v
+        }
+        if v > max {
+            max = v
+        }
+        sum += v
+    }
+    mean := float64(sum) / float64(len(vs))
+    if mean == 0 {
+        return max == 0
+    }
+    return float64(max - min) / mean <= tolerance
+}
+
+func (e *Env) detectStagnation(config Config, dt *Delta) {
+    if config.StagnationWindow <= 0 {
+        e.stagnation = nil
+        return
+    }
+    s := e.stagnation
+    if s == nil || s.window != config.StagnationWindow {
+        s = &stagnationDetector{window: config.StagnationWindow}
+        e.stagnation = s
+    }
+    if dt.Tick < s.next {
+        return
+    }
+
+    step := s.window / stagnationSamples
+    if step < 1 {
+        step = 1
+    }
+    s.next = dt.Tick + step
+
+    s.pop = append(s.pop, int64(e.liveCells.len()))
+    s.div = append(s.div, e.diversityLocked())
+    if len(s.pop) > stagnationSamples {
+        s.pop = s.pop[1:]
+        s.div = s.div[1:]
+    }
+    if len(s.pop) < stagnationSamples {
+        return
+    }
+    if !flat(s.pop, config.StagnationTolerance) || !flat(s.div, config.StagnationTolerance) {
+        return
+    }
+
+    ev := dt.addEvent(EventStagnation, nil)
+    ev.Values = Stats{
+        "LiveCells": s.pop[len(s.pop) - 1],
+        "Diversity": s.div[len(s.div) - 1],
+        "Window": s.window,
+    }
+    ev.Message = config.Perturbation.String()
+    dt.Stats.inc("Stagnations", 1)
+
+    s.pop = s.pop[:0]
+    s.div = s.div[:0]
+
+    switch config.Perturbation {
+    case PerturbDisturbance:
+        e.disturb(config, dt.Tick)
+    case PerturbMutationSpike:
+        atomic.StoreInt64(&e.spikeUntil, dt.Tick + config.SpikeDuration)
+    }
+}
+
+func (e *Env) disturb(config Config, tick int64) {
+    r := rand.New(rand.NewSource(e.Seed + tick))
+
+    dt := e.acquireDelta()
+    dt.force = true
+    for _, idx := range e.liveCells.all() {
+        if r.Float64() >= config.DisturbanceFraction {
+            continue
+        }
+        c := e.acquireCell(e.cells[idx])
+        c.Energy = 0
+        c.ID = 0
+        c.Origin = 0
+        c.Parent = 0
+        c.Generation = 0
+        c.Born = 0
+        c.Execs = 0
+        c.Dormant = false
+        c.resetGenome()
+        dt.Cells = append(dt.Cells, c)
+    }
+    if len(dt.Cells) == 0 {
+        dt.Release()
+        return
+    }
+    dt.Stats.inc("DisturbanceKills", int64(len(dt.Cells)))
+
+    e.externalMutex.Lock()
+    e.external = append(e.external, dt)
+    e.externalMutex.Unlock()
+
+    select {
+    case e.externalReady <- struct{}{}:
+    default:
+    }
+}
+
+func (e *Env) mutationSpike(config Config, tick int64) float64 {
+    if tick < atomic.LoadInt64(&e.spikeUntil) {
+        return config.SpikeMutationRate
+    }
+    return 0
+}
@@ -26,6 +26,12 @@ func (s Stats) Add(a Stats) {
             fallthrough
         case "MaxGeneration":
             s.update(n, i)
+        case "DormantCells":
+            fallthrough
+        case "EnergyBudget":
+            fallthrough
+        case "ExecFairness":
+            fallthrough
         case "ViableLiveCells":
             fallthrough
         case "LiveCells":
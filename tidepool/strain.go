@@ -0,0 +1,96 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "tidepool/tidepool/gene"
+)
+
+type strain struct {
+    genome gene.Genome
+    count int64
+    peak int64
+    founded int64
+    peakTick int64
+}
+
+type strainWatch struct {
+    threshold int64
+    hashes []uint64
+    strains map[uint64]*strain
+}
+
+func newStrainWatch(e *Env, threshold int64, tick int64) *strainWatch {
+    w := &strainWatch{
+        threshold: threshold,
+        hashes: make([]uint64, len(e.cells)),
+        strains: make(map[uint64]*strain),
+    }
+    for _, idx := range e.liveCells.all() {
+        w.set(nil, tick, e.cells[idx])
+    }
+    return w
+}
+
+func (w *strainWatch) set(dt *Delta, tick int64, c *Cell) {
+    var h uint64
+    if c.live() {
+        h = c.Genome.Hash()
+    }
+    old := w.hashes[c.Idx]
+    if old == h {
+        return
+    }
+    w.hashes[c.Idx] = h
+
+    if s := w.strains[old]; s != nil {
+        if s.count--; s.count <= 0 {
+            delete(w.strains, old)
+            if s.genome != nil && dt != nil {
+                w.addEvent(dt, tick, s)
+            }
+        }
+    }
+    if h == 0 {
+        return
+    }
+
+    s := w.strains[h]
+    if s == nil {
+        s = &strain{founded: tick}
+        w.strains[h] = s
+    }
+    if s.count++; s.count > s.peak {
+        s.peak = s.count
+        s.peakTick = tick
+        if s.genome == nil && s.peak >= w.threshold {
+            s.genome = append(gene.Genome(nil), c.Genome...)
+        }
+    }
+}
+
+func (w *strainWatch) addEvent(dt *Delta, tick int64, s *strain) {
+    ev := dt.addEvent(EventStrainExtinct, nil)
+    ev.Genome = s.genome
+    ev.Values = Stats{
+        "Peak": s.peak,
+        "PeakTick": s.peakTick,
+        "Founded": s.founded,
+        "Lifespan": tick - s.founded,
+    }
+    dt.Stats.inc("StrainExtinctions", 1)
+}
+
+func (e *Env) updateStrains(config Config, dt *Delta) {
+    if config.StrainAlertThreshold <= 0 {
+        e.strains = nil
+        return
+    }
+    if e.strains == nil || e.strains.threshold != config.StrainAlertThreshold {
+        e.strains = newStrainWatch(e, config.StrainAlertThreshold, dt.Tick)
+        return
+    }
+    for _, c := range dt.Cells {
+        e.strains.set(dt, dt.Tick, c)
+    }
+}
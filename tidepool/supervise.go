@@ -0,0 +1,45 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "fmt"
+    "runtime/debug"
+)
+
+type CrashPolicy int
+
+const (
+    CrashRestart CrashPolicy = iota
+    CrashHalt
+)
+
+func (e *Env) abandon(ctx *Context) {
+    if ctx.claimed < 0 {
+        return
+    }
+    e.mutex.Lock()
+    delete(e.execCells, ctx.claimed)
+    e.mutex.Unlock()
+    ctx.claimed = -1
+}
+
+func (e *Env) supervise(ctx *Context, ticks int64,
+    f func(*Context, int64) *Delta) (dt *Delta, crash *Delta) {
+    defer func() {
+        r := recover()
+        if r == nil {
+            return
+        }
+        e.abandon(ctx)
+
+        crash = &Delta{
+            Tick: ticks,
+            Stats: Stats{"WorkerCrashes": 1},
+        }
+        ev := crash.addEvent(EventWorkerCrashed, nil)
+        ev.Message = fmt.Sprintf("%v\n%s", r, debug.Stack())
+    }()
+
+    return f(ctx, ticks), nil
+}
@@ -0,0 +1,36 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+func (c Config) temperatureAt(x, width int32) float64 {
+    if width <= 1 {
+        return c.TemperatureMin
+    }
+    f := float64(x) / float64(width - 1)
+    return c.TemperatureMin + (c.TemperatureMax - c.TemperatureMin) * f
+}
+
+func (e *Env) TemperatureAt(x, y int32) float64 {
+    return e.GetConfig().temperatureAt(x, e.Width)
+}
+
+type heat struct {
+    budget int64
+    mutation float64
+    death float64
+}
+
+func (e *Env) heatAt(config Config, c *Cell) heat {
+    if config.TemperatureMin == 0 && config.TemperatureMax == 0 {
+        return heat{}
+    }
+    t := config.temperatureAt(c.X, e.Width)
+    if t <= 0 {
+        return heat{}
+    }
+    return heat{
+        budget: int64(t * float64(config.HeatBudget)),
+        mutation: t * config.HeatMutationRate,
+        death: t * config.HeatDeathRate,
+    }
+}
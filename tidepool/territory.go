@@ -0,0 +1,106 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+type TerritoryMap struct {
+    ChunkSize int32
+    Cols int32
+    Rows int32
+    Dominant []uint64
+}
+
+type territoryMap struct {
+    size int32
+    cols int32
+    rows int32
+    hashes []uint64
+    counts []map[uint64]int32
+    dominant []uint64
+}
+
+func newTerritoryMap(e *Env, size int32) *territoryMap {
+    cols := (e.Width + size - 1) / size
+    rows := (e.Height + size - 1) / size
+    t := &territoryMap{
+        size: size,
+        cols: cols,
+        rows: rows,
+        hashes: make([]uint64, len(e.cells)),
+        counts: make([]map[uint64]int32, cols * rows),
+        dominant: make([]uint64, cols * rows),
+    }
+    for i := range t.counts {
+        t.counts[i] = make(map[uint64]int32)
+    }
+    for _, idx := range e.liveCells.all() {
+        t.set(e.cells[idx])
+    }
+    return t
+}
+
+func (t *territoryMap) chunk(c *Cell) int32 {
+    return c.Y / t.size * t.cols + c.X / t.size
+}
+
+func (t *territoryMap) set(c *Cell) {
+    var h uint64
+    if c.live() {
+        h = c.Genome.Hash()
+    }
+    old := t.hashes[c.Idx]
+    if old == h {
+        return
+    }
+    t.hashes[c.Idx] = h
+
+    i := t.chunk(c)
+    counts := t.counts[i]
+    if old != 0 {
+        if counts[old]--; counts[old] <= 0 {
+            delete(counts, old)
+        }
+    }
+    if h != 0 {
+        counts[h]++
+    }
+
+    var dom uint64
+    var max int32
+    for h, n := range counts {
+        if n > max || (n == max && h < dom) {
+            dom, max = h, n
+        }
+    }
+    t.dominant[i] = dom
+}
+
+func (e *Env) updateTerritory(config Config, dt *Delta) {
+    if config.TerritoryChunkSize <= 0 {
+        e.territory = nil
+        return
+    }
+    if e.territory == nil || e.territory.size != config.TerritoryChunkSize {
+        e.territory = newTerritoryMap(e, config.TerritoryChunkSize)
+        return
+    }
+    for _, c := range dt.Cells {
+        e.territory.set(c)
+    }
+}
+
+func (e *Env) Territories() TerritoryMap {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+
+    t := e.territory
+    if t == nil {
+        return TerritoryMap{}
+    }
+
+    return TerritoryMap{
+        ChunkSize: t.size,
+        Cols: t.cols,
+        Rows: t.rows,
+        Dominant: append([]uint64(nil), t.dominant...),
+    }
+}
@@ -0,0 +1,68 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepooltest
+
+import (
+    "sync"
+    "time"
+)
+
+type fakeTicker struct {
+    c chan time.Time
+    period time.Duration
+    next time.Time
+    stopped bool
+}
+
+type FakeClock struct {
+    mutex sync.Mutex
+    now time.Time
+    tickers []*fakeTicker
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+    return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    return c.now
+}
+
+func (c *FakeClock) Ticker(d time.Duration) (<-chan time.Time, func()) {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+
+    t := &fakeTicker{
+        c: make(chan time.Time, 1),
+        period: d,
+        next: c.now.Add(d),
+    }
+    c.tickers = append(c.tickers, t)
+
+    return t.c, func() {
+        c.mutex.Lock()
+        t.stopped = true
+        c.mutex.Unlock()
+    }
+}
+
+func (c *FakeClock) Advance(d time.Duration) {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+
+    c.now = c.now.Add(d)
+    for _, t := range c.tickers {
+        if t.stopped || t.period <= 0 {
+            continue
+        }
+        for !t.next.After(c.now) {
+            select {
+            case t.c <- t.next:
+            default:
+            }
+            t.next = t.next.Add(t.period)
+        }
+    }
+}
@@ -0,0 +1,111 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepooltest
+
+import (
+    "strings"
+    "testing"
+
+    tp "tidepool/tidepool"
+    "tidepool/tidepool/gene"
+)
+
+func NewEnv(tb testing.TB, width, height, genomeSize int32) (*tp.Env, *ScriptedRNG) {
+    tb.Helper()
+
+    e := tp.NewEnv(width, height, genomeSize, 0, 1)
+
+    config := e.GetConfig()
+    config.InflowFrequency = 1 << 62
+    e.SetConfig(config)
+
+    rng := &ScriptedRNG{DefaultAccess: true}
+    e.SetRNG(rng)
+
+    return e, rng
+}
+
+func Place(tb testing.TB, e *tp.Env, x, y int32, genome string, energy int64) *tp.Cell {
+    tb.Helper()
+
+    g, err := gene.ParseGenome(genome)
+    if err != nil {
+        tb.Fatal(err)
+    }
+    c, err := e.InjectCell(x, y, g, energy)
+    if err != nil {
+        tb.Fatal(err)
+    }
+    return c
+}
+
+func Step(tb testing.TB, e *tp.Env, n int) []*tp.Delta {
+    tb.Helper()
+
+    var dts []*tp.Delta
+    for i := 0; i < n; i++ {
+        dts = append(dts, e.Advance()...)
+    }
+    return dts
+}
+
+func Stats(dts []*tp.Delta) tp.Stats {
+    s := make(tp.Stats)
+    for _, dt := range dts {
+        s.Add(dt.Stats)
+    }
+    return s
+}
+
+func FindCell(dts []*tp.Delta, x, y int32) *tp.Cell {
+    var c *tp.Cell
+    for _, dt := range dts {
+        for _, n := range dt.Cells {
+            if n.X == x && n.Y == y {
+                c = n
+            }
+        }
+    }
+    return c
+}
+
+func FindEvent(dts []*tp.Delta, typ string) *tp.Event {
+    for _, dt := range dts {
+        for _, ev := range dt.Events {
+            if ev.Type == typ {
+                return ev
+            }
+        }
+    }
+    return nil
+}
+
+func AssertStat(tb testing.TB, dts []*tp.Delta, name string, want int64) {
+    tb.Helper()
+    if got := Stats(dts)[name]; got != want {
+        tb.Fatalf("stat %s = %d, want %d", name, got, want)
+    }
+}
+
+func AssertEvent(tb testing.TB, dts []*tp.Delta, typ string) *tp.Event {
+    tb.Helper()
+    ev := FindEvent(dts, typ)
+    if ev == nil {
+        tb.Fatalf("no %s event in %d deltas", typ, len(dts))
+    }
+    return ev
+}
+
+func AssertEnergy(tb testing.TB, e *tp.Env, x, y int32, want int64) {
+    tb.Helper()
+    if got := e.GetCell(x, y).Energy; got != want {
+        tb.Fatalf("cell %d,%d energy = %d, want %d", x, y, got, want)
+    }
+}
+
+func AssertGenome(tb testing.TB, e *tp.Env, x, y int32, prefix string) {
+    tb.Helper()
+    if got := e.GetCell(x, y).Genome.String(); !strings.HasPrefix(got, prefix) {
+        tb.Fatalf("cell %d,%d genome = %s, want prefix %s", x, y, got, prefix)
+    }
+}
@@ -0,0 +1,95 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepooltest
+
+import (
+    "testing"
+
+    tp "tidepool/tidepool"
+)
+
+func TestReplication(t *testing.T) {
+    e, _ := NewEnv(t, 4, 4, 8)
+
+    config := e.GetConfig()
+    config.Scheduler = tp.SchedulerSweep
+    e.SetConfig(config)
+
+    Place(t, e, 1, 1, "0B.", 10)
+    Place(t, e, 0, 1, ".", 5)
+
+    dts := Step(t, e, 2)
+
+    AssertStat(t, dts, "Reproductions", 1)
+    AssertEvent(t, dts, tp.EventBirth)
+    AssertGenome(t, e, 0, 1, "0.")
+}
+
+func TestMove(t *testing.T) {
+    e, _ := NewEnv(t, 4, 4, 8)
+
+    config := e.GetConfig()
+    config.Scheduler = tp.SchedulerSweep
+    config.ISA = tp.ISAv3
+    e.SetConfig(config)
+
+    Place(t, e, 1, 1, "0m.", 10)
+
+    dts := Step(t, e, 1)
+
+    AssertStat(t, dts, "Moves", 1)
+    AssertGenome(t, e, 0, 1, "0m.")
+    AssertEnergy(t, e, 0, 1, 8)
+    AssertEnergy(t, e, 1, 1, 0)
+
+    if err := e.SetBarrier(3, 1, true); err != nil {
+        t.Fatal(err)
+    }
+
+    dts = Step(t, e, 1)
+
+    AssertStat(t, dts, "BlockedMoves", 1)
+    AssertEnergy(t, e, 0, 1, 6)
+}
+
+func TestDormancy(t *testing.T) {
+    e, _ := NewEnv(t, 4, 4, 8)
+
+    config := e.GetConfig()
+    config.Scheduler = tp.SchedulerSweep
+    config.ISA = tp.ISAv4
+    config.DormancyWake = 100
+    e.SetConfig(config)
+
+    Place(t, e, 1, 1, "0z.", 10)
+
+    dts := Step(t, e, 1)
+
+    AssertStat(t, dts, "Dormancies", 1)
+    AssertStat(t, dts, "DormantCells", 1)
+    AssertEnergy(t, e, 1, 1, 9)
+
+    dts = Step(t, e, 1)
+
+    AssertStat(t, dts, "DormantCells", 1)
+    AssertEnergy(t, e, 1, 1, 8)
+}
+
+func TestPlacementDead(t *testing.T) {
+    e, _ := NewEnv(t, 4, 4, 8)
+
+    config := e.GetConfig()
+    config.Scheduler = tp.SchedulerSweep
+    config.Placement = tp.PlacementDead
+    e.SetConfig(config)
+
+    Place(t, e, 1, 1, "0B.", 10)
+
+    dts := Step(t, e, 1)
+
+    AssertStat(t, dts, "Reproductions", 1)
+    AssertEnergy(t, e, 1, 1, 4)
+    if n := e.LiveCount(); n != 2 {
+        t.Fatalf("expected 2 live cells, got %d", n)
+    }
+}
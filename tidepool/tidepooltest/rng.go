@@ -0,0 +1,58 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepooltest
+
+import (
+    "sync"
+
+    tp "tidepool/tidepool"
+    "tidepool/tidepool/gene"
+)
+
+type ScriptedRNG struct {
+    Mutations []bool
+    Energies []int64
+    Access []bool
+
+    DefaultEnergy int64
+    DefaultAccess bool
+
+    mutex sync.Mutex
+}
+
+func (r *ScriptedRNG) Mutate(*tp.Context) bool {
+    r.mutex.Lock()
+    defer r.mutex.Unlock()
+
+    if len(r.Mutations) == 0 {
+        return false
+    }
+    m := r.Mutations[0]
+    r.Mutations = r.Mutations[1:]
+    return m
+}
+
+func (r *ScriptedRNG) Energy(*tp.Context) int64 {
+    r.mutex.Lock()
+    defer r.mutex.Unlock()
+
+    if len(r.Energies) == 0 {
+        return r.DefaultEnergy
+    }
+    e := r.Energies[0]
+    r.Energies = r.Energies[1:]
+    return e
+}
+
+func (r *ScriptedRNG) CellAccessible(ctx *tp.Context, c *tp.Cell,
+    logo gene.Gene, mode gene.Gene) bool {
+    r.mutex.Lock()
+    defer r.mutex.Unlock()
+
+    if len(r.Access) == 0 {
+        return r.DefaultAccess
+    }
+    a := r.Access[0]
+    r.Access = r.Access[1:]
+    return a
+}
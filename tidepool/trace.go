@@ -0,0 +1,164 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "errors"
+    "fmt"
+    "sync/atomic"
+    "time"
+
+    "tidepool/tidepool/gene"
+)
+
+var ErrCellNotFound = errors.New("cell not found")
+
+const traceCheckInterval = 100 * time.Millisecond
+
+type TraceStep struct {
+    GenomeIdx int32
+    Gene gene.Gene
+    Op string
+    Mutated bool `json:",omitempty"`
+    Skipped bool `json:",omitempty"`
+    Pointer int32
+    Register gene.Gene
+    Direction int
+    Energy int64
+    Effect string `json:",omitempty"`
+}
+
+type Trace struct {
+    CellID int64
+    Tick int64
+    X int32
+    Y int32
+    Genome gene.Genome
+    Steps []TraceStep
+    Buffer gene.Genome
+    Energy int64
+    Dormant bool
+    Child *Cell `json:",omitempty"`
+}
+
+func (t *Trace) begin(vm *VM, c *Cell, g gene.Gene, mutated bool) {
+    t.Steps = append(t.Steps, TraceStep{
+        GenomeIdx: vm.genomeIdx,
+        Gene: g,
+        Op: g.Name(),
+        Mutated: mutated,
+        Skipped: vm.loopDepth > 0,
+        Pointer: vm.pointer,
+        Register: vm.register,
+        Direction: vm.direction,
+        Energy: c.Energy,
+    })
+}
+
+func (t *Trace) end(vm *VM, c *Cell) {
+    s := &t.Steps[len(t.Steps) - 1]
+    s.Pointer = vm.pointer
+    s.Register = vm.register
+    s.Direction = vm.direction
+    s.Energy = c.Energy
+    s.Effect = vm.effect
+    vm.effect = ""
+}
+
+func (vm *VM) traceEffect(format string, args ...interface{}) {
+    if vm.trace != nil {
+        vm.effect = fmt.Sprintf(format, args...)
+    }
+}
+
+func (e *Env) hasCell(id int64) bool {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+
+    for _, idx := range e.liveCells.all() {
+        if e.cells[idx].ID == id {
+            return true
+        }
+    }
+    return false
+}
+
+func (e *Env) takeTrace(c *Cell) *Trace {
+    e.traceMutex.Lock()
+    defer e.traceMutex.Unlock()
+
+    if _, ok := e.traces[c.ID]; !ok {
+        return nil
+    }
+    return &Trace{
+        CellID: c.ID,
+        X: c.X,
+        Y: c.Y,
+        Genome: append(gene.Genome(nil), c.Genome...),
+    }
+}
+
+func (e *Env) deliverTrace(t *Trace) {
+    e.traceMutex.Lock()
+    defer e.traceMutex.Unlock()
+
+    for _, ch := range e.traces[t.CellID] {
+        select {
+        case ch <- t:
+        default:
+        }
+    }
+    atomic.AddInt32(&e.tracing, -int32(len(e.traces[t.CellID])))
+    delete(e.traces, t.CellID)
+}
+
+func (e *Env) untrace(id int64, ch chan *Trace) {
+    e.traceMutex.Lock()
+    defer e.traceMutex.Unlock()
+
+    chs := e.traces[id]
+    for i, c := range chs {
+        if c == ch {
+            chs = append(chs[:i], chs[i + 1:]...)
+            atomic.AddInt32(&e.tracing, -1)
+            break
+        }
+    }
+    if len(chs) == 0 {
+        delete(e.traces, id)
+    } else {
+        e.traces[id] = chs
+    }
+}
+
+func (e *Env) TraceNextExec(id int64) (Trace, error) {
+    if id == 0 || !e.hasCell(id) {
+        return Trace{}, ErrCellNotFound
+    }
+
+    ch := make(chan *Trace, 1)
+
+    e.traceMutex.Lock()
+    if e.traces == nil {
+        e.traces = make(map[int64][]chan *Trace)
+    }
+    e.traces[id] = append(e.traces[id], ch)
+    atomic.AddInt32(&e.tracing, 1)
+    e.traceMutex.Unlock()
+
+    defer e.untrace(id, ch)
+
+    ticker, stop := e.GetClock().Ticker(traceCheckInterval)
+    defer stop()
+
+    for {
+        select {
+        case t := <-ch:
+            return *t, nil
+        case <-ticker:
+            if !e.hasCell(id) {
+                return Trace{}, ErrCellNotFound
+            }
+        }
+    }
+}
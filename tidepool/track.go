@@ -0,0 +1,95 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "sort"
+)
+
+func (e *Env) Track(ids ...int64) {
+    e.mutex.Lock()
+    defer e.mutex.Unlock()
+
+    if e.tracked == nil {
+        e.tracked = make(map[int64]struct{})
+    }
+    for _, id := range ids {
+        if id != 0 {
+            e.tracked[id] = struct{}{}
+        }
+    }
+}
+
+func (e *Env) Untrack(ids ...int64) {
+    e.mutex.Lock()
+    defer e.mutex.Unlock()
+
+    for _, id := range ids {
+        delete(e.tracked, id)
+    }
+}
+
+func (e *Env) Tracked() []int64 {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+
+    ids := make([]int64, 0, len(e.tracked))
+    for id := range e.tracked {
+        ids = append(ids, id)
+    }
+    sort.Slice(ids, func(i, j int) bool {
+        return ids[i] < ids[j]
+    })
+    return ids
+}
+
+func (e *Env) SetTrackChannel(ch chan<- *Event) {
+    e.mutex.Lock()
+    e.trackCh = ch
+    e.mutex.Unlock()
+}
+
+func (e *Env) isTracked(id int64) bool {
+    if id == 0 {
+        return false
+    }
+    _, ok := e.tracked[id]
+    return ok
+}
+
+func (e *Env) trackCell(dt *Delta, old *Cell, c *Cell) {
+    if e.trackCh == nil || !(e.isTracked(c.ID) || e.isTracked(old.ID)) {
+        return
+    }
+
+    ev := &Event{
+        Type: EventTrackedChange,
+        Tick: dt.Tick,
+        Cell: c.clone(),
+        Values: Stats{
+            "Energy": c.Energy,
+            "Version": c.Version,
+        },
+    }
+
+    if dt.exec && c.Idx == dt.execIdx {
+        ev.Type = EventTrackedExec
+        ev.Values.Add(dt.Stats)
+    }
+
+    switch {
+    case old.ID == c.ID:
+    case c.ID == 0:
+        ev.Message = "killed"
+    case old.ID == 0:
+        ev.Message = "born"
+    default:
+        ev.Message = "replaced"
+    }
+
+    select {
+    case e.trackCh <- ev:
+    default:
+        dt.Stats.inc("TrackDropped", 1)
+    }
+}
@@ -0,0 +1,53 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "errors"
+    "fmt"
+)
+
+var ErrStaleDelta = errors.New("stale delta")
+
+func (e *Env) Apply(cells ...*Cell) error {
+    if len(cells) == 0 {
+        return nil
+    }
+
+    seen := make(map[int32]bool, len(cells))
+    dt := e.acquireDelta()
+    done := make(chan bool, 1)
+    dt.done = done
+
+    for _, c := range cells {
+        if c.X < 0 || c.Y < 0 || c.X >= e.Width || c.Y >= e.Height {
+            dt.Release()
+            return fmt.Errorf("cell %d,%d out of bounds", c.X, c.Y)
+        }
+        if c.Idx != c.X + e.Width * c.Y {
+            dt.Release()
+            return fmt.Errorf("cell %d,%d has index %d", c.X, c.Y, c.Idx)
+        }
+        if int32(len(c.Genome)) != e.GenomeSize {
+            dt.Release()
+            return fmt.Errorf("cell %d,%d genome size %d != %d",
+                c.X, c.Y, len(c.Genome), e.GenomeSize)
+        }
+        if seen[c.Idx] {
+            dt.Release()
+            return fmt.Errorf("cell %d,%d applied twice", c.X, c.Y)
+        }
+        seen[c.Idx] = true
+        dt.Cells = append(dt.Cells, e.acquireCell(c))
+    }
+
+    ev := dt.addEvent(EventTransaction, nil)
+    ev.Values = Stats{"Cells": int64(len(dt.Cells))}
+
+    e.submit(dt)
+    if !<-done {
+        return ErrStaleDelta
+    }
+
+    return nil
+}
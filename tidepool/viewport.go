@@ -0,0 +1,249 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "sync"
+    "sync/atomic"
+)
+
+type Viewport struct {
+    Rect Rect
+    Zoom int32
+}
+
+type Tile struct {
+    X int32
+    Y int32
+    W int32
+    H int32
+    Live int32
+    Energy int64
+    Dominant uint64 `json:",omitempty"`
+}
+
+type ViewUpdate struct {
+    Seq int64
+    Tick int64
+    Keyframe bool `json:",omitempty"`
+    Zoom int32
+    Cells []*Cell `json:",omitempty"`
+    Tiles []Tile `json:",omitempty"`
+}
+
+type ViewportSubscription struct {
+    C <-chan *ViewUpdate
+
+    id int
+    ch chan *ViewUpdate
+    done chan struct{}
+
+    mutex sync.Mutex
+    view Viewport
+    reset bool
+
+    dropped int64
+}
+
+func (v Viewport) raw() bool {
+    return v.Zoom <= 1
+}
+
+func (e *Env) tileLocked(x, y, zoom int32) Tile {
+    t := Rect{X: x, Y: y, W: zoom, H: zoom}.clip(e.Width, e.Height)
+    tile := Tile{X: t.X, Y: t.Y, W: t.W, H: t.H}
+
+    counts := make(map[uint64]int32)
+    var max int32
+    for j := t.Y; j < t.Y + t.H; j++ {
+        for i := t.X; i < t.X + t.W; i++ {
+            c := e.cells[i + e.Width * j]
+            if !c.live() {
+                continue
+            }
+            tile.Live++
+            tile.Energy += c.Energy
+
+            h := c.Genome.Hash()
+            counts[h]++
+            if n := counts[h]; n > max || (n == max && h < tile.Dominant) {
+                tile.Dominant, max = h, n
+            }
+        }
+    }
+
+    return tile
+}
+
+func (e *Env) Tiles(r Rect, zoom int32) []Tile {
+    if zoom < 1 {
+        zoom = 1
+    }
+    r = r.clip(e.Width, e.Height)
+    if r.W == 0 || r.H == 0 {
+        return nil
+    }
+
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+
+    var ts []Tile
+    for y := r.Y / zoom * zoom; y < r.Y + r.H; y += zoom {
+        for x := r.X / zoom * zoom; x < r.X + r.W; x += zoom {
+            ts = append(ts, e.tileLocked(x, y, zoom))
+        }
+    }
+    return ts
+}
+
+func (e *Env) tilesAt(origins [][2]int32, zoom int32) []Tile {
+    e.mutex.RLock()
+    defer e.mutex.RUnlock()
+
+    ts := make([]Tile, len(origins))
+    for i, o := range origins {
+        ts[i] = e.tileLocked(o[0], o[1], zoom)
+    }
+    return ts
+}
+
+func (h *Hub) SubscribeViewport(v Viewport, buffer int) *ViewportSubscription {
+    if buffer < 1 {
+        buffer = 1
+    }
+
+    ch := make(chan *ViewUpdate, buffer)
+    s := &ViewportSubscription{
+        C: ch,
+        ch: ch,
+        done: make(chan struct{}),
+        view: v,
+        reset: true,
+    }
+
+    h.mutex.Lock()
+    s.id = h.nextID
+    h.nextID++
+    h.views[s.id] = s
+    h.mutex.Unlock()
+
+    return s
+}
+
+func (h *Hub) UnsubscribeViewport(s *ViewportSubscription) {
+    h.mutex.Lock()
+    defer h.mutex.Unlock()
+
+    if _, ok := h.views[s.id]; !ok {
+        return
+    }
+    close(s.done)
+    delete(h.views, s.id)
+    close(s.ch)
+}
+
+func (s *ViewportSubscription) SetViewport(v Viewport) {
+    s.mutex.Lock()
+    s.view = v
+    s.reset = true
+    s.mutex.Unlock()
+}
+
+func (s *ViewportSubscription) Viewport() Viewport {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+    return s.view
+}
+
+func (s *ViewportSubscription) Dropped() int64 {
+    return atomic.LoadInt64(&s.dropped)
+}
+
+func (s *ViewportSubscription) take() (Viewport, bool) {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+    reset := s.reset
+    s.reset = false
+    return s.view, reset
+}
+
+func (h *Hub) viewUpdate(v Viewport, reset bool, dt *Delta) *ViewUpdate {
+    u := &ViewUpdate{
+        Seq: dt.Seq,
+        Tick: dt.Tick,
+        Keyframe: reset,
+        Zoom: v.Zoom,
+    }
+    if u.Zoom < 1 {
+        u.Zoom = 1
+    }
+
+    if reset {
+        if v.raw() {
+            u.Cells = h.env.GetRegion(v.Rect.X, v.Rect.Y, v.Rect.W, v.Rect.H)
+        } else {
+            u.Tiles = h.env.Tiles(v.Rect, v.Zoom)
+        }
+        return u
+    }
+
+    if v.raw() {
+        for _, c := range dt.Cells {
+            if v.Rect.Contains(c.X, c.Y) {
+                u.Cells = append(u.Cells, c.clone())
+            }
+        }
+        if len(u.Cells) == 0 {
+            return nil
+        }
+        return u
+    }
+
+    var origins [][2]int32
+    for _, c := range dt.Cells {
+        if !v.Rect.Contains(c.X, c.Y) {
+            continue
+        }
+        o := [2]int32{c.X / v.Zoom * v.Zoom, c.Y / v.Zoom * v.Zoom}
+        dup := false
+        for _, p := range origins {
+            if p == o {
+                dup = true
+                break
+            }
+        }
+        if !dup {
+            origins = append(origins, o)
+        }
+    }
+    if len(origins) == 0 {
+        return nil
+    }
+    u.Tiles = h.env.tilesAt(origins, v.Zoom)
+
+    return u
+}
+
+func (h *Hub) sendView(s *ViewportSubscription, dt *Delta) {
+    v, reset := s.take()
+    u := h.viewUpdate(v, reset, dt)
+    if u == nil {
+        return
+    }
+
+    for {
+        select {
+        case s.ch <- u:
+            return
+        default:
+        }
+        select {
+        case <-s.ch:
+            atomic.AddInt64(&s.dropped, 1)
+            s.mutex.Lock()
+            s.reset = true
+            s.mutex.Unlock()
+        default:
+        }
+    }
+}
@@ -3,6 +3,8 @@
 package tidepool
 
 import (
+    "sync/atomic"
+
     "tidepool/tidepool/gene"
 )
 
@@ -10,6 +12,7 @@ const (
     VM_NOOP int = iota
     VM_BREAK
     VM_CONTINUE
+    VM_MOVE
 )
 
 type CellMap map[int32]*Cell
@@ -26,18 +29,26 @@ type VM struct {
 
     pointer int32
     register gene.Gene
+    maxGene gene.Gene
     direction int
     buffer gene.Genome
 
     cellMap CellMap
-}
-
-func (cm CellMap) getCell(e *Env, idx int32) *Cell {
-    c, ok := cm[idx]
-    if !ok {
-        c = e.GetCellByIdx(idx)
-    }
-    return c
+    cells []*Cell
+    fetched CellMap
+    births []*Cell
+    interactions []Interaction
+    mutations []Mutation
+    pending int
+    moved *Cell
+    spent int64
+    trace *Trace
+    effect string
+    breaks map[int32]bool
+    hit *BreakpointHit
+    profile GeneProfile
+    payloads map[int32]interface{}
+    staged []int32
 }
 
 func (cm CellMap) AddCell(c *Cell) {
@@ -60,6 +71,25 @@ func (cm CellMap) Cells() []*Cell {
     return cs
 }
 
+func (vm *VM) addCell(c *Cell) {
+    if _, ok := vm.cellMap[c.Idx]; !ok {
+        vm.cells = append(vm.cells, c)
+    }
+    vm.cellMap.AddCell(c)
+}
+
+func (vm *VM) getCell(idx int32) *Cell {
+    if c, ok := vm.cellMap[idx]; ok {
+        return c
+    }
+    if c, ok := vm.fetched[idx]; ok {
+        return c
+    }
+    c := vm.ctx.env.acquireCellByIdx(idx)
+    vm.fetched[idx] = c
+    return c
+}
+
 func newVM(ctx *Context) *VM {
     env := ctx.env
     gs := env.GenomeSize
@@ -70,6 +100,9 @@ func newVM(ctx *Context) *VM {
         buffer: make(gene.Genome, gs),
         loopStack: make([]int32, gs),
         cellMap: make(CellMap),
+        fetched: make(CellMap),
+        profile: make(GeneProfile, gene.NMax),
+        payloads: make(map[int32]interface{}),
     }
     vm.reset()
 
@@ -88,7 +121,44 @@ func (vm *VM) reset() {
         vm.buffer[i] = gene.STOP
     }
 
+    for idx, c := range vm.fetched {
+        if vm.cellMap[idx] != c {
+            vm.ctx.env.releaseCell(c)
+        }
+    }
+    vm.fetched.Reset()
     vm.cellMap.Reset()
+
+    for i := range vm.cells {
+        vm.cells[i] = nil
+    }
+    vm.cells = vm.cells[:0]
+
+    vm.interactions = vm.interactions[:0]
+    vm.mutations = vm.mutations[:0]
+    vm.pending = -1
+
+    for i := range vm.births {
+        vm.births[i] = nil
+    }
+    vm.births = vm.births[:0]
+    vm.moved = nil
+    vm.spent = 0
+    vm.trace = nil
+    vm.effect = ""
+    vm.breaks = nil
+    vm.hit = nil
+
+    for i := range vm.profile {
+        vm.profile[i] = 0
+    }
+
+    if len(vm.staged) > 0 {
+        for _, idx := range vm.staged {
+            delete(vm.payloads, idx)
+        }
+        vm.staged = vm.staged[:0]
+    }
 }
 
 func (vm *VM) incGenomeIdx() {
@@ -99,6 +169,12 @@ func (vm *VM) incGenomeIdx() {
     }
 }
 
+func (vm *VM) fixMutation() {
+    if vm.pending >= 0 {
+        vm.mutations[vm.pending].Fixed = true
+    }
+}
+
 func (vm *VM) execGene(c *Cell, g gene.Gene, stats Stats) int {
     ctx := vm.ctx
     env := ctx.env
@@ -107,6 +183,7 @@ func (vm *VM) execGene(c *Cell, g gene.Gene, stats Stats) int {
     case gene.ZERO:
         vm.pointer = 0
         vm.register = gene.ZERO
+        vm.pending = -1
         vm.direction = 0
     case gene.FWD:
         if vm.pointer == vm.genomeMaxIdx {
@@ -121,25 +198,29 @@ func (vm *VM) execGene(c *Cell, g gene.Gene, stats Stats) int {
             vm.pointer--
         }
     case gene.INC:
-        if vm.register == gene.STOP {
+        if vm.register >= vm.maxGene {
             vm.register = gene.ZERO
         } else {
             vm.register++
         }
     case gene.DEC:
-        if vm.register == gene.ZERO {
-            vm.register = gene.STOP
+        if vm.register == gene.ZERO || vm.register > vm.maxGene {
+            vm.register = vm.maxGene
         } else {
             vm.register--
         }
     case gene.READG:
         vm.register = c.Genome[vm.pointer]
+        vm.pending = -1
     case gene.WRITEG:
         c.Genome[vm.pointer] = vm.register
+        vm.fixMutation()
     case gene.READB:
         vm.register = vm.buffer[vm.pointer]
+        vm.pending = -1
     case gene.WRITEB:
         vm.buffer[vm.pointer] = vm.register
+        vm.fixMutation()
     case gene.LOOP:
         if vm.register == gene.ZERO {
             vm.loopDepth = 1
@@ -164,48 +245,100 @@ func (vm *VM) execGene(c *Cell, g gene.Gene, stats Stats) int {
         vm.incGenomeIdx()
         vm.register = c.Genome[vm.genomeIdx]
         c.Genome[vm.genomeIdx] = reg
+        vm.fixMutation()
+        vm.pending = -1
     case gene.KILL:
         config := env.GetConfig()
         idx := env.getNeighborIdx(c, vm.direction)
-        n := vm.cellMap.getCell(env, idx)
-        if n.accessible(ctx, vm.register, gene.KILL) {
+        n := vm.getCell(idx)
+        if !n.dormant() && n.accessible(ctx, vm.register, gene.KILL) &&
+            vm.allow(ActionKill, c, n) {
+            live := n.Energy > 0
+            victim := n.Origin
+            vm.loot(config, c, n, stats)
+
             n.resetMetadata(ctx)
             n.resetGenome()
 
-            vm.cellMap.AddCell(n)
+            vm.addCell(n)
+            vm.apply(ActionKill, c, n)
 
-            if n.Energy > 0 {
+            vm.traceEffect("killed %d,%d", n.X, n.Y)
+            if live {
                 stats.inc("LiveCellsKilled", 1)
+                vm.interact(InteractionKill, c.Origin, victim)
             }
             if n.viable(config) {
                 stats.inc("ViableCellsKilled", 1)
             }
             stats.inc("CellsKilled", 1)
-        } else if n.Generation >= config.ViableCellGeneration {
-            c.Energy -= c.Energy / config.FailedKillPenalty
+        } else if n.viable(config) {
+            p := c.Energy / config.FailedKillPenalty
+            c.Energy -= p
+            vm.spent += p
         }
     case gene.SHARE:
         config := env.GetConfig()
         idx := env.getNeighborIdx(c, vm.direction)
-        n := vm.cellMap.getCell(env, idx)
-        if n.accessible(ctx, vm.register, gene.SHARE) {
-            e := c.Energy + n.Energy
-            n.Energy = e / 2
-            c.Energy = e - n.Energy
+        n := vm.getCell(idx)
+        if n.accessible(ctx, vm.register, gene.SHARE) && config.kin(c, n) &&
+            vm.allow(ActionShare, c, n) {
+            recipient := n.Origin
+            c.share(config, n)
+            vm.interact(InteractionShare, c.Origin, recipient)
 
             if n.ID == 0 {
                 n.resetID(ctx)
+                vm.resetPayload(n.Idx)
             }
 
-            vm.cellMap.AddCell(n)
+            vm.addCell(n)
+            vm.apply(ActionShare, c, n)
+            vm.traceEffect("shared with %d,%d", n.X, n.Y)
 
             if n.viable(config) {
                 stats.inc("ViableCellsShared", 1)
             }
+            if c.Energy == 0 {
+                stats.inc("DonorDeaths", 1)
+            }
             stats.inc("CellsShared", 1)
         }
     case gene.STOP:
         return VM_BREAK
+    case gene.RAND:
+        vm.register = ctx.getRandomGene()
+        vm.pending = -1
+    case gene.SENSE:
+        idx := env.getNeighborIdx(c, vm.direction)
+        vm.register = vm.getCell(idx).logo()
+        vm.pending = -1
+        vm.traceEffect("sensed %d", idx)
+    case gene.MOVE:
+        idx := env.getNeighborIdx(c, vm.direction)
+        if env.barriers.has(idx) {
+            stats.inc("BlockedMoves", 1)
+            vm.traceEffect("blocked at %d", idx)
+            break
+        }
+        n := vm.getCell(idx)
+        if n.Energy == 0 && vm.allow(ActionMove, c, n) {
+            vm.apply(ActionMove, c, n)
+            vm.movePayload(c.Idx, n.Idx)
+            c.swap(n)
+
+            vm.addCell(n)
+            vm.moved = n
+            vm.traceEffect("moved to %d,%d", n.X, n.Y)
+
+            stats.inc("Moves", 1)
+            return VM_MOVE
+        }
+    case gene.DORM:
+        c.Dormant = true
+        stats.inc("Dormancies", 1)
+        vm.traceEffect("dormant")
+        return VM_BREAK
     }
 
     return VM_NOOP
@@ -216,24 +349,108 @@ func (vm *VM) exec(c *Cell) *Delta {
     env := ctx.env
 
     defer vm.reset()
-    vm.cellMap.AddCell(c)
+    vm.addCell(c)
+    execIdx := c.Idx
+
+    dt := env.acquireDelta()
+    dt.ledger = true
+    stats := dt.Stats
+    config := env.GetConfig()
+    genes := config.ISA.Size()
+    is := env.getInstructions()
+    vm.maxGene = gene.Gene(config.Alphabet() - 1)
+
+    var hash uint64
+
+    c.Execs++
 
-    stats := make(Stats)
+    if atomic.LoadInt32(&env.tracing) > 0 {
+        vm.trace = env.takeTrace(c)
+    }
+    if atomic.LoadInt32(&env.breaking) > 0 {
+        vm.breaks = env.takeBreakpoints(c.ID)
+    }
+
+    if config.ProfileGenes {
+        hash = c.Genome.Hash()
+    }
+
+    if n := env.absorbNutrients(config, c, ctx.tick); n > 0 {
+        c.Energy += n
+        dt.energyIn += n
+        stats.inc("NutrientsAbsorbed", n)
+    }
+    if n := env.photosynthesize(config, c, ctx.tick); n > 0 {
+        c.Energy += n
+        dt.energyIn += n
+        stats.inc("Photosynthesis", n)
+    }
+
+    if c.Dormant {
+        if c.Energy >= config.DormancyWake {
+            c.Dormant = false
+            stats.inc("Awakenings", 1)
+        } else {
+            cost := config.DormancyCost
+            if cost > c.Energy {
+                cost = c.Energy
+            }
+            c.Energy -= cost
+            vm.spent += cost
+        }
+    } else if c.Energy < config.DormancyThreshold {
+        c.Dormant = true
+        stats.inc("Dormancies", 1)
+    }
+
+    h := env.heatAt(config, c)
+    h.mutation += env.mutationSpike(config, ctx.tick)
 
-    for c.Energy > 0 {
+    for c.Energy > 0 && !c.Dormant {
         g := c.Genome[vm.genomeIdx]
+        mutated := false
 
-        if env.GetRNG().Mutate(ctx) {
+        if env.GetRNG().Mutate(ctx) || (h.mutation > 0 && ctx.rand.Float64() < h.mutation) {
             mut := ctx.getRandomGene()
+            m := Mutation{
+                Tick: ctx.tick,
+                CellID: c.ID,
+                Origin: c.Origin,
+                Idx: vm.genomeIdx,
+                New: mut,
+            }
             if ctx.getRandomBool() {
+                m.Old = g
                 g = mut
             } else {
+                m.Old = vm.register
+                m.Register = true
                 vm.register = mut
             }
+            if config.RecordMutations {
+                m.ParentHash = c.Genome.Hash()
+                vm.mutations = append(vm.mutations, m)
+                if m.Register {
+                    vm.pending = len(vm.mutations) - 1
+                }
+            }
+            mutated = true
             stats.inc("Mutations", 1)
         }
 
-        c.Energy--
+        if h.budget > 0 {
+            h.budget--
+        } else {
+            c.Energy--
+            vm.spent++
+        }
+
+        if vm.breaks != nil && vm.hit == nil && vm.breaks[vm.genomeIdx] {
+            vm.hit = vm.breakAt(c, g)
+        }
+        if vm.trace != nil {
+            vm.trace.begin(vm, c, g, mutated)
+        }
 
         if vm.loopDepth > 0 {
             switch g {
@@ -243,8 +460,25 @@ func (vm *VM) exec(c *Cell) *Delta {
                 vm.loopDepth--
                 continue
             }
-        } else {
+        } else if is != nil && is[g] != nil {
+            vm.profile[g]++
+            vm.register = is[g](ctx, c, vm.register)
+            if vm.register < gene.ZERO || vm.register > vm.maxGene {
+                vm.register = gene.ZERO
+            }
+            vm.pending = -1
+            if vm.trace != nil {
+                vm.trace.end(vm, c)
+            }
+        } else if int(g) < genes {
+            vm.profile[g]++
             r := vm.execGene(c, g, stats)
+            if r == VM_MOVE {
+                c = vm.moved
+            }
+            if vm.trace != nil {
+                vm.trace.end(vm, c)
+            }
             if r == VM_BREAK {
                 break
             } else if r == VM_CONTINUE {
@@ -255,23 +489,37 @@ func (vm *VM) exec(c *Cell) *Delta {
         vm.incGenomeIdx()
     }
 
-    if vm.buffer[0] != gene.STOP {
-        idx := env.getNeighborIdx(c, vm.direction)
-        n := vm.cellMap.getCell(env, idx)
+    if vm.buffer[0] != gene.STOP && !c.Dormant {
+        n := vm.placement(c, config)
 
         stats.inc("ReproductionAttempts", 1)
 
-        if n.Energy > 0 && n.accessible(ctx, vm.register, gene.STOP) {
-            n.ID = env.getNextCellID()
+        if n != nil && n.accessible(ctx, vm.register, gene.STOP) &&
+            vm.allow(ActionReplicate, c, n) {
+            if n.Energy == 0 {
+                n.Energy = c.Energy / 2
+                c.Energy -= n.Energy
+            }
+            n.ID = ctx.newCellID()
             n.Parent = c.ID
             n.Origin = c.Origin
             n.Generation = c.Generation + 1
+            n.Born = ctx.tick
+            n.Execs = 0
+            n.Dormant = false
 
             for i, g := range vm.buffer {
                 n.Genome[i] = g
             }
 
-            vm.cellMap.AddCell(n)
+            vm.addCell(n)
+            vm.resetPayload(n.Idx)
+            vm.apply(ActionReplicate, c, n)
+
+            vm.births = append(vm.births, n)
+            if vm.trace != nil {
+                vm.trace.Child = n.clone()
+            }
 
             stats.inc("Reproductions", 1)
             stats.update("MaxGeneration", n.Generation)
@@ -280,13 +528,51 @@ func (vm *VM) exec(c *Cell) *Delta {
 
     if c.Energy == 0 {
         stats.inc("NaturalDeaths", 1)
-        if c.viable(env.GetConfig()) {
+        if c.viable(config) {
             stats.inc("ViableCellNaturalDeaths", 1)
         }
     }
+    if c.Energy > 0 && !c.Dormant && h.death > 0 && ctx.rand.Float64() < h.death {
+        vm.spent += c.Energy
+        c.Energy = 0
+        stats.inc("HeatDeaths", 1)
+    }
 
-    return &Delta{
-        Cells: vm.cellMap.Cells(),
-        Stats: stats,
+    if vm.trace != nil {
+        vm.trace.Tick = ctx.tick
+        vm.trace.Buffer = append(gene.Genome(nil), vm.buffer...)
+        vm.trace.Energy = c.Energy
+        vm.trace.Dormant = c.Dormant
+        env.deliverTrace(vm.trace)
     }
+
+    dt.Tick = ctx.tick
+    dt.execIdx = execIdx
+    dt.Cells = append(dt.Cells, vm.cells...)
+    dt.Mutations = append(dt.Mutations, vm.mutations...)
+    dt.Interactions = append(dt.Interactions, vm.interactions...)
+    dt.energyOut = vm.spent
+    if len(vm.staged) > 0 {
+        vm.flushPayloads(dt)
+    }
+
+    for _, n := range vm.births {
+        dt.addEvent(EventBirth, n.clone())
+    }
+    if vm.hit != nil {
+        ev := dt.addEvent(EventBreakpoint, c.clone())
+        ev.Values = Stats{"GenomeIdx": int64(vm.hit.GenomeIdx)}
+    }
+
+    if config.ProfileGenes {
+        for g, n := range vm.profile {
+            if n > 0 {
+                stats.inc("Exec" + gene.Gene(g).Name(), n)
+            }
+        }
+        dt.ProfileHash = hash
+        dt.Profile = vm.profile.clone()
+    }
+
+    return dt
 }
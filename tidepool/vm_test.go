@@ -0,0 +1,38 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "testing"
+
+    "tidepool/tidepool/gene"
+)
+
+func TestAlphabetSize(t *testing.T) {
+    const alphabet = 8
+
+    env := NewEnv(8, 8, 16, 0, 1)
+    env.SetRNG(DefaultRNG{})
+    config := env.GetConfig()
+    config.AlphabetSize = alphabet
+    env.SetConfig(config)
+
+    g := gene.Genome{
+        gene.STOP,
+        gene.DEC, gene.WRITEG, gene.FWD,
+        gene.INC, gene.WRITEG, gene.FWD,
+        gene.READB, gene.DEC, gene.WRITEG,
+    }
+    c := env.GetCell(2, 2)
+    copy(c.Genome, g)
+    c.Energy = int64(len(g) - 1)
+
+    c.exec(newContext(env))
+
+    want := gene.Genome{alphabet - 1, gene.ZERO, alphabet - 1}
+    for i, w := range want {
+        if c.Genome[i] != w {
+            t.Fatalf("gene %d is %d, want %d inside alphabet of %d", i, c.Genome[i], w, alphabet)
+        }
+    }
+}
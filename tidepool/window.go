@@ -0,0 +1,131 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "sort"
+    "sync"
+    "time"
+)
+
+type Resolution struct {
+    Width time.Duration
+    Keep int
+}
+
+var DefaultResolutions = []Resolution{
+    {Width: time.Second, Keep: 60},
+    {Width: time.Minute, Keep: 60},
+    {Width: time.Hour, Keep: 24 * 7},
+}
+
+type StatsBucket struct {
+    Start time.Time
+    Width time.Duration
+    Stats Stats
+}
+
+type statsLevel struct {
+    res Resolution
+    buckets []StatsBucket
+}
+
+type StatsWindows struct {
+    mutex sync.Mutex
+    levels []*statsLevel
+}
+
+func NewStatsWindows(rs ...Resolution) *StatsWindows {
+    if len(rs) == 0 {
+        rs = DefaultResolutions
+    }
+    rs = append([]Resolution(nil), rs...)
+    sort.Slice(rs, func(i, j int) bool {
+        return rs[i].Width < rs[j].Width
+    })
+
+    w := &StatsWindows{}
+    for _, r := range rs {
+        if r.Width <= 0 || r.Keep < 1 {
+            continue
+        }
+        w.levels = append(w.levels, &statsLevel{
+            res: r,
+            buckets: make([]StatsBucket, 0, r.Keep),
+        })
+    }
+
+    return w
+}
+
+func (l *statsLevel) add(t time.Time, s Stats) {
+    start := t.Truncate(l.res.Width)
+
+    if n := len(l.buckets); n > 0 && !l.buckets[n - 1].Start.Before(start) {
+        l.buckets[n - 1].Stats.Add(s)
+        return
+    }
+
+    if len(l.buckets) == l.res.Keep {
+        copy(l.buckets, l.buckets[1:])
+        l.buckets = l.buckets[:len(l.buckets) - 1]
+    }
+
+    b := StatsBucket{
+        Start: start,
+        Width: l.res.Width,
+        Stats: make(Stats, len(s)),
+    }
+    b.Stats.Add(s)
+    l.buckets = append(l.buckets, b)
+}
+
+func (w *StatsWindows) Add(t time.Time, s Stats) {
+    w.mutex.Lock()
+    defer w.mutex.Unlock()
+
+    for _, l := range w.levels {
+        l.add(t, s)
+    }
+}
+
+func (w *StatsWindows) Resolutions() []Resolution {
+    w.mutex.Lock()
+    defer w.mutex.Unlock()
+
+    rs := make([]Resolution, len(w.levels))
+    for i, l := range w.levels {
+        rs[i] = l.res
+    }
+    return rs
+}
+
+func (w *StatsWindows) Buckets(width time.Duration) []StatsBucket {
+    w.mutex.Lock()
+    defer w.mutex.Unlock()
+
+    var level *statsLevel
+    for _, l := range w.levels {
+        if l.res.Width >= width {
+            level = l
+            break
+        }
+    }
+    if level == nil {
+        if len(w.levels) == 0 {
+            return nil
+        }
+        level = w.levels[len(w.levels) - 1]
+    }
+
+    bs := make([]StatsBucket, len(level.buckets))
+    for i, b := range level.buckets {
+        bs[i] = StatsBucket{
+            Start: b.Start,
+            Width: b.Width,
+            Stats: make(Stats, len(b.Stats)),
+        }
+        bs[i].Stats.Add(b.Stats)
+    }
+    return bs
+}
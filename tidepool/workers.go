@@ -0,0 +1,100 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package tidepool
+
+import (
+    "context"
+    "sync"
+    "sync/atomic"
+)
+
+type workerPool struct {
+    wg sync.WaitGroup
+    context context.Context
+    cancels []context.CancelFunc
+}
+
+func (e *Env) SetWorkers(n int) {
+    if n < 1 {
+        n = 1
+    }
+    atomic.StoreInt32(&e.workers, int32(n))
+
+    select {
+    case e.workersChanged <- struct{}{}:
+    default:
+    }
+}
+
+func (e *Env) Workers() int {
+    return int(atomic.LoadInt32(&e.workers))
+}
+
+type QueueStats struct {
+    DeltaCap int
+    DeltaLen int
+    DeltaHighWater int
+    OutputCap int
+    OutputLen int
+    OutputHighWater int
+}
+
+type queueMarks struct {
+    delta int64
+    output int64
+}
+
+func markHighWater(n *int64, v int) {
+    for {
+        old := atomic.LoadInt64(n)
+        if int64(v) <= old || atomic.CompareAndSwapInt64(n, old, int64(v)) {
+            return
+        }
+    }
+}
+
+func (e *Env) Backlog() int {
+    if dts, ok := e.dts.Load().(chan *Delta); ok {
+        return len(dts)
+    }
+    return 0
+}
+
+func (e *Env) QueueStats() QueueStats {
+    s := QueueStats{
+        DeltaHighWater: int(atomic.LoadInt64(&e.marks.delta)),
+        OutputHighWater: int(atomic.LoadInt64(&e.marks.output)),
+    }
+    if dts, ok := e.dts.Load().(chan *Delta); ok {
+        s.DeltaCap, s.DeltaLen = cap(dts), len(dts)
+    }
+    if out, ok := e.out.Load().(chan<- *Delta); ok {
+        s.OutputCap, s.OutputLen = cap(out), len(out)
+    }
+    return s
+}
+
+func (e *Env) release(dt *Delta) {
+    e.mutex.Lock()
+    for _, c := range dt.Cells {
+        delete(e.execCells, c.Idx)
+    }
+    e.mutex.Unlock()
+}
+
+func (e *Env) resizeWorkers(p *workerPool, exec <-chan execBatch,
+    inflow chan int64, dts chan<- *Delta) {
+    n := e.Workers()
+
+    for len(p.cancels) < n {
+        context, cancel := context.WithCancel(p.context)
+        p.cancels = append(p.cancels, cancel)
+        p.wg.Add(1)
+        go e.process(&p.wg, context, exec, inflow, dts)
+    }
+    for len(p.cancels) > n {
+        last := len(p.cancels) - 1
+        p.cancels[last]()
+        p.cancels = p.cancels[:last]
+    }
+}
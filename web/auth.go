@@ -0,0 +1,104 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package web
+
+import (
+    "context"
+    "crypto/subtle"
+    "net/http"
+    "strings"
+)
+
+type Role int
+
+const (
+    RoleNone Role = iota
+    RoleObserver
+    RoleOperator
+)
+
+type identityKey struct{}
+
+type Auth struct {
+    Observers []string
+    Operators []string
+    Public bool
+}
+
+func (a *Auth) enabled() bool {
+    return a != nil && (len(a.Observers) > 0 || len(a.Operators) > 0)
+}
+
+func requestToken(r *http.Request) string {
+    if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+        return strings.TrimSpace(strings.TrimPrefix(h, "Bearer "))
+    }
+    return r.URL.Query().Get("token")
+}
+
+func matchToken(tokens []string, tok string) bool {
+    ok := false
+    for _, t := range tokens {
+        if subtle.ConstantTimeCompare([]byte(t), []byte(tok)) == 1 {
+            ok = true
+        }
+    }
+    return ok
+}
+
+func (a *Auth) Role(r *http.Request) Role {
+    if !a.enabled() {
+        return RoleOperator
+    }
+
+    if tok := requestToken(r); tok != "" {
+        if matchToken(a.Operators, tok) {
+            return RoleOperator
+        }
+        if matchToken(a.Observers, tok) {
+            return RoleObserver
+        }
+        return RoleNone
+    }
+
+    if a.Public {
+        return RoleObserver
+    }
+    return RoleNone
+}
+
+func requestIdentity(r *http.Request) string {
+    id, _ := r.Context().Value(identityKey{}).(string)
+    return id
+}
+
+func requiredRole(r *http.Request) Role {
+    switch r.Method {
+    case http.MethodGet, http.MethodHead, http.MethodOptions:
+        return RoleObserver
+    }
+    return RoleOperator
+}
+
+func (a *Auth) Wrap(h http.Handler) http.Handler {
+    if !a.enabled() {
+        return h
+    }
+
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        role := a.Role(r)
+        if role == RoleNone {
+            w.Header().Set("WWW-Authenticate", "Bearer")
+            http.Error(w, "unauthorized", http.StatusUnauthorized)
+            return
+        }
+        if role < requiredRole(r) {
+            http.Error(w, "forbidden", http.StatusForbidden)
+            return
+        }
+        if tok := requestToken(r); tok != "" {
+            r = r.WithContext(context.WithValue(r.Context(), identityKey{}, "token:" + tok))
+        }
+        h.ServeHTTP(w, r)
+    })
+}
@@ -0,0 +1,69 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package web
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+)
+
+func authStatus(h http.Handler, method, header, token string) int {
+    r := httptest.NewRequest(method, "/config", nil)
+    if header != "" {
+        r.Header.Set("Authorization", header)
+    }
+    if token != "" {
+        q := r.URL.Query()
+        q.Set("token", token)
+        r.URL.RawQuery = q.Encode()
+    }
+    w := httptest.NewRecorder()
+    h.ServeHTTP(w, r)
+    return w.Code
+}
+
+func TestAuthRoles(t *testing.T) {
+    ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+    h := (&Auth{Observers: []string{"obs"}, Operators: []string{"op"}}).Wrap(ok)
+
+    cases := []struct {
+        method, header, token string
+        code int
+    }{
+        {http.MethodGet, "", "", http.StatusUnauthorized},
+        {http.MethodGet, "", "bad", http.StatusUnauthorized},
+        {http.MethodGet, "", "obs", http.StatusOK},
+        {http.MethodPost, "", "obs", http.StatusForbidden},
+        {http.MethodPost, "", "op", http.StatusOK},
+        {http.MethodPost, "Bearer op", "", http.StatusOK},
+        {http.MethodPost, "Bearer obs", "op", http.StatusForbidden},
+    }
+    for _, c := range cases {
+        if code := authStatus(h, c.method, c.header, c.token); code != c.code {
+            t.Errorf("%s header=%q token=%q: expected %d, got %d",
+                c.method, c.header, c.token, c.code, code)
+        }
+    }
+}
+
+func TestAuthPublic(t *testing.T) {
+    ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+    h := (&Auth{Operators: []string{"op"}, Public: true}).Wrap(ok)
+
+    if code := authStatus(h, http.MethodGet, "", ""); code != http.StatusOK {
+        t.Fatalf("expected public read, got %d", code)
+    }
+    if code := authStatus(h, http.MethodPost, "", ""); code != http.StatusForbidden {
+        t.Fatalf("expected anonymous write to be rejected, got %d", code)
+    }
+}
+
+func TestAuthDisabled(t *testing.T) {
+    ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+    h := (&Auth{}).Wrap(ok)
+
+    if code := authStatus(h, http.MethodPost, "", "anything"); code != http.StatusOK {
+        t.Fatalf("expected open access, got %d", code)
+    }
+}
@@ -4,6 +4,7 @@ package web
 
 import (
     "encoding/json"
+    "fmt"
     "log"
     "net/http"
     "sync"
@@ -25,10 +26,27 @@ type Conn struct {
     upgrader websocket.Upgrader
     mutex *sync.RWMutex
     channels map[int]chan []byte
+    stale map[int]bool
+    statsChannels map[int]chan []byte
+    lastStats tp.Stats
+    windows *tp.StatsWindows
+    health health
     nextID int
 }
 
+const channelBuffer = 16
+
+type StatsJSON struct {
+    RunID string
+    Ticks int64
+    Population int64
+    Births int64
+    Deaths int64
+    Diversity int64
+}
+
 type EnvJSON struct {
+    RunID string
     Width int32
     Height int32
     ViableCellGeneration int64
@@ -46,9 +64,34 @@ func NewConn(e *tp.Env, d <-chan *tp.Delta, u <-chan time.Time) *Conn {
         upgrader: websocket.Upgrader{},
         mutex: &sync.RWMutex{},
         channels: make(map[int]chan []byte),
+        stale: make(map[int]bool),
+        statsChannels: make(map[int]chan []byte),
+        lastStats: make(tp.Stats),
+        windows: tp.NewStatsWindows(),
     }
 }
 
+func (c *Conn) Register(mux *http.ServeMux) {
+    mux.HandleFunc("/ws", c.WebsocketHandler)
+    mux.HandleFunc("/env", c.EnvHandler)
+    mux.HandleFunc("/stats", c.StatsHandler)
+    mux.HandleFunc("/control", c.ControlHandler)
+    mux.HandleFunc("/config", c.ConfigHandler)
+    mux.HandleFunc("/presets", c.PresetsHandler)
+    mux.HandleFunc("/params", c.ParamsHandler)
+    mux.HandleFunc("/history", c.HistoryHandler)
+    mux.HandleFunc("/dirty", c.DirtyHandler)
+    mux.HandleFunc("/chart.png", c.ChartHandler)
+    mux.HandleFunc("/export", c.ExportHandler)
+    mux.HandleFunc("/arrow", c.ArrowHandler)
+    mux.HandleFunc("/census", c.CensusHandler)
+    mux.HandleFunc("/phenotype", c.PhenotypeHandler)
+    mux.HandleFunc("/discoveries", c.DiscoveriesHandler)
+    mux.HandleFunc("/interactions", c.InteractionsHandler)
+    mux.HandleFunc("/healthz", c.HealthzHandler)
+    mux.HandleFunc("/readyz", c.ReadyzHandler)
+}
+
 func (c *Conn) addChannel(ch chan []byte) int {
     c.mutex.Lock()
     id := c.nextID
@@ -60,15 +103,41 @@ func (c *Conn) addChannel(ch chan []byte) int {
 
 func (c *Conn) delChannel(id int) {
     c.mutex.Lock()
-    close(c.channels[id])
-    delete(c.channels, id)
+    if ch, ok := c.channels[id]; ok {
+        close(ch)
+        delete(c.channels, id)
+        delete(c.stale, id)
+    }
+    c.mutex.Unlock()
+}
+
+func (c *Conn) addStatsChannel(ch chan []byte) int {
+    c.mutex.Lock()
+    id := c.nextID
+    c.nextID++
+    c.statsChannels[id] = ch
+    c.mutex.Unlock()
+    return id
+}
+
+func (c *Conn) delStatsChannel(id int) {
+    c.mutex.Lock()
+    if ch, ok := c.statsChannels[id]; ok {
+        close(ch)
+        delete(c.statsChannels, id)
+    }
     c.mutex.Unlock()
 }
 
 func (c *Conn) Close() {
     c.mutex.Lock()
-    for _, ch := range c.channels {
+    for id, ch := range c.channels {
+        close(ch)
+        delete(c.channels, id)
+    }
+    for id, ch := range c.statsChannels {
         close(ch)
+        delete(c.statsChannels, id)
     }
     c.mutex.Unlock()
 }
@@ -81,7 +150,7 @@ func (c *Conn) WebsocketHandler(w http.ResponseWriter, r *http.Request) {
     }
     defer s.Close()
 
-    ch := make(chan []byte)
+    ch := make(chan []byte, channelBuffer)
     id := c.addChannel(ch)
 
     go func() {
@@ -96,9 +165,60 @@ func (c *Conn) WebsocketHandler(w http.ResponseWriter, r *http.Request) {
     }
 }
 
+func (c *Conn) StatsHandler(w http.ResponseWriter, r *http.Request) {
+    f, ok := w.(http.Flusher)
+    if !ok {
+        http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "text/event-stream")
+    w.Header().Set("Cache-Control", "no-cache")
+    w.Header().Set("Connection", "keep-alive")
+
+    ch := make(chan []byte, channelBuffer)
+    id := c.addStatsChannel(ch)
+    defer c.delStatsChannel(id)
+
+    for {
+        select {
+        case js, ok := <-ch:
+            if !ok {
+                return
+            }
+            fmt.Fprintf(w, "data: %s\n\n", js)
+            f.Flush()
+        case <-r.Context().Done():
+            return
+        }
+    }
+}
+
+func (c *Conn) statsJSON() ([]byte, error) {
+    diff := func(n string) int64 {
+        return c.stats[n] - c.lastStats[n]
+    }
+
+    j := StatsJSON{
+        RunID: c.env.RunID(),
+        Ticks: c.stats["Ticks"],
+        Population: c.stats["LiveCells"],
+        Births: diff("Reproductions"),
+        Deaths: diff("NaturalDeaths") + diff("LiveCellsKilled"),
+        Diversity: c.env.Diversity(),
+    }
+
+    for n, v := range c.stats {
+        c.lastStats[n] = v
+    }
+
+    return json.Marshal(j)
+}
+
 func (c *Conn) EnvHandler(w http.ResponseWriter, r *http.Request) {
     config := c.env.GetConfig()
     j := EnvJSON{
+        RunID: c.env.RunID(),
         Width: c.env.Width,
         Height: c.env.Height,
         ViableCellGeneration: config.ViableCellGeneration,
@@ -106,6 +226,52 @@ func (c *Conn) EnvHandler(w http.ResponseWriter, r *http.Request) {
     json.NewEncoder(w).Encode(j)
 }
 
+func (c *Conn) cellsJSON() ([]byte, error) {
+    var js []byte
+    var err error
+    c.env.WithCells(func(cs []*tp.Cell) {
+        dt := &tp.Delta{
+            Cells: cs,
+            Stats: c.stats,
+        }
+        js, err = json.Marshal(dt)
+    })
+    return js, err
+}
+
+func (c *Conn) send(id int, ch chan []byte, js []byte) {
+    select {
+    case ch <- js:
+        delete(c.stale, id)
+    default:
+        c.stale[id] = true
+    }
+}
+
+func (c *Conn) publishStats() {
+    c.mutex.RLock()
+    n := len(c.statsChannels)
+    c.mutex.RUnlock()
+    if n == 0 {
+        return
+    }
+
+    js, err := c.statsJSON()
+    if err != nil {
+        log.Println(err)
+        return
+    }
+
+    c.mutex.RLock()
+    for _, ch := range c.statsChannels {
+        select {
+        case ch <- js:
+        default:
+        }
+    }
+    c.mutex.RUnlock()
+}
+
 func (c *Conn) Run() {
     for {
         select {
@@ -117,25 +283,18 @@ func (c *Conn) Run() {
                 c.cellMap.AddCell(cell)
             }
             c.stats.Add(dt.Stats)
+            c.windows.Add(c.env.GetClock().Now(), dt.Stats)
         case id := <-c.request:
-            var js []byte
-            var err error
-            c.env.WithCells(func(cs []*tp.Cell) {
-                dt := &tp.Delta{
-                    Cells: cs,
-                    Stats: c.stats,
-                }
-                js, err = json.Marshal(dt)
-            })
+            js, err := c.cellsJSON()
             if err != nil {
                 log.Println(err)
                 break
             }
-            c.mutex.RLock()
+            c.mutex.Lock()
             if ch, ok := c.channels[id]; ok {
-                ch <- js
+                c.send(id, ch, js)
             }
-            c.mutex.RUnlock()
+            c.mutex.Unlock()
         case <-c.update:
             dt := &tp.Delta{
                 Cells: c.cellMap.Cells(),
@@ -147,11 +306,21 @@ func (c *Conn) Run() {
                 break
             }
             c.cellMap.Reset()
-            c.mutex.RLock()
-            for _, ch := range c.channels {
-                ch <- js
+            c.mutex.Lock()
+            for id, ch := range c.channels {
+                if !c.stale[id] {
+                    c.send(id, ch, js)
+                    continue
+                }
+                full, err := c.cellsJSON()
+                if err != nil {
+                    log.Println(err)
+                    continue
+                }
+                c.send(id, ch, full)
             }
-            c.mutex.RUnlock()
+            c.mutex.Unlock()
+            c.publishStats()
         }
     }
 }
@@ -0,0 +1,410 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package web
+
+import (
+    "bytes"
+    "embed"
+    "encoding/json"
+    "log"
+    "net/http"
+    "strconv"
+    "text/template"
+    "time"
+
+    "tidepool/render"
+    tp "tidepool/tidepool"
+    "tidepool/tidepool/gene"
+)
+
+//go:embed static
+var static embed.FS
+
+const chartWidth = 640
+const chartHeight = 240
+
+const defaultInjectEnergy = 1000
+const maxBurst = 1000
+const defaultCensusSize = 16
+const labeledCensusSize = 64
+
+type DirtyJSON struct {
+    Cursor int64
+    Chunks []tp.Rect
+}
+
+type Index struct {
+    Host string
+    Scale int
+    Token string
+}
+
+func IndexHandler(index string, scale int) (http.HandlerFunc, error) {
+    return indexHandler(index, scale, "")
+}
+
+func indexHandler(index string, scale int, prefix string) (http.HandlerFunc, error) {
+    var t *template.Template
+    var err error
+
+    if index == "" {
+        t, err = template.ParseFS(static, "static/index.html")
+    } else {
+        t, err = template.ParseFiles(index)
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    return func(w http.ResponseWriter, r *http.Request) {
+        t.Execute(w, Index{
+            Host: r.Host + prefix,
+            Scale: scale,
+            Token: r.URL.Query().Get("token"),
+        })
+    }, nil
+}
+
+func (c *Conn) ControlHandler(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    switch r.URL.Query().Get("action") {
+    case "pause":
+        c.env.Pause()
+    case "resume":
+        c.env.Resume()
+    case "step":
+        n := int64(1)
+        if s := r.URL.Query().Get("n"); s != "" {
+            v, err := strconv.ParseInt(s, 10, 64)
+            if err != nil || v < 1 {
+                http.Error(w, "invalid step count", http.StatusBadRequest)
+                return
+            }
+            n = v
+        }
+        c.env.Pause()
+        c.env.Step(n)
+    case "speed":
+        x, err := strconv.ParseFloat(r.URL.Query().Get("x"), 64)
+        if err != nil {
+            http.Error(w, "invalid speed", http.StatusBadRequest)
+            return
+        }
+        if err := c.env.SetSpeed(x); err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+    case "inject":
+        q := r.URL.Query()
+        x, err1 := strconv.Atoi(q.Get("x"))
+        y, err2 := strconv.Atoi(q.Get("y"))
+        if err1 != nil || err2 != nil {
+            http.Error(w, "invalid position", http.StatusBadRequest)
+            return
+        }
+        g, err := gene.ParseGenome(q.Get("genome"))
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        energy := int64(defaultInjectEnergy)
+        if s := q.Get("energy"); s != "" {
+            v, err := strconv.ParseInt(s, 10, 64)
+            if err != nil || v < 1 {
+                http.Error(w, "invalid energy", http.StatusBadRequest)
+                return
+            }
+            energy = v
+        }
+        if _, err := c.env.InjectCell(int32(x), int32(y), g, energy); err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+    case "kill":
+        x, err1 := strconv.Atoi(r.URL.Query().Get("x"))
+        y, err2 := strconv.Atoi(r.URL.Query().Get("y"))
+        if err1 != nil || err2 != nil {
+            http.Error(w, "invalid position", http.StatusBadRequest)
+            return
+        }
+        if err := c.env.KillCell(int32(x), int32(y)); err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+    case "burst":
+        n, err := strconv.Atoi(r.URL.Query().Get("n"))
+        if err != nil || n < 1 || n > maxBurst {
+            http.Error(w, "invalid burst size", http.StatusBadRequest)
+            return
+        }
+        if err := c.env.InflowBurst(n, nil); err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+    case "workers":
+        n, err := strconv.Atoi(r.URL.Query().Get("n"))
+        if err != nil || n < 1 {
+            http.Error(w, "invalid worker count", http.StatusBadRequest)
+            return
+        }
+        c.env.SetWorkers(n)
+    case "preset":
+        p, err := tp.LookupPreset(r.URL.Query().Get("name"))
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        if err := c.env.ApplyPreset(p); err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+    default:
+        http.Error(w, "unknown action", http.StatusBadRequest)
+        return
+    }
+
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *Conn) ParamsHandler(w http.ResponseWriter, r *http.Request) {
+    switch r.Method {
+    case http.MethodGet:
+        json.NewEncoder(w).Encode(c.env.Params())
+    case http.MethodPost:
+        q := r.URL.Query()
+        if err := c.env.SetParam(q.Get("name"), q.Get("value")); err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        w.WriteHeader(http.StatusAccepted)
+    default:
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+    }
+}
+
+func (c *Conn) DirtyHandler(w http.ResponseWriter, r *http.Request) {
+    var cursor int64
+    if s := r.URL.Query().Get("cursor"); s != "" {
+        v, err := strconv.ParseInt(s, 10, 64)
+        if err != nil || v < 0 {
+            http.Error(w, "invalid cursor", http.StatusBadRequest)
+            return
+        }
+        cursor = v
+    }
+
+    var j DirtyJSON
+    j.Chunks, j.Cursor = c.env.DirtyChunks(cursor)
+    json.NewEncoder(w).Encode(j)
+}
+
+func (c *Conn) HistoryHandler(w http.ResponseWriter, r *http.Request) {
+    width := time.Second
+    if s := r.URL.Query().Get("width"); s != "" {
+        d, err := time.ParseDuration(s)
+        if err != nil || d <= 0 {
+            http.Error(w, "invalid width", http.StatusBadRequest)
+            return
+        }
+        width = d
+    }
+    json.NewEncoder(w).Encode(c.windows.Buckets(width))
+}
+
+func (c *Conn) ChartHandler(w http.ResponseWriter, r *http.Request) {
+    q := r.URL.Query()
+
+    width := time.Second
+    if s := q.Get("width"); s != "" {
+        d, err := time.ParseDuration(s)
+        if err != nil || d <= 0 {
+            http.Error(w, "invalid width", http.StatusBadRequest)
+            return
+        }
+        width = d
+    }
+
+    metrics := q["metric"]
+    if len(metrics) == 0 {
+        metrics = []string{"LiveCells"}
+    }
+    series := make([]render.ChartSeries, len(metrics))
+    for i, m := range metrics {
+        vs, ts := c.windows.Series(width, m)
+        series[i] = render.ChartSeries{Name: m, Values: vs, Ticks: ts}
+    }
+
+    w.Header().Set("Content-Type", "image/png")
+    if err := render.EncodeChart(w, chartWidth, chartHeight, series...); err != nil {
+        log.Println(err)
+    }
+}
+
+func (c *Conn) ExportHandler(w http.ResponseWriter, r *http.Request) {
+    if name := r.URL.Query().Get("array"); name != "" {
+        var buf bytes.Buffer
+        if err := c.env.ExportNpy(&buf, name); err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        w.Header().Set("Content-Type", "application/octet-stream")
+        w.Header().Set("Content-Disposition", "attachment; filename=" + name + ".npy")
+        w.Write(buf.Bytes())
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/zip")
+    w.Header().Set("Content-Disposition", "attachment; filename=grid.npz")
+    if err := c.env.ExportNpz(w, true); err != nil {
+        log.Println(err)
+    }
+}
+
+func (c *Conn) ArrowHandler(w http.ResponseWriter, r *http.Request) {
+    q := r.URL.Query()
+
+    var buf bytes.Buffer
+    switch q.Get("table") {
+    case "", "history":
+        width := time.Second
+        if s := q.Get("width"); s != "" {
+            d, err := time.ParseDuration(s)
+            if err != nil || d <= 0 {
+                http.Error(w, "invalid width", http.StatusBadRequest)
+                return
+            }
+            width = d
+        }
+        var h tp.StatsHistory
+        for _, b := range c.windows.Buckets(width) {
+            h = append(h, b.Stats)
+        }
+        if err := tp.ExportStatsArrow(&buf, h); err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+    case "census":
+        cs := c.env.Census()
+        c.env.LabelCensus(cs, labeledCensusSize)
+        if err := tp.ExportCensusArrow(&buf, cs); err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+    default:
+        http.Error(w, "unknown table", http.StatusBadRequest)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/vnd.apache.arrow.stream")
+    w.Write(buf.Bytes())
+}
+
+func (c *Conn) CensusHandler(w http.ResponseWriter, r *http.Request) {
+    n := defaultCensusSize
+    if s := r.URL.Query().Get("n"); s != "" {
+        v, err := strconv.Atoi(s)
+        if err != nil || v < 1 {
+            http.Error(w, "invalid n", http.StatusBadRequest)
+            return
+        }
+        n = v
+    }
+    if n > labeledCensusSize {
+        n = labeledCensusSize
+    }
+
+    cs := c.env.Census()
+    if len(cs) > n {
+        cs = cs[:n]
+    }
+    c.env.LabelCensus(cs, n)
+    json.NewEncoder(w).Encode(cs)
+}
+
+func (c *Conn) PhenotypeHandler(w http.ResponseWriter, r *http.Request) {
+    q := r.URL.Query()
+
+    var g gene.Genome
+    if s := q.Get("genome"); s != "" {
+        var err error
+        if g, err = gene.ParseGenome(s); err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+    } else {
+        x, err1 := strconv.Atoi(q.Get("x"))
+        y, err2 := strconv.Atoi(q.Get("y"))
+        if err1 != nil || err2 != nil {
+            http.Error(w, "expected genome or x and y", http.StatusBadRequest)
+            return
+        }
+        in := c.env.Inspect(int32(x), int32(y))
+        if in.Phenotype == nil {
+            http.Error(w, "no live cell", http.StatusNotFound)
+            return
+        }
+        json.NewEncoder(w).Encode(in.Phenotype)
+        return
+    }
+
+    if int32(len(g)) > c.env.GenomeSize {
+        http.Error(w, "genome too long", http.StatusBadRequest)
+        return
+    }
+    json.NewEncoder(w).Encode(c.env.Phenotype(g))
+}
+
+func (c *Conn) DiscoveriesHandler(w http.ResponseWriter, r *http.Request) {
+    json.NewEncoder(w).Encode(c.env.Discoveries())
+}
+
+func (c *Conn) InteractionsHandler(w http.ResponseWriter, r *http.Request) {
+    edges := c.env.InteractionGraph()
+
+    var err error
+    switch r.URL.Query().Get("format") {
+    case "", "json":
+        err = json.NewEncoder(w).Encode(edges)
+    case "dot":
+        w.Header().Set("Content-Type", "text/vnd.graphviz")
+        err = tp.WriteInteractionDOT(w, edges)
+    case "graphml":
+        w.Header().Set("Content-Type", "application/graphml+xml")
+        err = tp.WriteInteractionGraphML(w, edges)
+    default:
+        http.Error(w, "unknown format", http.StatusBadRequest)
+        return
+    }
+    if err != nil {
+        log.Println(err)
+    }
+}
+
+func (c *Conn) PresetsHandler(w http.ResponseWriter, r *http.Request) {
+    json.NewEncoder(w).Encode(tp.Presets())
+}
+
+func (c *Conn) ConfigHandler(w http.ResponseWriter, r *http.Request) {
+    switch r.Method {
+    case http.MethodGet:
+        json.NewEncoder(w).Encode(c.env.GetConfig())
+    case http.MethodPost:
+        config := c.env.GetConfig()
+        if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        if err := config.Validate(); err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        c.env.SetConfig(config)
+        json.NewEncoder(w).Encode(config)
+    default:
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+    }
+}
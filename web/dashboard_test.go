@@ -0,0 +1,44 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package web
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    tp "tidepool/tidepool"
+)
+
+func TestConfigHandler(t *testing.T) {
+    env := tp.NewEnv(8, 8, 16, 0, 1)
+    c := NewConn(env, nil, nil)
+
+    post := func(body string) int {
+        w := httptest.NewRecorder()
+        c.ConfigHandler(w, httptest.NewRequest(http.MethodPost, "/config", strings.NewReader(body)))
+        return w.Code
+    }
+
+    for _, body := range []string{
+        `{"FailedKillPenalty": 0}`,
+        `{"DormancyCost": -1}`,
+        `{"ShareFraction": 2}`,
+        `{"Scheduler": 99}`,
+    } {
+        if code := post(body); code != http.StatusBadRequest {
+            t.Fatalf("%s: expected 400, got %d", body, code)
+        }
+    }
+    if env.GetConfig().DormancyCost < 0 || env.GetConfig().FailedKillPenalty < 1 {
+        t.Fatal("expected invalid config to be rejected")
+    }
+
+    if code := post(`{"InflowFrequency": 7}`); code != http.StatusOK {
+        t.Fatalf("expected 200, got %d", code)
+    }
+    if env.GetConfig().InflowFrequency != 7 {
+        t.Fatal("expected config to be applied")
+    }
+}
@@ -0,0 +1,128 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package web
+
+import (
+    "encoding/json"
+    "net/http"
+    "sync"
+    "time"
+)
+
+const defaultStallTimeout = 10 * time.Second
+
+type CheckpointStatus interface {
+    LastCheckpoint() (time.Time, error)
+}
+
+type HealthJSON struct {
+    Status string
+    RunID string
+    Ticks int64
+    Paused bool
+    Running bool
+    LastTick time.Time
+    LastCheckpoint *time.Time `json:",omitempty"`
+    Error string `json:",omitempty"`
+}
+
+type health struct {
+    mutex sync.Mutex
+    stall time.Duration
+    checkpoints CheckpointStatus
+    ticks int64
+    changed time.Time
+}
+
+func (c *Conn) SetStallTimeout(d time.Duration) {
+    c.health.mutex.Lock()
+    c.health.stall = d
+    c.health.mutex.Unlock()
+}
+
+func (c *Conn) SetCheckpointStatus(s CheckpointStatus) {
+    c.health.mutex.Lock()
+    c.health.checkpoints = s
+    c.health.mutex.Unlock()
+}
+
+func (c *Conn) liveness() (HealthJSON, bool) {
+    h := &c.health
+    h.mutex.Lock()
+    defer h.mutex.Unlock()
+
+    now := c.env.GetClock().Now()
+    ticks := c.env.Ticks()
+    if ticks != h.ticks || h.changed.IsZero() || c.env.Paused() {
+        h.ticks = ticks
+        h.changed = now
+    }
+
+    j := HealthJSON{
+        Status: "ok",
+        RunID: c.env.RunID(),
+        Ticks: ticks,
+        Paused: c.env.Paused(),
+        Running: c.env.Running(),
+        LastTick: h.changed,
+    }
+
+    stall := h.stall
+    if stall == 0 {
+        stall = defaultStallTimeout
+    }
+    if j.Running && !j.Paused && now.Sub(h.changed) > stall {
+        j.Status = "stalled"
+        return j, false
+    }
+
+    return j, true
+}
+
+func (c *Conn) readiness() (HealthJSON, bool) {
+    j, ok := c.liveness()
+    if !ok {
+        return j, false
+    }
+
+    if !j.Running {
+        j.Status = "not running"
+        return j, false
+    }
+
+    c.health.mutex.Lock()
+    s := c.health.checkpoints
+    c.health.mutex.Unlock()
+
+    if s != nil {
+        last, err := s.LastCheckpoint()
+        if !last.IsZero() {
+            j.LastCheckpoint = &last
+        }
+        if err != nil {
+            j.Status = "checkpoint failed"
+            j.Error = err.Error()
+            return j, false
+        }
+    }
+
+    return j, true
+}
+
+func writeHealth(w http.ResponseWriter, j HealthJSON, ok bool) {
+    w.Header().Set("Content-Type", "application/json")
+    if !ok {
+        w.WriteHeader(http.StatusServiceUnavailable)
+    }
+    json.NewEncoder(w).Encode(j)
+}
+
+func (c *Conn) HealthzHandler(w http.ResponseWriter, r *http.Request) {
+    j, ok := c.liveness()
+    writeHealth(w, j, ok)
+}
+
+func (c *Conn) ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+    j, ok := c.readiness()
+    writeHealth(w, j, ok)
+}
@@ -0,0 +1,48 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package web
+
+import (
+    "math"
+    "net"
+    "net/http"
+    "strconv"
+
+    "tidepool/ratelimit"
+)
+
+func clientKey(r *http.Request) string {
+    if id := requestIdentity(r); id != "" {
+        return id
+    }
+    host, _, err := net.SplitHostPort(r.RemoteAddr)
+    if err != nil {
+        return r.RemoteAddr
+    }
+    return host
+}
+
+func Limit(l *ratelimit.Limiter, h http.Handler) http.Handler {
+    if !l.Enabled() {
+        return h
+    }
+
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if requiredRole(r) < RoleOperator {
+            h.ServeHTTP(w, r)
+            return
+        }
+
+        key := clientKey(r)
+        ok, wait := l.Allow(key)
+        if n := l.Remaining(key); n >= 0 {
+            w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(n))
+        }
+        if !ok {
+            w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+            http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+            return
+        }
+        h.ServeHTTP(w, r)
+    })
+}
@@ -0,0 +1,50 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package web
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "tidepool/ratelimit"
+)
+
+func limitStatus(h http.Handler, token string) int {
+    r := httptest.NewRequest(http.MethodPost, "/step?token=" + token, nil)
+    r.RemoteAddr = "10.0.0.1:1234"
+    w := httptest.NewRecorder()
+    h.ServeHTTP(w, r)
+    return w.Code
+}
+
+func TestLimitIgnoresUnvalidatedTokens(t *testing.T) {
+    ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+    h := Limit(ratelimit.New(ratelimit.Quota{Rate: 0.001, Burst: 1}), ok)
+
+    if code := limitStatus(h, "a"); code != http.StatusOK {
+        t.Fatalf("expected first request to pass, got %d", code)
+    }
+    if code := limitStatus(h, "b"); code != http.StatusTooManyRequests {
+        t.Fatalf("expected rotated token to share the IP bucket, got %d", code)
+    }
+}
+
+func TestLimitKeysValidatedTokens(t *testing.T) {
+    ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+    auth := &Auth{Operators: []string{"a", "b"}}
+    h := auth.Wrap(Limit(ratelimit.New(ratelimit.Quota{Rate: 0.001, Burst: 1}), ok))
+
+    if code := limitStatus(h, "a"); code != http.StatusOK {
+        t.Fatalf("expected operator a to pass, got %d", code)
+    }
+    if code := limitStatus(h, "b"); code != http.StatusOK {
+        t.Fatalf("expected operator b to have its own bucket, got %d", code)
+    }
+    if code := limitStatus(h, "a"); code != http.StatusTooManyRequests {
+        t.Fatalf("expected operator a to be limited, got %d", code)
+    }
+    if code := limitStatus(h, "c"); code != http.StatusUnauthorized {
+        t.Fatalf("expected unknown token to be rejected, got %d", code)
+    }
+}
@@ -0,0 +1,506 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package web
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "runtime"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    tp "tidepool/tidepool"
+)
+
+const (
+    defaultEnvWidth = 256
+    defaultEnvHeight = 256
+    defaultEnvGenome = 1024
+    defaultEnvPop = 0.01
+    defaultEnvTick = time.Millisecond
+
+    maxEnvSide = 4096
+    maxEnvGenome = 1 << 16
+    maxEnvGenes = 1 << 26
+)
+
+var (
+    ErrEnvExists = errors.New("env already exists")
+    ErrEnvNotFound = errors.New("env not found")
+    ErrEnvRunning = errors.New("env already running")
+    ErrEnvStopped = errors.New("env not running")
+    ErrEnvLimit = errors.New("env limit reached")
+    ErrInvalidEnvName = errors.New("invalid env name")
+    ErrEnvTooLarge = errors.New("env too large")
+)
+
+type ServerOptions struct {
+    Update time.Duration
+    Buffer int
+    Workers int
+    MaxEnvs int
+    Index string
+    Scale int
+}
+
+type CreateEnvJSON struct {
+    Name string
+    Width int32
+    Height int32
+    GenomeSize int32
+    Pop float64
+    Seed int64
+    Tick string
+    Preset string
+    Config *tp.Config `json:",omitempty"`
+    Start bool
+}
+
+type EnvInfoJSON struct {
+    Name string
+    RunID string
+    Width int32
+    Height int32
+    Ticks int64
+    Running bool
+    Paused bool
+}
+
+type tenant struct {
+    name string
+    env *tp.Env
+    conn *Conn
+    mux *http.ServeMux
+    tick time.Duration
+    out chan *tp.Delta
+    ticker *time.Ticker
+
+    mutex sync.Mutex
+    cancel context.CancelFunc
+    done chan struct{}
+    closed bool
+}
+
+type Server struct {
+    opts ServerOptions
+    mutex sync.RWMutex
+    tenants map[string]*tenant
+}
+
+func NewServer(opts ServerOptions) *Server {
+    if opts.Update <= 0 {
+        opts.Update = time.Second
+    }
+    if opts.Buffer < 1 {
+        opts.Buffer = 4096
+    }
+    if opts.Workers < 1 {
+        opts.Workers = runtime.NumCPU()
+    }
+    if opts.Scale < 1 {
+        opts.Scale = 1
+    }
+    return &Server{
+        opts: opts,
+        tenants: make(map[string]*tenant),
+    }
+}
+
+func validEnvName(name string) bool {
+    return name != "" && !strings.ContainsAny(name, "/\\?#") &&
+        !strings.HasPrefix(name, ".")
+}
+
+func (s *Server) admitLocked(name string) error {
+    if _, ok := s.tenants[name]; ok {
+        return ErrEnvExists
+    }
+    if s.opts.MaxEnvs > 0 && len(s.tenants) >= s.opts.MaxEnvs {
+        return ErrEnvLimit
+    }
+    return nil
+}
+
+func (s *Server) admit(name string) error {
+    s.mutex.RLock()
+    defer s.mutex.RUnlock()
+    return s.admitLocked(name)
+}
+
+func (s *Server) Add(name string, e *tp.Env, tick time.Duration) error {
+    if !validEnvName(name) {
+        return ErrInvalidEnvName
+    }
+
+    index, err := indexHandler(s.opts.Index, s.opts.Scale, "/envs/" + name)
+    if err != nil {
+        return err
+    }
+
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    if err := s.admitLocked(name); err != nil {
+        return err
+    }
+
+    t := &tenant{
+        name: name,
+        env: e,
+        mux: http.NewServeMux(),
+        tick: tick,
+        out: make(chan *tp.Delta, channelBuffer),
+        ticker: time.NewTicker(s.opts.Update),
+    }
+    t.conn = NewConn(e, t.out, t.ticker.C)
+    t.conn.Register(t.mux)
+    t.mux.HandleFunc("/", index)
+    s.tenants[name] = t
+
+    go t.conn.Run()
+
+    return nil
+}
+
+func (s *Server) Create(j CreateEnvJSON) (*tp.Env, error) {
+    if !validEnvName(j.Name) {
+        return nil, ErrInvalidEnvName
+    }
+
+    if j.Width < 1 {
+        j.Width = defaultEnvWidth
+    }
+    if j.Height < 1 {
+        j.Height = defaultEnvHeight
+    }
+    if j.GenomeSize < 1 {
+        j.GenomeSize = defaultEnvGenome
+    }
+    if j.Pop <= 0 {
+        j.Pop = defaultEnvPop
+    }
+    if j.Width > maxEnvSide || j.Height > maxEnvSide || j.GenomeSize > maxEnvGenome ||
+        int64(j.Width) * int64(j.Height) * int64(j.GenomeSize) > maxEnvGenes {
+        return nil, ErrEnvTooLarge
+    }
+    if j.Pop > 1 {
+        return nil, errors.New("invalid pop")
+    }
+    if j.Seed == 0 {
+        j.Seed = -1
+    }
+    tick := defaultEnvTick
+    if j.Tick != "" {
+        d, err := time.ParseDuration(j.Tick)
+        if err != nil || d <= 0 {
+            return nil, errors.New("invalid tick")
+        }
+        tick = d
+    }
+    if err := s.admit(j.Name); err != nil {
+        return nil, err
+    }
+
+    pop := int32(j.Pop * float64(j.Width * j.Height))
+    e := tp.NewEnv(j.Width, j.Height, j.GenomeSize, pop, j.Seed)
+
+    if j.Preset != "" {
+        p, err := tp.LookupPreset(j.Preset)
+        if err == nil {
+            err = e.ApplyPreset(p)
+        }
+        if err != nil {
+            return nil, err
+        }
+    }
+    if j.Config != nil {
+        if err := j.Config.Validate(); err != nil {
+            return nil, err
+        }
+        e.SetConfig(*j.Config)
+    }
+
+    if err := s.Add(j.Name, e, tick); err != nil {
+        return nil, err
+    }
+    if j.Start {
+        if err := s.Start(j.Name); err != nil {
+            s.Delete(j.Name)
+            return nil, err
+        }
+    }
+
+    return e, nil
+}
+
+func (s *Server) lookup(name string) (*tenant, error) {
+    s.mutex.RLock()
+    defer s.mutex.RUnlock()
+
+    t, ok := s.tenants[name]
+    if !ok {
+        return nil, ErrEnvNotFound
+    }
+    return t, nil
+}
+
+func (s *Server) Env(name string) (*tp.Env, error) {
+    t, err := s.lookup(name)
+    if err != nil {
+        return nil, err
+    }
+    return t.env, nil
+}
+
+func (s *Server) Start(name string) error {
+    t, err := s.lookup(name)
+    if err != nil {
+        return err
+    }
+
+    t.mutex.Lock()
+    defer t.mutex.Unlock()
+
+    if t.closed {
+        return ErrEnvNotFound
+    }
+    if t.done != nil {
+        return ErrEnvRunning
+    }
+
+    dts := make(chan *tp.Delta, channelBuffer)
+    hub := tp.NewHub(t.env, dts)
+    sub := hub.Subscribe(tp.SubscribeOptions{
+        Policy: tp.PolicyDropOldest,
+        Buffer: s.opts.Buffer,
+    })
+    go hub.Run()
+
+    ctx, cancel := context.WithCancel(context.Background())
+    done := make(chan struct{})
+    t.cancel = cancel
+    t.done = done
+
+    go func() {
+        defer close(done)
+        for dt := range sub.C {
+            t.out <- dt
+        }
+    }()
+
+    go t.env.RunWith(tp.RunOptions{
+        Workers: s.opts.Workers,
+        Tick: t.tick,
+        Context: ctx,
+    }, dts)
+
+    return nil
+}
+
+func (t *tenant) stopLocked() error {
+    if t.done == nil {
+        return ErrEnvStopped
+    }
+    t.cancel()
+    <-t.done
+    t.cancel = nil
+    t.done = nil
+
+    return nil
+}
+
+func (t *tenant) stop() error {
+    t.mutex.Lock()
+    defer t.mutex.Unlock()
+    return t.stopLocked()
+}
+
+func (s *Server) Stop(name string) error {
+    t, err := s.lookup(name)
+    if err != nil {
+        return err
+    }
+    return t.stop()
+}
+
+func (t *tenant) close() {
+    t.mutex.Lock()
+    t.stopLocked()
+    t.closed = true
+    t.mutex.Unlock()
+
+    t.ticker.Stop()
+    close(t.out)
+    t.conn.Close()
+}
+
+func (s *Server) Delete(name string) error {
+    s.mutex.Lock()
+    t, ok := s.tenants[name]
+    delete(s.tenants, name)
+    s.mutex.Unlock()
+
+    if !ok {
+        return ErrEnvNotFound
+    }
+    t.close()
+
+    return nil
+}
+
+func (s *Server) Close() {
+    s.mutex.Lock()
+    ts := s.tenants
+    s.tenants = make(map[string]*tenant)
+    s.mutex.Unlock()
+
+    for _, t := range ts {
+        t.close()
+    }
+}
+
+func (t *tenant) info() EnvInfoJSON {
+    t.mutex.Lock()
+    running := t.done != nil
+    t.mutex.Unlock()
+
+    return EnvInfoJSON{
+        Name: t.name,
+        RunID: t.env.RunID(),
+        Width: t.env.Width,
+        Height: t.env.Height,
+        Ticks: t.env.Ticks(),
+        Running: running,
+        Paused: t.env.Paused(),
+    }
+}
+
+func (s *Server) Envs() []EnvInfoJSON {
+    s.mutex.RLock()
+    ts := make([]*tenant, 0, len(s.tenants))
+    for _, t := range s.tenants {
+        ts = append(ts, t)
+    }
+    s.mutex.RUnlock()
+
+    js := make([]EnvInfoJSON, len(ts))
+    for i, t := range ts {
+        js[i] = t.info()
+    }
+    sort.Slice(js, func(i, j int) bool {
+        return js[i].Name < js[j].Name
+    })
+    return js
+}
+
+func envStatus(err error) int {
+    switch err {
+    case ErrEnvNotFound:
+        return http.StatusNotFound
+    case ErrEnvExists, ErrEnvRunning, ErrEnvStopped:
+        return http.StatusConflict
+    case ErrEnvLimit:
+        return http.StatusTooManyRequests
+    case ErrEnvTooLarge:
+        return http.StatusRequestEntityTooLarge
+    }
+    return http.StatusBadRequest
+}
+
+func (s *Server) Register(mux *http.ServeMux) {
+    mux.HandleFunc("/envs", s.EnvsHandler)
+    mux.HandleFunc("/envs/", s.EnvsHandler)
+}
+
+func (s *Server) EnvsHandler(w http.ResponseWriter, r *http.Request) {
+    path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/envs"), "/")
+    if path == "" {
+        s.listHandler(w, r)
+        return
+    }
+
+    name, rest := path, ""
+    if i := strings.IndexByte(path, '/'); i >= 0 {
+        name, rest = path[:i], path[i + 1:]
+    }
+
+    t, err := s.lookup(name)
+    if err != nil {
+        http.Error(w, err.Error(), envStatus(err))
+        return
+    }
+
+    switch rest {
+    case "":
+        if r.URL.Path == "/envs/" + name + "/" {
+            t.serve(w, r, "/")
+            return
+        }
+        switch r.Method {
+        case http.MethodGet:
+            json.NewEncoder(w).Encode(t.info())
+        case http.MethodDelete:
+            if err := s.Delete(name); err != nil {
+                http.Error(w, err.Error(), envStatus(err))
+                return
+            }
+            w.WriteHeader(http.StatusNoContent)
+        default:
+            http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        }
+    case "start", "stop":
+        if r.Method != http.MethodPost {
+            http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+            return
+        }
+        if rest == "start" {
+            err = s.Start(name)
+        } else {
+            err = t.stop()
+        }
+        if err != nil {
+            http.Error(w, err.Error(), envStatus(err))
+            return
+        }
+        json.NewEncoder(w).Encode(t.info())
+    default:
+        t.serve(w, r, "/" + rest)
+    }
+}
+
+func (t *tenant) serve(w http.ResponseWriter, r *http.Request, path string) {
+    r2 := r.Clone(r.Context())
+    r2.URL.Path = path
+    r2.URL.RawPath = ""
+    t.mux.ServeHTTP(w, r2)
+}
+
+func (s *Server) listHandler(w http.ResponseWriter, r *http.Request) {
+    switch r.Method {
+    case http.MethodGet:
+        json.NewEncoder(w).Encode(s.Envs())
+    case http.MethodPost:
+        var j CreateEnvJSON
+        if err := json.NewDecoder(r.Body).Decode(&j); err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        if _, err := s.Create(j); err != nil {
+            http.Error(w, err.Error(), envStatus(err))
+            return
+        }
+        t, err := s.lookup(j.Name)
+        if err != nil {
+            http.Error(w, err.Error(), envStatus(err))
+            return
+        }
+        w.WriteHeader(http.StatusCreated)
+        json.NewEncoder(w).Encode(t.info())
+    default:
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+    }
+}
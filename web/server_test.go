@@ -0,0 +1,134 @@
+// This project is licensed under the MIT License (see LICENSE).
+
+package web
+
+import (
+    "bytes"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    tp "tidepool/tidepool"
+)
+
+func testEnv(name string) CreateEnvJSON {
+    return CreateEnvJSON{Name: name, Width: 16, Height: 16, GenomeSize: 32, Seed: 1}
+}
+
+func TestServerCreate(t *testing.T) {
+    s := NewServer(ServerOptions{MaxEnvs: 2, Workers: 1})
+    defer s.Close()
+
+    if _, err := s.Create(testEnv("a")); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := s.Create(testEnv("a")); err != ErrEnvExists {
+        t.Fatalf("expected ErrEnvExists, got %v", err)
+    }
+    if _, err := s.Create(testEnv("../a")); err != ErrInvalidEnvName {
+        t.Fatalf("expected ErrInvalidEnvName, got %v", err)
+    }
+
+    big := testEnv("big")
+    big.Width, big.Height = 1 << 20, 1 << 20
+    if _, err := s.Create(big); err != ErrEnvTooLarge {
+        t.Fatalf("expected ErrEnvTooLarge, got %v", err)
+    }
+    big.Width, big.Height, big.GenomeSize = 4096, 4096, 4096
+    if _, err := s.Create(big); err != ErrEnvTooLarge {
+        t.Fatalf("expected ErrEnvTooLarge, got %v", err)
+    }
+
+    bad := testEnv("bad")
+    config := tp.NewEnv(1, 1, 8, 0, 1).GetConfig()
+    config.FailedKillPenalty = 0
+    bad.Config = &config
+    if _, err := s.Create(bad); err == nil {
+        t.Fatal("expected invalid config to be rejected")
+    }
+
+    if _, err := s.Create(testEnv("b")); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := s.Create(testEnv("c")); err != ErrEnvLimit {
+        t.Fatalf("expected ErrEnvLimit, got %v", err)
+    }
+    if n := len(s.Envs()); n != 2 {
+        t.Fatalf("expected 2 envs, got %d", n)
+    }
+}
+
+func TestServerLifecycle(t *testing.T) {
+    s := NewServer(ServerOptions{Workers: 1})
+    defer s.Close()
+
+    j := testEnv("a")
+    j.Start = true
+    if _, err := s.Create(j); err != nil {
+        t.Fatal(err)
+    }
+    if err := s.Start("a"); err != ErrEnvRunning {
+        t.Fatalf("expected ErrEnvRunning, got %v", err)
+    }
+    if err := s.Stop("a"); err != nil {
+        t.Fatal(err)
+    }
+    if err := s.Stop("a"); err != ErrEnvStopped {
+        t.Fatalf("expected ErrEnvStopped, got %v", err)
+    }
+    if err := s.Start("a"); err != nil {
+        t.Fatal(err)
+    }
+    if err := s.Delete("a"); err != nil {
+        t.Fatal(err)
+    }
+    if err := s.Start("a"); err != ErrEnvNotFound {
+        t.Fatalf("expected ErrEnvNotFound, got %v", err)
+    }
+}
+
+func TestServerHandler(t *testing.T) {
+    s := NewServer(ServerOptions{Workers: 1})
+    defer s.Close()
+    mux := http.NewServeMux()
+    s.Register(mux)
+
+    do := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+        var buf bytes.Buffer
+        if body != nil {
+            json.NewEncoder(&buf).Encode(body)
+        }
+        w := httptest.NewRecorder()
+        mux.ServeHTTP(w, httptest.NewRequest(method, path, &buf))
+        return w
+    }
+
+    if w := do(http.MethodPost, "/envs", testEnv("a")); w.Code != http.StatusCreated {
+        t.Fatalf("expected 201, got %d: %s", w.Code, w.Body)
+    }
+    if w := do(http.MethodPost, "/envs", testEnv("a")); w.Code != http.StatusConflict {
+        t.Fatalf("expected 409, got %d", w.Code)
+    }
+
+    var info EnvInfoJSON
+    w := do(http.MethodPost, "/envs/a/start", nil)
+    if err := json.NewDecoder(w.Body).Decode(&info); err != nil || !info.Running {
+        t.Fatalf("expected running env, got %+v (%v)", info, err)
+    }
+
+    var envs []EnvInfoJSON
+    if err := json.NewDecoder(do(http.MethodGet, "/envs", nil).Body).Decode(&envs); err != nil {
+        t.Fatal(err)
+    }
+    if len(envs) != 1 || envs[0].Name != "a" {
+        t.Fatalf("unexpected env list %+v", envs)
+    }
+
+    if w := do(http.MethodDelete, "/envs/a", nil); w.Code != http.StatusNoContent {
+        t.Fatalf("expected 204, got %d", w.Code)
+    }
+    if w := do(http.MethodGet, "/envs/a", nil); w.Code != http.StatusNotFound {
+        t.Fatalf("expected 404, got %d", w.Code)
+    }
+}